@@ -0,0 +1,78 @@
+package efincore
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const proxyAuthRealm = "efincore"
+
+// CredentialChecker validates the credentials sent by clients in the
+// Proxy-Authorization header
+type CredentialChecker interface {
+	CheckCredentials(user, password string) bool
+}
+
+type CredentialCheckerFunc func(user, password string) bool
+
+func (f CredentialCheckerFunc) CheckCredentials(user, password string) bool {
+	return f(user, password)
+}
+
+// StaticCredentials returns a CredentialChecker accepting a single user
+func StaticCredentials(user, password string) CredentialChecker {
+	return CredentialCheckerFunc(func(u, p string) bool {
+		userOK := subtle.ConstantTimeCompare([]byte(u), []byte(user)) == 1
+		passwordOK := subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1
+
+		return userOK && passwordOK
+	})
+}
+
+func (m *mitm) SetCredentialChecker(c CredentialChecker) {
+	if c == nil {
+		m.credentialChecker.Store(nil)
+		return
+	}
+
+	m.credentialChecker.Store(&c)
+}
+
+// authorize checks the Proxy-Authorization header of a request. If the
+// credentials are not valid, it answers 407 and returns false
+func (m *mitm) authorize(w http.ResponseWriter, r *http.Request) bool {
+	checker := m.credentialChecker.Load()
+	if checker == nil {
+		return true
+	}
+
+	user, password, ok := parseProxyAuthorization(r.Header.Get("Proxy-Authorization"))
+	if ok && (*checker).CheckCredentials(user, password) {
+		return true
+	}
+
+	m.stats.Increase(StatProxyAuthFailures)
+	m.logger.Warn("proxy authentication failed", hostAttr(r.URL.Host), slog.String("user", user))
+
+	w.Header().Set("Proxy-Authenticate", `Basic realm="`+proxyAuthRealm+`"`)
+	w.WriteHeader(http.StatusProxyAuthRequired)
+
+	return false
+}
+
+func parseProxyAuthorization(header string) (string, string, bool) {
+	scheme, credentials, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Basic") {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(credentials))
+	if err != nil {
+		return "", "", false
+	}
+
+	return strings.Cut(string(decoded), ":")
+}
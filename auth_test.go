@@ -0,0 +1,171 @@
+package efincore
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestParseProxyAuthorization(t *testing.T) {
+	header := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass:word"))
+
+	user, password, ok := parseProxyAuthorization(header)
+	if !ok || user != "user" || password != "pass:word" {
+		t.Errorf("credentials: got '%s' '%s' '%t', expected '%s' '%s' '%t'", user, password, ok, "user", "pass:word", true)
+	}
+
+	if _, _, ok := parseProxyAuthorization("Bearer token"); ok {
+		t.Errorf("non basic credentials were accepted")
+	}
+}
+
+func TestProxyAuth_ConnectWithoutCredentials_Gets407(t *testing.T) {
+	proxy := runTestProxy(t)
+	proxy.SetCredentialChecker(StaticCredentials("user", "secret"))
+
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {})
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, conn := sendTestConnect(t, proxy, serverURL.Host, nil)
+	defer conn.Close()
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusProxyAuthRequired {
+		t.Errorf("response status code: got '%d', expected '%d'", resp.StatusCode, http.StatusProxyAuthRequired)
+	}
+
+	if got := resp.Header.Get("Proxy-Authenticate"); got != `Basic realm="efincore"` {
+		t.Errorf("Proxy-Authenticate header: got '%s', expected '%s'", got, `Basic realm="efincore"`)
+	}
+
+	if got := proxy.GetStats()[StatProxyAuthFailures]; got != 1 {
+		t.Errorf("auth failures: got '%d', expected '%d'", got, 1)
+	}
+}
+
+func TestProxyAuth_ClientWithCredentials_RequestsSucceed(t *testing.T) {
+	proxy := runTestProxy(t)
+	proxy.SetCredentialChecker(StaticCredentials("user", "secret"))
+
+	var gotProxyAuth string
+	plainServer := newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		gotProxyAuth = r.Header.Get("Proxy-Authorization")
+	})
+	httpsServer := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	proxyURL := proxy.URL()
+	proxyURL.User = url.UserPassword("user", "secret")
+	client := newTestClientProxy(t, proxyURL.String())
+
+	for _, u := range []string{plainServer.URL, httpsServer.URL} {
+		resp, err := client.Get(u)
+		if err != nil {
+			t.Fatalf("request to '%s' failed: %v", u, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("response status code of '%s': got '%d', expected '%d'", u, resp.StatusCode, http.StatusOK)
+		}
+	}
+
+	if gotProxyAuth != "" {
+		t.Errorf("Proxy-Authorization header forwarded upstream: '%s'", gotProxyAuth)
+	}
+
+	badURL := proxy.URL()
+	badURL.User = url.UserPassword("user", "wrong")
+	badClient := newTestClientProxy(t, badURL.String())
+
+	resp, err := badClient.Get(plainServer.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusProxyAuthRequired {
+		t.Errorf("response status code: got '%d', expected '%d'", resp.StatusCode, http.StatusProxyAuthRequired)
+	}
+}
+
+// connectResponseConn discards the response to the CONNECT request before
+// returning the bytes of the tunnel
+type connectResponseConn struct {
+	net.Conn
+	reader *bufio.Reader
+	status string
+}
+
+func (c *connectResponseConn) Read(p []byte) (int, error) {
+	if c.status == "" {
+		resp, err := http.ReadResponse(c.reader, nil)
+		if err != nil {
+			return 0, err
+		}
+		c.status = resp.Status
+	}
+
+	return c.reader.Read(p)
+}
+
+func TestConnect_ClientHelloSentBeforeResponse_IsNotLost(t *testing.T) {
+	proxy := runTestProxy(t)
+
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("response"))
+	})
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", proxy.Addr())
+	if err != nil {
+		t.Fatalf("could not connect to proxy: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("CONNECT " + serverURL.Host + " HTTP/1.1\r\nHost: " + serverURL.Host + "\r\n\r\n")); err != nil {
+		t.Fatalf("could not send CONNECT request: %v", err)
+	}
+
+	// the TLS handshake starts without waiting for the CONNECT response
+	tunnel := &connectResponseConn{Conn: conn, reader: bufio.NewReader(conn)}
+	tlsConn := tls.Client(tunnel, &tls.Config{InsecureSkipVerify: true})
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("could not create request: %v", err)
+	}
+
+	if err := req.Write(tlsConn); err != nil {
+		t.Fatalf("could not send request: %v", err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(tlsConn), req)
+	if err != nil {
+		t.Fatalf("could not read response: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read response body: %v", err)
+	}
+
+	if tunnel.status != "200 Connection Established" {
+		t.Errorf("CONNECT status: got '%s', expected '%s'", tunnel.status, "200 Connection Established")
+	}
+
+	if string(body) != "response" {
+		t.Errorf("response body: got '%s', expected '%s'", body, "response")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"math"
 	"math/big"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "embed"
@@ -25,6 +26,7 @@ type CA struct {
 	proxyCertificate []byte
 	proxyPrivateKey  []byte
 	cache            *sync.Map
+	cacheSize        *atomic.Int64
 }
 
 func NewCA() *CA {
@@ -32,6 +34,7 @@ func NewCA() *CA {
 		proxyCertificate: defaultProxyCertificate,
 		proxyPrivateKey:  defaultProxyPrivateKey,
 		cache:            &sync.Map{},
+		cacheSize:        &atomic.Int64{},
 	}
 }
 
@@ -93,10 +96,19 @@ func (c *CA) GetCertificateFor(domain string) (tls.Certificate, error) {
 		return tls.Certificate{}, err
 	}
 
-	c.cache.Store(domain, serverCert)
+	if cachedCert, loaded := c.cache.LoadOrStore(domain, serverCert); loaded {
+		return cachedCert.(tls.Certificate), nil
+	}
+	c.cacheSize.Add(1)
+
 	return serverCert, nil
 }
 
+// CacheSize returns the number of certificates generated and cached
+func (c *CA) CacheSize() int {
+	return int(c.cacheSize.Load())
+}
+
 func DefaultProxyCertificate() string {
 	return string(defaultProxyCertificate)
 }
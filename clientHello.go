@@ -0,0 +1,77 @@
+package efincore
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	// tlsRecordTypeHandshake is the first byte of the TLS record carrying
+	// the ClientHello
+	tlsRecordTypeHandshake = 0x16
+
+	// clientPeekTimeout is how long the proxy waits for the first bytes
+	// sent by a client to detect its protocol
+	clientPeekTimeout = 500 * time.Millisecond
+)
+
+var errClientHelloRead = errors.New("client hello read")
+
+// clientHelloConn feeds the bytes of a reader to a TLS handshake that is
+// only used to parse the ClientHello. Nothing is written to the client
+type clientHelloConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *clientHelloConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *clientHelloConn) Write(p []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+// isTLSClientHello reports whether the first byte sent by the client
+// starts a TLS handshake. Clients that send nothing within
+// clientPeekTimeout, as in protocols where the server speaks first, are
+// not TLS clients
+func isTLSClientHello(conn net.Conn, r *bufio.Reader) bool {
+	conn.SetReadDeadline(time.Now().Add(clientPeekTimeout))
+	first, err := r.Peek(1)
+	conn.SetReadDeadline(time.Time{})
+
+	return err == nil && first[0] == tlsRecordTypeHandshake
+}
+
+// peekClientHello parses the ClientHello sent by the client without
+// consuming it. The returned connection replays the bytes that were read
+func peekClientHello(conn net.Conn, r *bufio.Reader) (*tls.ClientHelloInfo, net.Conn, error) {
+	peeked := &bytes.Buffer{}
+
+	var hello *tls.ClientHelloInfo
+	err := tls.Server(&clientHelloConn{Conn: conn, reader: io.TeeReader(r, peeked)}, &tls.Config{
+		GetConfigForClient: func(h *tls.ClientHelloInfo) (*tls.Config, error) {
+			hello = &tls.ClientHelloInfo{}
+			*hello = *h
+			hello.Conn = conn
+			return nil, errClientHelloRead
+		},
+	}).Handshake()
+
+	replay := &bufferedConn{
+		Conn:   conn,
+		reader: io.MultiReader(peeked, r),
+	}
+
+	if hello == nil {
+		return nil, replay, err
+	}
+
+	return hello, replay, nil
+}
@@ -17,8 +17,14 @@ func (f RequestFilterFunc) ShouldInterceptRequest(r *http.Request) bool {
 
 type criteria struct {
 	domainsRe *regexp.Regexp
+	scope     *Scope
 
-	requestFilters []RequestFilter
+	requestFilters []filterEntry
+}
+
+type filterEntry struct {
+	registration
+	filter RequestFilter
 }
 
 func newCriteria() *criteria {
@@ -33,13 +39,33 @@ func (c *criteria) shouldInterceptDomain(domain string) bool {
 	return c.domainsRe.MatchString(domain)
 }
 
+func (c *criteria) shouldInterceptHost(scheme, host string, port int) bool {
+	if c == nil {
+		return true
+	}
+
+	if !c.shouldInterceptDomain(host) {
+		return false
+	}
+
+	return c.scope.inScopeHost(scheme, host, port)
+}
+
 func (c *criteria) shouldInterceptRequest(r *http.Request) bool {
 	if c == nil {
 		return true
 	}
 
+	if !c.scope.InScope(r) {
+		return false
+	}
+
 	for _, f := range c.requestFilters {
-		if !f.ShouldInterceptRequest(r) {
+		if !f.enabled {
+			continue
+		}
+
+		if !f.filter.ShouldInterceptRequest(r) {
 			return false
 		}
 	}
@@ -54,13 +80,65 @@ func (c *criteria) WithDomainRegex(re *regexp.Regexp) *criteria {
 	return newCriteria
 }
 
-func (c *criteria) AddRequestFilter(f RequestFilter) *criteria {
+func (c *criteria) WithScope(s *Scope) *criteria {
 	newCriteria := c.clone()
-	newCriteria.requestFilters = append(newCriteria.requestFilters, f)
+	newCriteria.scope = s
 
 	return newCriteria
 }
 
+func (c *criteria) AddRequestFilter(f RequestFilter, reg registration) *criteria {
+	newCriteria := c.clone()
+	newCriteria.requestFilters = append(newCriteria.requestFilters, filterEntry{reg, f})
+
+	return newCriteria
+}
+
+func (c *criteria) RemoveRequestFilter(id uint64) *criteria {
+	newCriteria := c.clone()
+
+	requestFilters := []filterEntry{}
+	for _, f := range newCriteria.requestFilters {
+		if f.id == id {
+			continue
+		}
+		requestFilters = append(requestFilters, f)
+	}
+	newCriteria.requestFilters = requestFilters
+
+	return newCriteria
+}
+
+func (c *criteria) SetRequestFilterEnabled(id uint64, enabled bool) *criteria {
+	newCriteria := c.clone()
+
+	for i := range newCriteria.requestFilters {
+		if newCriteria.requestFilters[i].id == id {
+			newCriteria.requestFilters[i].enabled = enabled
+		}
+	}
+
+	return newCriteria
+}
+
+func (c *criteria) ListRequestFilters() []FilterInfo {
+	result := []FilterInfo{}
+	if c == nil {
+		return result
+	}
+
+	for _, f := range c.requestFilters {
+		result = append(result, FilterInfo{
+			ID:          f.id,
+			Name:        f.name,
+			Description: f.description,
+			Enabled:     f.enabled,
+		})
+	}
+
+	return result
+}
+
 func (c *criteria) clone() *criteria {
 	if c == nil {
 		return &criteria{}
@@ -73,6 +151,7 @@ func (c *criteria) clone() *criteria {
 
 	return &criteria{
 		domainsRe:      domainsRe,
-		requestFilters: append([]RequestFilter{}, c.requestFilters...),
+		scope:          c.scope,
+		requestFilters: append([]filterEntry{}, c.requestFilters...),
 	}
 }
@@ -51,3 +51,20 @@ func TestCriteria_ShouldInterceptRequest_ReturnsTrueByDefault(t *testing.T) {
 		t.Errorf("expected '%t', got '%t'", got, expected)
 	}
 }
+
+func TestCriteria_WithScope_ShouldInterceptHost(t *testing.T) {
+	scope, err := NewScope(ScopeRule{Action: ScopeInclude, Host: "*.example.com"})
+	if err != nil {
+		t.Fatalf("could not create scope: %v", err)
+	}
+
+	c := newCriteria().WithScope(scope)
+
+	if got := c.shouldInterceptHost("https", "www.example.com", 443); got != true {
+		t.Errorf("expected 'true', got '%t'", got)
+	}
+
+	if got := c.shouldInterceptHost("https", "www.other.com", 443); got != false {
+		t.Errorf("expected 'false', got '%t'", got)
+	}
+}
@@ -0,0 +1,271 @@
+package efincore
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"syscall"
+
+	"github.com/google/uuid"
+)
+
+// ErrorKind classifies the errors of the proxy. It is used in the error
+// pages, the error events and the StatErrorsByKind stats
+type ErrorKind string
+
+const (
+	ErrorKindDNS               ErrorKind = "dns"
+	ErrorKindConnectionRefused ErrorKind = "connection-refused"
+	ErrorKindTLS               ErrorKind = "tls"
+	ErrorKindTimeout           ErrorKind = "timeout"
+	ErrorKindHookRejected      ErrorKind = "hook-rejected"
+	ErrorKindHook              ErrorKind = "hook"
+	ErrorKindUpstream          ErrorKind = "upstream"
+	ErrorKindUpstreamProxy     ErrorKind = "upstream-proxy"
+
+	// errors that are not reported to the client
+	ErrorKindClient      ErrorKind = "client"
+	ErrorKindCertificate ErrorKind = "certificate"
+)
+
+func (k ErrorKind) String() string {
+	return string(k)
+}
+
+// StatusCode returns the status of the response sent to the client for
+// errors of this kind
+func (k ErrorKind) StatusCode() int {
+	switch k {
+	case ErrorKindHookRejected:
+		return http.StatusForbidden
+	case ErrorKindTimeout:
+		return http.StatusGatewayTimeout
+	}
+
+	return http.StatusBadGateway
+}
+
+func (k ErrorKind) description() string {
+	switch k {
+	case ErrorKindDNS:
+		return "the upstream host name could not be resolved"
+	case ErrorKindConnectionRefused:
+		return "the upstream server refused the connection"
+	case ErrorKindTLS:
+		return "the TLS connection with the upstream server failed"
+	case ErrorKindTimeout:
+		return "the request timed out"
+	case ErrorKindHookRejected:
+		return "the request was rejected by the proxy"
+	case ErrorKindHook:
+		return "a proxy hook failed"
+	case ErrorKindUpstreamProxy:
+		return "the upstream proxy could not connect to the server"
+	}
+
+	return "the upstream server could not be reached"
+}
+
+// ErrHookRejected can be returned, wrapped or not, by mod hooks to block a
+// request or response. The client gets a 403 response regardless of the
+// failure policy of the hook, and the rejection does not count as a
+// failure
+var ErrHookRejected = errors.New("rejected by hook")
+
+// ProxyError is an error that prevented the proxy from completing a
+// request. RequestID is the zero UUID if the request was not intercepted
+type ProxyError struct {
+	Kind      ErrorKind
+	Host      string
+	RequestID uuid.UUID
+	Err       error
+}
+
+func newProxyError(host string, err error) *ProxyError {
+	var proxyErr *ProxyError
+	if errors.As(err, &proxyErr) {
+		return proxyErr
+	}
+
+	return &ProxyError{
+		Kind: errorKind(err),
+		Host: host,
+		Err:  err,
+	}
+}
+
+func (e *ProxyError) Error() string {
+	return fmt.Sprintf("%s error for '%s': %v", e.Kind, e.Host, e.Err)
+}
+
+func (e *ProxyError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ProxyError) StatusCode() int {
+	return e.Kind.StatusCode()
+}
+
+func errorKind(err error) ErrorKind {
+	var hookErr *HookError
+	if errors.As(err, &hookErr) {
+		switch {
+		case errors.Is(err, ErrHookRejected):
+			return ErrorKindHookRejected
+		case errors.Is(err, ErrHookTimeout):
+			return ErrorKindTimeout
+		}
+
+		return ErrorKindHook
+	}
+
+	var upstreamProxyErr *UpstreamProxyError
+	if errors.As(err, &upstreamProxyErr) {
+		return ErrorKindUpstreamProxy
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return ErrorKindTimeout
+		}
+
+		return ErrorKindDNS
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrorKindConnectionRefused
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrorKindTimeout
+	}
+
+	var (
+		recordErr      tls.RecordHeaderError
+		alertErr       tls.AlertError
+		verifyErr      *tls.CertificateVerificationError
+		unknownAuthErr x509.UnknownAuthorityError
+		hostnameErr    x509.HostnameError
+		certInvalidErr x509.CertificateInvalidError
+	)
+	if errors.As(err, &recordErr) || errors.As(err, &alertErr) || errors.As(err, &verifyErr) ||
+		errors.As(err, &unknownAuthErr) || errors.As(err, &hostnameErr) || errors.As(err, &certInvalidErr) {
+		return ErrorKindTLS
+	}
+
+	if _, ok := remoteTLSAlert(err); ok {
+		return ErrorKindTLS
+	}
+
+	return ErrorKindUpstream
+}
+
+// remoteTLSAlert returns the TLS alert sent by the peer that caused err
+func remoteTLSAlert(err error) (uint8, bool) {
+	var alertErr tls.AlertError
+	if errors.As(err, &alertErr) {
+		return uint8(alertErr), true
+	}
+
+	// crypto/tls returns the alerts of the peer as an OpError wrapping an
+	// unexported uint8 type
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "remote error" {
+		return 0, false
+	}
+
+	v := reflect.ValueOf(opErr.Err)
+	if v.Kind() != reflect.Uint8 {
+		return 0, false
+	}
+
+	return uint8(v.Uint()), true
+}
+
+// ErrorPage builds the response written to the client when a request
+// fails
+type ErrorPage func(r *http.Request, err *ProxyError) *http.Response
+
+// DefaultErrorPage answers with the status of the error kind and a plain
+// text body explaining the cause
+func DefaultErrorPage(r *http.Request, err *ProxyError) *http.Response {
+	body := fmt.Sprintf("efincore: %s (%s)\n%v\n", err.Kind.description(), err.Kind, err.Err)
+
+	return &http.Response{
+		StatusCode:    err.StatusCode(),
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Request:       r,
+		Header:        http.Header{"Content-Type": []string{"text/plain; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
+
+func errorResponse(page ErrorPage, r *http.Request, err *ProxyError) *http.Response {
+	if page == nil {
+		page = DefaultErrorPage
+	}
+
+	resp := page(r, err)
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+	if resp.Body == nil {
+		resp.Body = http.NoBody
+	}
+
+	// the tunnel stays open after the error, so the body length must be
+	// known for the client to find the end of the response
+	if resp.ContentLength <= 0 && resp.Body != http.NoBody {
+		body, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if readErr != nil {
+			body = nil
+		}
+
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		resp.ContentLength = int64(len(body))
+		resp.TransferEncoding = nil
+	}
+	if resp.ProtoMajor == 0 {
+		resp.ProtoMajor, resp.ProtoMinor = 1, 1
+	}
+	resp.Request = r
+
+	return resp
+}
+
+// writeErrorResponse writes an error page to the hijacked connection of a
+// client
+func writeErrorResponse(w io.Writer, page ErrorPage, r *http.Request, err *ProxyError) error {
+	resp := errorResponse(page, r, err)
+	defer resp.Body.Close()
+
+	return resp.Write(w)
+}
+
+// serveErrorResponse writes an error page using a http.ResponseWriter
+func serveErrorResponse(w http.ResponseWriter, page ErrorPage, r *http.Request, err *ProxyError) {
+	resp := errorResponse(page, r, err)
+	defer resp.Body.Close()
+
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
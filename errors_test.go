@@ -0,0 +1,287 @@
+package efincore
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/artilugio0/efincore/proto"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+)
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected ErrorKind
+	}{
+		{
+			name:     "dns",
+			err:      fmt.Errorf("dial: %w", &net.DNSError{Err: "no such host", Name: "invalid.test"}),
+			expected: ErrorKindDNS,
+		},
+		{
+			name:     "connection refused",
+			err:      &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+			expected: ErrorKindConnectionRefused,
+		},
+		{
+			name:     "timeout",
+			err:      fmt.Errorf("read: %w", os.ErrDeadlineExceeded),
+			expected: ErrorKindTimeout,
+		},
+		{
+			name:     "hook rejected",
+			err:      &HookError{Err: fmt.Errorf("blocked host: %w", ErrHookRejected)},
+			expected: ErrorKindHookRejected,
+		},
+		{
+			name:     "hook timeout",
+			err:      &HookError{Err: ErrHookTimeout},
+			expected: ErrorKindTimeout,
+		},
+		{
+			name:     "hook failure",
+			err:      &HookError{Err: errors.New("failed")},
+			expected: ErrorKindHook,
+		},
+		{
+			name:     "tls remote alert",
+			err:      fmt.Errorf("could not connect to destination: %w", testRemoteTLSAlert(t)),
+			expected: ErrorKindTLS,
+		},
+		{
+			name:     "other",
+			err:      errors.New("unexpected EOF"),
+			expected: ErrorKindUpstream,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorKind(tt.err); got != tt.expected {
+				t.Errorf("error kind: got '%s', expected '%s'", got, tt.expected)
+			}
+		})
+	}
+}
+
+// testRemoteTLSAlert returns the error of a handshake with a server that
+// only accepts TLS 1.3 using TLS 1.2
+func testRemoteTLSAlert(t *testing.T) error {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{MinVersion: tls.VersionTLS13}
+	server.StartTLS()
+	defer server.Close()
+
+	conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{
+		InsecureSkipVerify: true,
+		MaxVersion:         tls.VersionTLS12,
+	})
+	if err == nil {
+		conn.Close()
+		t.Fatalf("handshake with TLS 1.2 succeeded")
+	}
+
+	return err
+}
+
+func TestErrorKindStatusCode(t *testing.T) {
+	expected := map[ErrorKind]int{
+		ErrorKindDNS:               http.StatusBadGateway,
+		ErrorKindConnectionRefused: http.StatusBadGateway,
+		ErrorKindTimeout:           http.StatusGatewayTimeout,
+		ErrorKindHookRejected:      http.StatusForbidden,
+	}
+
+	for kind, status := range expected {
+		if got := kind.StatusCode(); got != status {
+			t.Errorf("status code of '%s': got '%d', expected '%d'", kind, got, status)
+		}
+	}
+}
+
+func TestConnectionRefused_ConnectGetsErrorPageAndErrorHookIsCalled(t *testing.T) {
+	proxy := runTestProxy(t)
+
+	errChan := make(chan *ProxyError, 1)
+	proxy.AddErrorHook(HookErrorReadFunc(func(e *ProxyError) error {
+		errChan <- e
+		return nil
+	}))
+
+	// nothing listens on a free port
+	resp, conn := sendTestConnect(t, proxy, "127.0.0.1:"+strconv.Itoa(getFreePort(t)), nil)
+	defer conn.Close()
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read response body: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("response status code: got '%d', expected '%d'", resp.StatusCode, http.StatusBadGateway)
+	}
+
+	if !strings.Contains(string(body), string(ErrorKindConnectionRefused)) {
+		t.Errorf("response body: got '%s', expected it to contain '%s'", body, ErrorKindConnectionRefused)
+	}
+
+	select {
+	case e := <-errChan:
+		if e.Kind != ErrorKindConnectionRefused {
+			t.Errorf("error kind: got '%s', expected '%s'", e.Kind, ErrorKindConnectionRefused)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("error hook not called")
+	}
+
+	if got := proxy.GetStats()[ErrorStat(ErrorKindConnectionRefused)]; got != 1 {
+		t.Errorf("errors by kind: got '%d', expected '%d'", got, 1)
+	}
+}
+
+func TestRequestModHookRejection_ClientGets403(t *testing.T) {
+	proxy := runTestProxy(t)
+
+	serverCalled := false
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {
+		serverCalled = true
+	})
+
+	handle := proxy.AddRequestModHook(HookRequestModFunc(func(r *http.Request, id uuid.UUID) error {
+		return fmt.Errorf("not allowed: %w", ErrHookRejected)
+	}), WithFailurePolicy(HookFailOpen))
+
+	client := newTestClientProxy(t, proxy.URL().String())
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("response status code: got '%d', expected '%d'", resp.StatusCode, http.StatusForbidden)
+	}
+
+	if serverCalled {
+		t.Errorf("server received a rejected request")
+	}
+
+	for _, h := range proxy.Hooks() {
+		if h.ID == handle.ID() && h.Failures != 0 {
+			t.Errorf("hook failures: got '%d', expected '%d'", h.Failures, 0)
+		}
+	}
+}
+
+func TestCustomErrorPage(t *testing.T) {
+	proxy := runTestProxy(t)
+
+	proxy.SetErrorPage(func(r *http.Request, e *ProxyError) *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusTeapot,
+			Body:       io.NopCloser(strings.NewReader("custom: " + string(e.Kind))),
+		}
+	})
+
+	proxy.AddRequestModHook(HookRequestModFunc(func(r *http.Request, id uuid.UUID) error {
+		return ErrHookRejected
+	}))
+
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {})
+	client := newTestClientProxy(t, proxy.URL().String())
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read response body: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusTeapot {
+		t.Errorf("response status code: got '%d', expected '%d'", resp.StatusCode, http.StatusTeapot)
+	}
+
+	expectedBody := "custom: " + string(ErrorKindHookRejected)
+	if string(body) != expectedBody {
+		t.Errorf("response body: got '%s', expected '%s'", body, expectedBody)
+	}
+}
+
+type testGetErrorsStream struct {
+	grpc.ServerStream
+
+	ctx    context.Context
+	errors chan *proto.ProxyError
+}
+
+func (s *testGetErrorsStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testGetErrorsStream) Send(e *proto.ProxyError) error {
+	s.errors <- e
+	return nil
+}
+
+func TestGRPCServerGetErrors_SendsErrorsOfErrorHook(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server := NewGRPCServer("127.0.0.1:0")
+	stream := &testGetErrorsStream{
+		ctx:    ctx,
+		errors: make(chan *proto.ProxyError, 1),
+	}
+
+	go server.GetErrors(&proto.GetErrorsInput{}, stream)
+
+	// wait for the stream to be subscribed
+	for len(server.getErrorClients()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	id := uuid.New()
+	server.ErrorHook(&ProxyError{
+		Kind:      ErrorKindTimeout,
+		Host:      "example.com",
+		RequestID: id,
+		Err:       os.ErrDeadlineExceeded,
+	})
+
+	select {
+	case e := <-stream.errors:
+		if e.Kind != string(ErrorKindTimeout) {
+			t.Errorf("kind: got '%s', expected '%s'", e.Kind, ErrorKindTimeout)
+		}
+
+		if e.RequestId != id.String() {
+			t.Errorf("request id: got '%s', expected '%s'", e.RequestId, id.String())
+		}
+
+		if e.StatusCode != http.StatusGatewayTimeout {
+			t.Errorf("status code: got '%d', expected '%d'", e.StatusCode, http.StatusGatewayTimeout)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("error not sent")
+	}
+}
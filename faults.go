@@ -0,0 +1,214 @@
+package efincore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// FaultRule injects failures in the requests of inspected tunnels that are
+// in its scope, to test how clients handle them. Zero values disable each
+// fault, including Probability
+type FaultRule struct {
+	Name     string
+	Disabled bool
+
+	// Scope limits the requests the rule applies to, as a Scope does
+	Scope []ScopeRule
+
+	// Probability is the chance, between 0 and 1, that a request in scope
+	// gets the faults. Use 1 to inject them in every request
+	Probability float64
+
+	// Latency delays sending the response to the client
+	Latency time.Duration
+
+	// BytesPerSecond limits the speed the response is sent to the client
+	BytesPerSecond int
+
+	// Reset resets the TCP connection of the client without sending a
+	// response
+	Reset bool
+
+	// StatusCode is sent to the client instead of the response of the
+	// upstream server, which is not contacted
+	StatusCode int
+
+	// TruncateBody closes the client connection after sending the headers
+	// and the first TruncateAfter bytes of the response body
+	TruncateBody  bool
+	TruncateAfter int
+}
+
+type compiledFaultRule struct {
+	rule  FaultRule
+	scope *Scope
+}
+
+// Faults is a set of fault rules. The first rule in scope whose
+// probability check succeeds is applied to each request
+type Faults struct {
+	rules *atomic.Pointer[[]compiledFaultRule]
+
+	// random returns numbers in [0, 1)
+	random func() float64
+}
+
+func NewFaults(rules ...FaultRule) (*Faults, error) {
+	f := &Faults{
+		rules:  &atomic.Pointer[[]compiledFaultRule]{},
+		random: rand.Float64,
+	}
+
+	if err := f.SetRules(rules...); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// SetRules replaces every rule. The rules are left unchanged if any of the
+// new ones is invalid
+func (f *Faults) SetRules(rules ...FaultRule) error {
+	compiled := make([]compiledFaultRule, 0, len(rules))
+	for i, r := range rules {
+		cr, err := compileFaultRule(r)
+		if err != nil {
+			return fmt.Errorf("invalid fault rule %d: %v", i, err)
+		}
+
+		compiled = append(compiled, cr)
+	}
+
+	f.rules.Store(&compiled)
+	return nil
+}
+
+func (f *Faults) Rules() []FaultRule {
+	rules := *f.rules.Load()
+
+	result := make([]FaultRule, len(rules))
+	for i, r := range rules {
+		result[i] = r.rule
+	}
+
+	return result
+}
+
+func compileFaultRule(r FaultRule) (compiledFaultRule, error) {
+	cr := compiledFaultRule{rule: r}
+
+	switch {
+	case r.Probability < 0 || r.Probability > 1:
+		return cr, fmt.Errorf("invalid probability %v", r.Probability)
+	case r.Latency < 0:
+		return cr, fmt.Errorf("invalid latency %v", r.Latency)
+	case r.BytesPerSecond < 0:
+		return cr, fmt.Errorf("invalid bytes per second %d", r.BytesPerSecond)
+	case r.StatusCode != 0 && (r.StatusCode < 100 || r.StatusCode > 999):
+		return cr, fmt.Errorf("invalid status code %d", r.StatusCode)
+	case r.TruncateAfter < 0:
+		return cr, fmt.Errorf("invalid truncate after %d", r.TruncateAfter)
+	case r.Reset && (r.StatusCode != 0 || r.TruncateBody):
+		return cr, errors.New("reset can not be combined with other responses")
+	}
+
+	if len(r.Scope) > 0 {
+		scope, err := NewScope(r.Scope...)
+		if err != nil {
+			return cr, err
+		}
+		cr.scope = scope
+	}
+
+	return cr, nil
+}
+
+// find returns the rule applied to r, or nil if no fault is injected
+func (f *Faults) find(r *http.Request) *compiledFaultRule {
+	if f == nil {
+		return nil
+	}
+
+	for _, rule := range *f.rules.Load() {
+		if rule.rule.Disabled || !rule.scope.InScope(r) {
+			continue
+		}
+
+		if f.random() < rule.rule.Probability {
+			return &rule
+		}
+	}
+
+	return nil
+}
+
+// response returns the response with the injected status code
+func (r *compiledFaultRule) response(req *http.Request) *http.Response {
+	code := r.rule.StatusCode
+	return newLocalResponse(req, code, "text/plain; charset=utf-8", []byte(fmt.Sprintf("%d %s\n", code, http.StatusText(code))))
+}
+
+// write sends a dumped response to the client, limiting its speed and
+// truncating its body. Without a rule the response is sent as it is
+func (r *compiledFaultRule) write(w io.Writer, resp []byte) (int64, error) {
+	if r == nil {
+		return io.Copy(w, bytes.NewReader(resp))
+	}
+
+	if r.rule.TruncateBody {
+		if i := bytes.Index(resp, []byte("\r\n\r\n")); i >= 0 {
+			resp = resp[:min(len(resp), i+4+r.rule.TruncateAfter)]
+		}
+	}
+
+	if r.rule.BytesPerSecond <= 0 {
+		return io.Copy(w, bytes.NewReader(resp))
+	}
+
+	// the response is sent in chunks of a tenth of a second of data
+	chunk := max(r.rule.BytesPerSecond/10, 1)
+	start := time.Now()
+
+	var written int64
+	for len(resp) > 0 {
+		n, err := w.Write(resp[:min(len(resp), chunk)])
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+		resp = resp[n:]
+
+		elapsed := time.Duration(float64(written) / float64(r.rule.BytesPerSecond) * float64(time.Second))
+		time.Sleep(time.Until(start.Add(elapsed)))
+	}
+
+	return written, nil
+}
+
+// resetConn makes the TCP connection under conn send a reset when it is
+// closed, and closes it. The TLS session of the connection is not closed,
+// so that clients see the connection reset by the peer. It reports
+// whether a TCP connection was found
+func resetConn(conn net.Conn) bool {
+	tcpConn, ok := tcpConnOf(conn)
+	if !ok {
+		return false
+	}
+
+	tcpConn.SetLinger(0)
+	tcpConn.Close()
+	return true
+}
+
+// SetFaults sets the faults injected in the requests of inspected tunnels;
+// nil disables them
+func (m *mitm) SetFaults(f *Faults) {
+	m.faults.Store(f)
+}
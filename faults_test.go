@@ -0,0 +1,236 @@
+package efincore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/artilugio0/efincore/proto"
+)
+
+func TestFaults_Find(t *testing.T) {
+	f, err := NewFaults(
+		FaultRule{Name: "disabled", Disabled: true, Probability: 1},
+		FaultRule{Name: "never", Scope: []ScopeRule{{Host: "never.example.com"}}},
+		FaultRule{Name: "scoped", Probability: 1, Scope: []ScopeRule{{Host: "api.example.com"}}},
+		FaultRule{Name: "half", Probability: 0.5},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		url      string
+		random   float64
+		expected string
+	}{
+		{"https://api.example.com/", 0.9, "scoped"},
+		{"https://never.example.com/", 0, "half"},
+		{"https://www.example.com/", 0.4, "half"},
+		{"https://www.example.com/", 0.6, ""},
+	}
+
+	for _, tt := range tests {
+		f.random = func() float64 { return tt.random }
+
+		got := ""
+		if rule := f.find(httptest.NewRequest(http.MethodGet, tt.url, nil)); rule != nil {
+			got = rule.rule.Name
+		}
+
+		if got != tt.expected {
+			t.Errorf("rule for '%s' with random %v: got '%s', expected '%s'", tt.url, tt.random, got, tt.expected)
+		}
+	}
+}
+
+func TestProxy_Faults(t *testing.T) {
+	body := strings.Repeat("0123456789", 100)
+
+	var upstreamRequests atomic.Int64
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {
+		upstreamRequests.Add(1)
+		w.Write([]byte(body))
+	})
+
+	tests := []struct {
+		name     string
+		rule     FaultRule
+		status   int
+		body     string
+		err      bool
+		upstream int64
+		minTime  time.Duration
+	}{
+		{
+			name:     "status code",
+			rule:     FaultRule{Probability: 1, StatusCode: http.StatusServiceUnavailable},
+			status:   http.StatusServiceUnavailable,
+			body:     "503 Service Unavailable\n",
+			upstream: 0,
+		},
+		{
+			name:     "reset",
+			rule:     FaultRule{Probability: 1, Reset: true},
+			err:      true,
+			upstream: 0,
+		},
+		{
+			name:     "truncated body",
+			rule:     FaultRule{Probability: 1, TruncateBody: true, TruncateAfter: 10},
+			status:   http.StatusOK,
+			err:      true,
+			upstream: 1,
+		},
+		{
+			name:     "latency",
+			rule:     FaultRule{Probability: 1, Latency: 200 * time.Millisecond},
+			status:   http.StatusOK,
+			body:     body,
+			upstream: 1,
+			minTime:  200 * time.Millisecond,
+		},
+		{
+			name:     "bandwidth",
+			rule:     FaultRule{Probability: 1, BytesPerSecond: 5000},
+			status:   http.StatusOK,
+			body:     body,
+			upstream: 1,
+			minTime:  200 * time.Millisecond,
+		},
+		{
+			name:     "out of scope",
+			rule:     FaultRule{Probability: 1, StatusCode: http.StatusBadGateway, Scope: []ScopeRule{{Host: "other.example.com"}}},
+			status:   http.StatusOK,
+			body:     body,
+			upstream: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamRequests.Store(0)
+
+			proxy := runTestProxy(t)
+
+			faults, err := NewFaults(tt.rule)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			proxy.SetFaults(faults)
+
+			start := time.Now()
+			resp, err := newTestClientProxy(t, proxy.URL().String()).Get(server.URL)
+			if err == nil {
+				defer resp.Body.Close()
+
+				var got []byte
+				got, err = io.ReadAll(resp.Body)
+				if resp.StatusCode != tt.status {
+					t.Errorf("response status code: got '%d', expected '%d'", resp.StatusCode, tt.status)
+				}
+
+				if err == nil && string(got) != tt.body {
+					t.Errorf("response body: got '%s', expected '%s'", got, tt.body)
+				}
+			}
+
+			if (err != nil) != tt.err {
+				t.Errorf("request error: got '%v', expected error: %v", err, tt.err)
+			}
+
+			if elapsed := time.Since(start); elapsed < tt.minTime {
+				t.Errorf("request time: got '%v', expected at least '%v'", elapsed, tt.minTime)
+			}
+
+			if got := upstreamRequests.Load(); got != tt.upstream {
+				t.Errorf("upstream requests: got '%d', expected '%d'", got, tt.upstream)
+			}
+		})
+	}
+}
+
+func TestProxy_FaultReset_ResetsClientConnection(t *testing.T) {
+	proxy := runTestProxy(t)
+
+	faults, err := NewFaults(FaultRule{Probability: 1, Reset: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	proxy.SetFaults(faults)
+
+	tlsConn := testHTTPTunnel(t, proxy)
+	tlsConn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	if _, err := tlsConn.Write([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")); err != nil {
+		t.Fatalf("could not write to tunnel: %v", err)
+	}
+
+	if _, err := tlsConn.Read(make([]byte, 1)); !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("read from reset tunnel: got '%v', expected '%v'", err, syscall.ECONNRESET)
+	}
+}
+
+func TestNewFaults_InvalidRules(t *testing.T) {
+	rules := []FaultRule{
+		{Probability: 1.5},
+		{Latency: -time.Second},
+		{BytesPerSecond: -1},
+		{StatusCode: 42},
+		{TruncateBody: true, TruncateAfter: -1},
+		{Reset: true, StatusCode: http.StatusOK},
+		{Scope: []ScopeRule{{Port: 70000}}},
+	}
+
+	for _, r := range rules {
+		if _, err := NewFaults(r); err == nil {
+			t.Errorf("invalid rule '%+v' accepted", r)
+		}
+	}
+}
+
+func TestGRPCServerFaultRules(t *testing.T) {
+	faults, err := NewFaults()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server := NewGRPCServer("127.0.0.1:0")
+	server.SetFaultService(faults)
+
+	rule := &proto.FaultRule{
+		Name:        "slow api",
+		Probability: 0.25,
+		LatencyMs:   1500,
+		Scope:       []*proto.ScopeRule{{Action: "include", Host: "api.example.com"}},
+	}
+
+	if _, err := server.SetFaultRules(context.Background(), &proto.SetFaultRulesInput{Rules: []*proto.FaultRule{rule}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := faults.Rules(); len(got) != 1 || got[0].Latency != 1500*time.Millisecond || got[0].Probability != 0.25 {
+		t.Errorf("rules: got '%+v', expected '%v'", got, rule)
+	}
+
+	out, err := server.GetFaultRules(context.Background(), &proto.GetFaultRulesInput{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(out.Rules) != 1 || out.Rules[0].Name != "slow api" || out.Rules[0].LatencyMs != 1500 {
+		t.Errorf("rules: got '%v', expected '%v'", out.Rules, rule)
+	}
+
+	invalid := &proto.FaultRule{StatusCode: 42}
+	if _, err := server.SetFaultRules(context.Background(), &proto.SetFaultRulesInput{Rules: []*proto.FaultRule{invalid}}); err == nil {
+		t.Errorf("invalid rule accepted")
+	}
+}
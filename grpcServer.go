@@ -4,22 +4,33 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/artilugio0/efincore/proto"
 	"github.com/google/uuid"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
 type GRPCServer struct {
 	*proto.UnimplementedEfinProxyServer
 
-	addr string
+	addr        string
+	stats       StatsService
+	passthrough PassthroughService
+	logger      *eventLogger
+
+	matchReplace MatchReplaceService
+	faults       FaultService
 
 	requestInClientsMutex *sync.Mutex
 	requestInClients      []chan requestData
@@ -38,6 +49,32 @@ type GRPCServer struct {
 
 	responseOutClientsMutex *sync.Mutex
 	responseOutClients      []chan responseData
+
+	errorClientsMutex *sync.Mutex
+	errorClients      []chan *ProxyError
+}
+
+// PassthroughService manages the hosts whose connections are passed
+// through without terminating TLS. It is implemented by Proxy
+type PassthroughService interface {
+	PassthroughHosts() []PassthroughHost
+	ResetPassthroughHost(host string)
+	PinnedHosts() []string
+	SetPinnedHosts(patterns []string) error
+}
+
+// MatchReplaceService manages match and replace rules. It is implemented
+// by MatchReplace
+type MatchReplaceService interface {
+	Rules() []MatchReplaceRule
+	SetRules(rules ...MatchReplaceRule) error
+}
+
+// FaultService manages the faults injected in requests. It is implemented
+// by Faults
+type FaultService interface {
+	Rules() []FaultRule
+	SetRules(rules ...FaultRule) error
 }
 
 type requestData struct {
@@ -62,9 +99,13 @@ type responseModData struct {
 	wg *sync.WaitGroup
 }
 
+// NewGRPCServer returns a gRPC server with its own stats. Use
+// Proxy.NewGRPCServer, or SetStatsService, to report the stats of a proxy
 func NewGRPCServer(addr string) *GRPCServer {
 	return &GRPCServer{
-		addr: addr,
+		addr:   addr,
+		stats:  NewStatsService(),
+		logger: newEventLogger(),
 
 		requestInClientsMutex:  &sync.Mutex{},
 		requestModClientsMutex: &sync.Mutex{},
@@ -73,7 +114,66 @@ func NewGRPCServer(addr string) *GRPCServer {
 		responseInClientsMutex:  &sync.Mutex{},
 		responseModClientsMutex: &sync.Mutex{},
 		responseOutClientsMutex: &sync.Mutex{},
+
+		errorClientsMutex: &sync.Mutex{},
+	}
+}
+
+// SetStatsService makes the server report and update the given stats,
+// usually the ones returned by Proxy.StatsService
+func (s *GRPCServer) SetStatsService(stats StatsService) {
+	s.stats = stats
+}
+
+// SetPassthroughService makes the server list and update the passthrough
+// hosts of a proxy
+func (s *GRPCServer) SetPassthroughService(p PassthroughService) {
+	s.passthrough = p
+}
+
+// SetMatchReplaceService makes the server list and update the given match
+// and replace rules
+func (s *GRPCServer) SetMatchReplaceService(mr MatchReplaceService) {
+	s.matchReplace = mr
+}
+
+// SetFaultService makes the server list and update the given fault rules,
+// so that faults can be toggled while clients are tested
+func (s *GRPCServer) SetFaultService(f FaultService) {
+	s.faults = f
+}
+
+// SetLogger sets the logger of the server. By default slog.Default() is
+// used; nil discards every record
+func (s *GRPCServer) SetLogger(logger *slog.Logger) {
+	s.logger.setLogger(logger)
+}
+
+// SubscribeErrors returns a channel receiving an event for every error
+// logged by the server, and a function to unsubscribe
+func (s *GRPCServer) SubscribeErrors() (<-chan ErrorEvent, func()) {
+	return s.logger.subscribe()
+}
+
+// Metrics implements MetricsSource, reporting the number of clients
+// subscribed to each stream
+func (s *GRPCServer) Metrics() Stats {
+	subscribers := map[string]int{
+		"requests-in":   len(s.getRequestInClients()),
+		"requests-mod":  len(s.getRequestModClients()),
+		"requests-out":  len(s.getRequestOutClients()),
+		"responses-in":  len(s.getResponseInClients()),
+		"responses-mod": len(s.getResponseModClients()),
+		"responses-out": len(s.getResponseOutClients()),
+		"errors":        len(s.getErrorClients()),
+	}
+
+	result := Stats{}
+	for stream, n := range subscribers {
+		result[LabeledStat(StatGRPCSubscribers, stream)] = n
 	}
+
+	return result
 }
 
 func (s *GRPCServer) RequestInHook(r *http.Request, id uuid.UUID) error {
@@ -209,6 +309,25 @@ func (s *GRPCServer) ResponseOutHook(r *http.Response, id uuid.UUID) error {
 	return group.Wait()
 }
 
+// ErrorHook sends the errors of the proxy to the clients of GetErrors
+func (s *GRPCServer) ErrorHook(e *ProxyError) error {
+	group := errgroup.Group{}
+
+	for _, c := range s.getErrorClients() {
+		thisC := c
+		group.Go(func() error {
+			// recover if the channel was closed and this function
+			// writes to it
+			defer func() { recover() }()
+
+			thisC <- e
+			return nil
+		})
+	}
+
+	return group.Wait()
+}
+
 func (s *GRPCServer) getRequestInClients() []chan requestData {
 	s.requestInClientsMutex.Lock()
 	defer s.requestInClientsMutex.Unlock()
@@ -437,9 +556,47 @@ func (s *GRPCServer) removeResponseModClient(cRemove <-chan responseModData) {
 	s.responseModClients = newResponseModClients
 }
 
+func (s *GRPCServer) getErrorClients() []chan *ProxyError {
+	s.errorClientsMutex.Lock()
+	defer s.errorClientsMutex.Unlock()
+
+	result := make([]chan *ProxyError, len(s.errorClients))
+	for i, c := range s.errorClients {
+		result[i] = c
+	}
+
+	return result
+}
+
+func (s *GRPCServer) addErrorClient() <-chan *ProxyError {
+	s.errorClientsMutex.Lock()
+	defer s.errorClientsMutex.Unlock()
+
+	c := make(chan *ProxyError)
+	s.errorClients = append(s.errorClients, c)
+
+	return c
+}
+
+func (s *GRPCServer) removeErrorClient(cRemove <-chan *ProxyError) {
+	s.errorClientsMutex.Lock()
+	defer s.errorClientsMutex.Unlock()
+
+	newErrorClients := []chan *ProxyError{}
+	for _, c := range s.errorClients {
+		if c == cRemove {
+			close(c)
+			continue
+		}
+		newErrorClients = append(newErrorClients, c)
+	}
+
+	s.errorClients = newErrorClients
+}
+
 // GRPC server implementation
 func (s *GRPCServer) GetStats(context.Context, *proto.GetStatsInput) (*proto.GetStatsOutput, error) {
-	stats := GetStatsService().Get()
+	stats := s.stats.Get()
 	result := &proto.GetStatsOutput{}
 
 	for k, v := range stats {
@@ -452,6 +609,18 @@ func (s *GRPCServer) GetStats(context.Context, *proto.GetStatsInput) (*proto.Get
 	return result, nil
 }
 
+func (s *GRPCServer) WatchStats(in *proto.WatchStatsInput, stream proto.EfinProxy_WatchStatsServer) error {
+	interval := time.Duration(in.IntervalMs) * time.Millisecond
+
+	for snapshot := range WatchStats(stream.Context(), s.stats, interval, in.OnChange) {
+		if err := stream.Send(toProtoStatsUpdate(snapshot)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (s *GRPCServer) GetRequestsIn(_ *proto.GetRequestsInInput, stream proto.EfinProxy_GetRequestsInServer) error {
 	c := s.addRequestInClient()
 	defer s.removeRequestInClient(c)
@@ -617,6 +786,296 @@ func (s *GRPCServer) GetResponsesOut(_ *proto.GetResponsesOutInput, stream proto
 	return nil
 }
 
+func (s *GRPCServer) GetErrors(_ *proto.GetErrorsInput, stream proto.EfinProxy_GetErrorsServer) error {
+	c := s.addErrorClient()
+	defer s.removeErrorClient(c)
+
+	for {
+		select {
+		case e, ok := <-c:
+			if !ok {
+				return nil
+			}
+
+			if err := stream.Send(toProtoProxyError(e)); err != nil {
+				return err
+			}
+
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (s *GRPCServer) GetPassthroughHosts(context.Context, *proto.GetPassthroughHostsInput) (*proto.GetPassthroughHostsOutput, error) {
+	if s.passthrough == nil {
+		return nil, status.Error(codes.Unimplemented, "passthrough service not set")
+	}
+
+	result := &proto.GetPassthroughHostsOutput{
+		PinnedHosts: s.passthrough.PinnedHosts(),
+	}
+
+	for _, h := range s.passthrough.PassthroughHosts() {
+		result.Hosts = append(result.Hosts, &proto.PassthroughHost{
+			Host:          h.Host,
+			Failures:      uint32(h.Failures),
+			LastFailureMs: h.LastFailure.UnixMilli(),
+			LastError:     h.LastError,
+			PassedThrough: h.PassedThrough,
+		})
+	}
+
+	return result, nil
+}
+
+func (s *GRPCServer) ResetPassthroughHost(_ context.Context, in *proto.ResetPassthroughHostInput) (*proto.ResetPassthroughHostOutput, error) {
+	if s.passthrough == nil {
+		return nil, status.Error(codes.Unimplemented, "passthrough service not set")
+	}
+
+	s.passthrough.ResetPassthroughHost(in.Host)
+	return &proto.ResetPassthroughHostOutput{}, nil
+}
+
+func (s *GRPCServer) SetPinnedHosts(_ context.Context, in *proto.SetPinnedHostsInput) (*proto.SetPinnedHostsOutput, error) {
+	if s.passthrough == nil {
+		return nil, status.Error(codes.Unimplemented, "passthrough service not set")
+	}
+
+	if err := s.passthrough.SetPinnedHosts(in.Patterns); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &proto.SetPinnedHostsOutput{}, nil
+}
+
+func (s *GRPCServer) GetMatchReplaceRules(context.Context, *proto.GetMatchReplaceRulesInput) (*proto.GetMatchReplaceRulesOutput, error) {
+	if s.matchReplace == nil {
+		return nil, status.Error(codes.Unimplemented, "match and replace service not set")
+	}
+
+	result := &proto.GetMatchReplaceRulesOutput{}
+	for _, r := range s.matchReplace.Rules() {
+		result.Rules = append(result.Rules, toProtoMatchReplaceRule(r))
+	}
+
+	return result, nil
+}
+
+func (s *GRPCServer) SetMatchReplaceRules(_ context.Context, in *proto.SetMatchReplaceRulesInput) (*proto.SetMatchReplaceRulesOutput, error) {
+	if s.matchReplace == nil {
+		return nil, status.Error(codes.Unimplemented, "match and replace service not set")
+	}
+
+	rules := []MatchReplaceRule{}
+	for _, r := range in.Rules {
+		rule, err := fromProtoMatchReplaceRule(r)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		rules = append(rules, rule)
+	}
+
+	if err := s.matchReplace.SetRules(rules...); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &proto.SetMatchReplaceRulesOutput{}, nil
+}
+
+func (s *GRPCServer) GetFaultRules(context.Context, *proto.GetFaultRulesInput) (*proto.GetFaultRulesOutput, error) {
+	if s.faults == nil {
+		return nil, status.Error(codes.Unimplemented, "fault service not set")
+	}
+
+	result := &proto.GetFaultRulesOutput{}
+	for _, r := range s.faults.Rules() {
+		result.Rules = append(result.Rules, toProtoFaultRule(r))
+	}
+
+	return result, nil
+}
+
+func (s *GRPCServer) SetFaultRules(_ context.Context, in *proto.SetFaultRulesInput) (*proto.SetFaultRulesOutput, error) {
+	if s.faults == nil {
+		return nil, status.Error(codes.Unimplemented, "fault service not set")
+	}
+
+	rules := []FaultRule{}
+	for _, r := range in.Rules {
+		rule, err := fromProtoFaultRule(r)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		rules = append(rules, rule)
+	}
+
+	if err := s.faults.SetRules(rules...); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &proto.SetFaultRulesOutput{}, nil
+}
+
+func toProtoFaultRule(r FaultRule) *proto.FaultRule {
+	result := &proto.FaultRule{
+		Name:           r.Name,
+		Disabled:       r.Disabled,
+		Probability:    r.Probability,
+		LatencyMs:      r.Latency.Milliseconds(),
+		BytesPerSecond: int64(r.BytesPerSecond),
+		Reset_:         r.Reset,
+		StatusCode:     uint32(r.StatusCode),
+		TruncateBody:   r.TruncateBody,
+		TruncateAfter:  int64(r.TruncateAfter),
+	}
+
+	for _, sr := range r.Scope {
+		result.Scope = append(result.Scope, toProtoScopeRule(sr))
+	}
+
+	return result
+}
+
+func fromProtoFaultRule(r *proto.FaultRule) (FaultRule, error) {
+	result := FaultRule{
+		Name:           r.Name,
+		Disabled:       r.Disabled,
+		Probability:    r.Probability,
+		Latency:        time.Duration(r.LatencyMs) * time.Millisecond,
+		BytesPerSecond: int(r.BytesPerSecond),
+		Reset:          r.Reset_,
+		StatusCode:     int(r.StatusCode),
+		TruncateBody:   r.TruncateBody,
+		TruncateAfter:  int(r.TruncateAfter),
+	}
+
+	for _, sr := range r.Scope {
+		scopeRule, err := fromProtoScopeRule(sr)
+		if err != nil {
+			return result, err
+		}
+		result.Scope = append(result.Scope, scopeRule)
+	}
+
+	return result, nil
+}
+
+func toProtoMatchReplaceRule(r MatchReplaceRule) *proto.MatchReplaceRule {
+	result := &proto.MatchReplaceRule{
+		Name:     r.Name,
+		Disabled: r.Disabled,
+		Target:   string(r.Target),
+		Action:   string(r.Action),
+		Header:   r.Header,
+		Match:    r.Match,
+		Replace:  r.Replace,
+	}
+
+	for _, sr := range r.Scope {
+		result.Scope = append(result.Scope, toProtoScopeRule(sr))
+	}
+
+	return result
+}
+
+func fromProtoMatchReplaceRule(r *proto.MatchReplaceRule) (MatchReplaceRule, error) {
+	result := MatchReplaceRule{
+		Name:     r.Name,
+		Disabled: r.Disabled,
+		Target:   MatchReplaceTarget(r.Target),
+		Action:   MatchReplaceAction(r.Action),
+		Header:   r.Header,
+		Match:    r.Match,
+		Replace:  r.Replace,
+	}
+
+	for _, sr := range r.Scope {
+		scopeRule, err := fromProtoScopeRule(sr)
+		if err != nil {
+			return result, err
+		}
+		result.Scope = append(result.Scope, scopeRule)
+	}
+
+	return result, nil
+}
+
+func toProtoScopeRule(r ScopeRule) *proto.ScopeRule {
+	return &proto.ScopeRule{
+		Action: r.Action.String(),
+		Scheme: r.Scheme,
+		Host:   r.Host,
+		Port:   uint32(r.Port),
+		Path:   r.Path,
+		Method: r.Method,
+	}
+}
+
+func fromProtoScopeRule(r *proto.ScopeRule) (ScopeRule, error) {
+	var action ScopeAction
+	if err := action.UnmarshalText([]byte(r.Action)); err != nil {
+		return ScopeRule{}, err
+	}
+
+	return ScopeRule{
+		Action: action,
+		Scheme: r.Scheme,
+		Host:   r.Host,
+		Port:   int(r.Port),
+		Path:   r.Path,
+		Method: r.Method,
+	}, nil
+}
+
+func toProtoProxyError(e *ProxyError) *proto.ProxyError {
+	result := &proto.ProxyError{
+		Kind:       string(e.Kind),
+		Host:       e.Host,
+		Message:    e.Error(),
+		StatusCode: uint32(e.StatusCode()),
+	}
+
+	if e.RequestID != uuid.Nil {
+		result.RequestId = e.RequestID.String()
+	}
+
+	return result
+}
+
+func toProtoStatsUpdate(snapshot StatsSnapshot) *proto.StatsUpdate {
+	result := &proto.StatsUpdate{
+		TimestampMs: snapshot.Time.UnixMilli(),
+	}
+
+	names := make([]string, 0, len(snapshot.Stats))
+	for k := range snapshot.Stats {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		result.Stats = append(result.Stats, &proto.Stat{
+			Name:  k,
+			Value: int64(snapshot.Stats[k]),
+		})
+
+		rate, ok := snapshot.Rates[k]
+		if !ok {
+			continue
+		}
+
+		result.Rates = append(result.Rates, &proto.StatRate{
+			Name:      k,
+			Delta:     int64(rate.Delta),
+			PerSecond: rate.PerSecond,
+		})
+	}
+
+	return result
+}
+
 func toProtoRequest(r *http.Request, id uuid.UUID) (*proto.Request, error) {
 	headers := []*proto.Header{}
 	for h, vs := range r.Header {
@@ -675,11 +1134,36 @@ func (s *GRPCServer) Run() error {
 		return err
 	}
 
-	var opts []grpc.ServerOption
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(s.logUnary),
+		grpc.StreamInterceptor(s.logStream),
+	}
 	grpcServer := grpc.NewServer(opts...)
 
 	proto.RegisterEfinProxyServer(grpcServer, s)
 	reflection.Register(grpcServer)
 
+	s.logger.Info("grpc server listening", slog.String("addr", lis.Addr().String()))
 	return grpcServer.Serve(lis)
 }
+
+func (s *GRPCServer) logUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		s.logger.Error("grpc call failed", errAttr(err), slog.String("method", info.FullMethod))
+	}
+
+	return resp, err
+}
+
+func (s *GRPCServer) logStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	s.logger.Debug("grpc client subscribed", slog.String("method", info.FullMethod))
+
+	err := handler(srv, ss)
+	if err != nil && ss.Context().Err() == nil {
+		s.logger.Error("grpc stream failed", errAttr(err), slog.String("method", info.FullMethod))
+	}
+
+	s.logger.Debug("grpc client unsubscribed", slog.String("method", info.FullMethod))
+	return err
+}
@@ -0,0 +1,81 @@
+package efincore
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// orderHookEntries returns the entries sorted by priority (lower values
+// run first) and registration order, while honoring the before and after
+// constraints between hooks. Constraints that form a cycle are ignored.
+func orderHookEntries[H any](entries []hookEntry[H]) []hookEntry[H] {
+	n := len(entries)
+	if n < 2 {
+		return entries
+	}
+
+	// edges[i] contains the entries that must run after entry i
+	edges := make([][]int, n)
+	inDegree := make([]int, n)
+	addEdge := func(from, to int) {
+		if from == to || slices.Contains(edges[from], to) {
+			return
+		}
+		edges[from] = append(edges[from], to)
+		inDegree[to]++
+	}
+
+	for i, e := range entries {
+		for j, other := range entries {
+			if slices.Contains(e.before, other.name) {
+				addEdge(i, j)
+			}
+			if slices.Contains(e.after, other.name) {
+				addEdge(j, i)
+			}
+		}
+	}
+
+	less := func(a, b int) bool {
+		if entries[a].priority != entries[b].priority {
+			return entries[a].priority < entries[b].priority
+		}
+		return entries[a].id < entries[b].id
+	}
+
+	result := make([]hookEntry[H], 0, n)
+	done := make([]bool, n)
+	for len(result) < n {
+		next := -1
+		for i := range entries {
+			if done[i] || inDegree[i] > 0 {
+				continue
+			}
+			if next == -1 || less(i, next) {
+				next = i
+			}
+		}
+
+		if next == -1 {
+			// there is a cycle, pick the first pending entry ignoring
+			// its constraints
+			for i := range entries {
+				if done[i] {
+					continue
+				}
+				if next == -1 || less(i, next) {
+					next = i
+				}
+			}
+			entries[next].logger.Warn("hook ordering constraints form a cycle, ignoring them", slog.String(LogKeyHook, entries[next].name))
+		}
+
+		done[next] = true
+		result = append(result, entries[next])
+		for _, j := range edges[next] {
+			inDegree[j]--
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,104 @@
+package efincore
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestOrderHookEntries(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     [][]RegisterOption
+		expected []string
+	}{
+		{
+			name: "registration order",
+			opts: [][]RegisterOption{
+				{WithName("a")},
+				{WithName("b")},
+				{WithName("c")},
+			},
+			expected: []string{"a", "b", "c"},
+		},
+		{
+			name: "priorities",
+			opts: [][]RegisterOption{
+				{WithName("a"), WithPriority(10)},
+				{WithName("b"), WithPriority(-5)},
+				{WithName("c")},
+			},
+			expected: []string{"b", "c", "a"},
+		},
+		{
+			name: "before and after",
+			opts: [][]RegisterOption{
+				{WithName("a"), WithAfter("c")},
+				{WithName("b")},
+				{WithName("c"), WithPriority(10)},
+				{WithName("d"), WithBefore("b"), WithPriority(20)},
+			},
+			expected: []string{"c", "a", "d", "b"},
+		},
+		{
+			name: "cycle",
+			opts: [][]RegisterOption{
+				{WithName("a"), WithAfter("b")},
+				{WithName("b"), WithAfter("a")},
+				{WithName("c"), WithPriority(-1)},
+			},
+			expected: []string{"c", "a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		var h *hooks
+		for _, opts := range tt.opts {
+			h = h.AddRequestModHook(HookRequestModFunc(func(*http.Request, uuid.UUID) error { return nil }), newRegistration(nil, opts))
+		}
+
+		got := []string{}
+		for _, info := range h.List() {
+			got = append(got, info.Name)
+		}
+
+		if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("%s: got '%v', expected '%v'", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestProxyPipeline(t *testing.T) {
+	proxy := NewProxy("127.0.0.1:0")
+
+	proxy.AddRequestModHook(HookRequestModFunc(func(*http.Request, uuid.UUID) error { return nil }), WithName("second"))
+	proxy.AddRequestModHook(HookRequestModFunc(func(*http.Request, uuid.UUID) error { return nil }), WithName("first"), WithBefore("second"))
+	proxy.AddResponseInHook(HookResponseReadFunc(func(*http.Response, uuid.UUID) error { return nil }), WithName("logger"), WithDisabled())
+
+	r, err := http.NewRequest(http.MethodGet, "https://www.example.com/", nil)
+	if err != nil {
+		t.Fatalf("could not create request: %v", err)
+	}
+
+	pipeline := proxy.Pipeline(r)
+	if !pipeline.InterceptRequest {
+		t.Fatalf("expected request to be intercepted")
+	}
+
+	mods := pipeline.Stages[HookKindRequestMod]
+	if len(mods) != 2 || mods[0].Name != "first" || mods[1].Name != "second" {
+		t.Errorf("unexpected request mod stage: %v", mods)
+	}
+
+	out := pipeline.String()
+	if !strings.Contains(out, "'logger'") || !strings.Contains(out, "disabled") {
+		t.Errorf("expected disabled logger hook in pipeline description, got:\n%s", out)
+	}
+
+	proxy.AddRequestFilter(RequestFilterFunc(func(*http.Request) bool { return false }), WithName("none"))
+	if proxy.Pipeline(r).InterceptRequest {
+		t.Errorf("expected request not to be intercepted")
+	}
+}
@@ -0,0 +1,138 @@
+package efincore
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// HookOrdering decides if the events of a read hook are delivered in
+// sequence with the events of the other ordered read hooks
+type HookOrdering int
+
+const (
+	// HookOrderNone delivers events as soon as a worker is available
+	HookOrderNone HookOrdering = iota
+
+	// HookOrderPerExchange delivers the events of a request and its
+	// response in order: request in, request out, response in and
+	// response out
+	HookOrderPerExchange
+
+	// HookOrderPerConnection delivers every event of a client connection
+	// in order, including events of different exchanges
+	HookOrderPerConnection
+)
+
+func (o HookOrdering) String() string {
+	switch o {
+	case HookOrderNone:
+		return "none"
+	case HookOrderPerExchange:
+		return "per-exchange"
+	case HookOrderPerConnection:
+		return "per-connection"
+	}
+
+	return "unknown"
+}
+
+// WithOrdering sets the delivery ordering guarantee of a read hook. Events
+// of ordered hooks wait for the previous events of the same exchange or
+// connection to be processed
+func WithOrdering(ordering HookOrdering) RegisterOption {
+	return func(r *registration) {
+		r.ordering = ordering
+	}
+}
+
+type connectionIDKey struct{}
+
+func withConnectionID(ctx context.Context, id uuid.UUID) context.Context {
+	return context.WithValue(ctx, connectionIDKey{}, id)
+}
+
+// ConnectionID returns the ID of the client connection a request was
+// received from
+func ConnectionID(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(connectionIDKey{}).(uuid.UUID)
+	return id, ok
+}
+
+// hookSequencer chains the events sharing the same key, so that each one
+// runs after the previous one finished
+type hookSequencer struct {
+	mutex *sync.Mutex
+	last  map[uuid.UUID]chan struct{}
+}
+
+var readHookSequencer = newHookSequencer()
+
+func newHookSequencer() *hookSequencer {
+	return &hookSequencer{
+		mutex: &sync.Mutex{},
+		last:  map[uuid.UUID]chan struct{}{},
+	}
+}
+
+// next returns a channel that is closed when the previous event with the
+// same key is done (nil if there is none), and a function to be called
+// when the new event is done
+func (s *hookSequencer) next(key uuid.UUID) (<-chan struct{}, func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	prev := s.last[key]
+	current := make(chan struct{})
+	s.last[key] = current
+
+	done := func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		close(current)
+		if s.last[key] == current {
+			delete(s.last, key)
+		}
+	}
+
+	return prev, done
+}
+
+// dispatchOrdered queues an event of a read hook taking into account its
+// ordering guarantee
+func (r *registration) dispatchOrdered(ctx context.Context, exchangeID uuid.UUID, event func()) {
+	if r.ordering == HookOrderNone {
+		r.dispatch(event)
+		return
+	}
+
+	key := exchangeID
+	if connID, ok := ConnectionID(ctx); ok && r.ordering == HookOrderPerConnection {
+		key = connID
+	}
+
+	wait, done := readHookSequencer.next(key)
+	waitPrevious := func() {
+		if wait != nil {
+			<-wait
+		}
+	}
+
+	r.queue.enqueue(hookEvent{
+		run: func() {
+			waitPrevious()
+			defer done()
+
+			event()
+		},
+		cancel: func() {
+			// keep the chain ordered even if this event was dropped
+			go func() {
+				waitPrevious()
+				done()
+			}()
+		},
+	})
+}
@@ -0,0 +1,177 @@
+package efincore
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// eventRecorder records the events of read hooks. The events of gated
+// hooks are held until release is closed, so that they would be processed
+// after the other events if nothing ordered them
+type eventRecorder struct {
+	mutex  sync.Mutex
+	events []string
+
+	recorded chan struct{}
+	release  chan struct{}
+}
+
+func newEventRecorder() *eventRecorder {
+	return &eventRecorder{
+		recorded: make(chan struct{}, 100),
+		release:  make(chan struct{}),
+	}
+}
+
+func (er *eventRecorder) record(event string, gated bool) {
+	if gated {
+		<-er.release
+	}
+
+	er.mutex.Lock()
+	er.events = append(er.events, event)
+	er.mutex.Unlock()
+
+	er.recorded <- struct{}{}
+}
+
+// wait waits for n more events to be recorded
+func (er *eventRecorder) wait(t *testing.T, n int) {
+	t.Helper()
+
+	for range n {
+		select {
+		case <-er.recorded:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("events not recorded, got '%s'", er.String())
+		}
+	}
+}
+
+func (er *eventRecorder) String() string {
+	er.mutex.Lock()
+	defer er.mutex.Unlock()
+
+	return strings.Join(er.events, ",")
+}
+
+func addRecorderHooks(proxy *Proxy, er *eventRecorder, opts ...RegisterOption) {
+	proxy.AddRequestInHook(HookRequestReadFunc(func(r *http.Request, id uuid.UUID) error {
+		er.record("req-in "+r.URL.Path, true)
+		return nil
+	}), opts...)
+
+	proxy.AddRequestOutHook(HookRequestReadFunc(func(r *http.Request, id uuid.UUID) error {
+		er.record("req-out "+r.URL.Path, false)
+		return nil
+	}), opts...)
+
+	proxy.AddResponseInHook(HookResponseReadFunc(func(r *http.Response, id uuid.UUID) error {
+		er.record("resp-in "+r.Request.URL.Path, true)
+		return nil
+	}), opts...)
+
+	proxy.AddResponseOutHook(HookResponseReadFunc(func(r *http.Response, id uuid.UUID) error {
+		er.record("resp-out "+r.Request.URL.Path, false)
+		return nil
+	}), opts...)
+}
+
+func TestReadHooks_Unordered_EventsCanBeReordered(t *testing.T) {
+	proxy := runTestProxy(t)
+
+	er := newEventRecorder()
+	addRecorderHooks(proxy, er)
+
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {})
+	client := newTestClientProxy(t, proxy.URL().String())
+
+	if _, err := client.Get(server.URL + "/a"); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	// the events of hooks that are not gated do not wait for the others
+	er.wait(t, 1)
+	close(er.release)
+	er.wait(t, 3)
+
+	if strings.HasPrefix(er.String(), "req-in") {
+		t.Errorf("expected gated request in hook not to be the first one, got '%s'", er.String())
+	}
+}
+
+func TestReadHooks_OrderedPerExchange(t *testing.T) {
+	proxy := runTestProxy(t)
+
+	er := newEventRecorder()
+	addRecorderHooks(proxy, er, WithOrdering(HookOrderPerExchange))
+
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {})
+	client := newTestClientProxy(t, proxy.URL().String())
+
+	if _, err := client.Get(server.URL + "/a"); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	close(er.release)
+	er.wait(t, 4)
+
+	expected := "req-in /a,req-out /a,resp-in /a,resp-out /a"
+	if er.String() != expected {
+		t.Errorf("got '%s', expected '%s'", er.String(), expected)
+	}
+}
+
+func TestReadHooks_OrderedPerConnection(t *testing.T) {
+	proxy := runTestProxy(t)
+
+	er := newEventRecorder()
+	addRecorderHooks(proxy, er, WithOrdering(HookOrderPerConnection))
+
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {})
+	client := newTestClientProxy(t, proxy.URL().String())
+
+	for _, path := range []string{"/a", "/b"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+	close(er.release)
+	er.wait(t, 8)
+
+	expected := "req-in /a,req-out /a,resp-in /a,resp-out /a,req-in /b,req-out /b,resp-in /b,resp-out /b"
+	if er.String() != expected {
+		t.Errorf("got '%s', expected '%s'", er.String(), expected)
+	}
+}
+
+func TestHookSequencer_ChainsEventsWithTheSameKey(t *testing.T) {
+	s := newHookSequencer()
+	key := uuid.New()
+
+	_, done1 := s.next(key)
+	wait2, done2 := s.next(key)
+	wait3, done3 := s.next(key)
+
+	select {
+	case <-wait2:
+		t.Fatalf("expected second event to wait for the first one")
+	default:
+	}
+
+	done1()
+	<-wait2
+	done2()
+	<-wait3
+	done3()
+
+	if len(s.last) != 0 {
+		t.Errorf("expected sequencer to be empty, got %d keys", len(s.last))
+	}
+}
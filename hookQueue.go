@@ -0,0 +1,199 @@
+package efincore
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	DefaultHookQueueDepth = 1024
+	DefaultHookWorkers    = 4
+)
+
+// QueueFullPolicy decides what happens to the events of a read hook when
+// its queue is full. The default, QueueDropNewest, never slows down the
+// proxy; the first event dropped by each hook is logged
+type QueueFullPolicy int
+
+const (
+	QueueDropNewest QueueFullPolicy = iota
+	QueueDropOldest
+	QueueBlock
+)
+
+func (p QueueFullPolicy) String() string {
+	switch p {
+	case QueueDropNewest:
+		return "drop-newest"
+	case QueueDropOldest:
+		return "drop-oldest"
+	case QueueBlock:
+		return "block"
+	}
+
+	return "unknown"
+}
+
+// WithQueue sets the queue depth of a read hook and the behaviour when the
+// queue is full
+func WithQueue(depth int, policy QueueFullPolicy) RegisterOption {
+	return func(r *registration) {
+		r.queueDepth = depth
+		r.queuePolicy = policy
+	}
+}
+
+// WithWorkers sets the number of goroutines processing the events of a
+// read hook
+func WithWorkers(workers int) RegisterOption {
+	return func(r *registration) {
+		r.workers = workers
+	}
+}
+
+func (r *registration) startQueue() {
+	if r.queue == nil {
+		r.queue = newHookQueue(r.queueDepth, r.queuePolicy, r.workers, r.stats, r.logger.with(slog.String(LogKeyHook, r.name)))
+	}
+}
+
+func (r *registration) dispatch(event func()) {
+	r.queue.enqueue(hookEvent{run: event})
+}
+
+// hookEvent is a unit of work of a read hook. cancel is called, if set,
+// when the event is dropped from the queue
+type hookEvent struct {
+	run    func()
+	cancel func()
+}
+
+// hookQueue runs the events of a read hook in a bounded pool of workers
+type hookQueue struct {
+	events chan hookEvent
+	policy QueueFullPolicy
+
+	mutex  *sync.RWMutex
+	closed bool
+
+	dropped *atomic.Int64
+	stats   StatsService
+	logger  *eventLogger
+}
+
+func newHookQueue(depth int, policy QueueFullPolicy, workers int, stats StatsService, logger *eventLogger) *hookQueue {
+	if depth < 0 {
+		depth = 0
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	q := &hookQueue{
+		events:  make(chan hookEvent, depth),
+		policy:  policy,
+		mutex:   &sync.RWMutex{},
+		dropped: &atomic.Int64{},
+		stats:   stats,
+		logger:  logger,
+	}
+
+	for range workers {
+		go q.work()
+	}
+
+	return q
+}
+
+func (q *hookQueue) work() {
+	for event := range q.events {
+		event.run()
+	}
+}
+
+// enqueue adds an event to the queue applying the queue policy. It
+// returns false if the event or an older one was dropped
+func (q *hookQueue) enqueue(event hookEvent) bool {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	if q.closed {
+		event.dropped()
+		return false
+	}
+
+	select {
+	case q.events <- event:
+		return true
+	default:
+	}
+
+	switch q.policy {
+	case QueueBlock:
+		q.events <- event
+		return true
+
+	case QueueDropOldest:
+		if cap(q.events) == 0 {
+			// nothing to drop in unbuffered queues
+			break
+		}
+
+		for {
+			select {
+			case oldest := <-q.events:
+				q.drop(oldest)
+			default:
+			}
+
+			select {
+			case q.events <- event:
+				return false
+			default:
+			}
+		}
+	}
+
+	q.drop(event)
+	return false
+}
+
+func (q *hookQueue) drop(event hookEvent) {
+	if q.dropped.Add(1) == 1 {
+		q.logger.Warn("hook queue full, dropping events", slog.String("policy", q.policy.String()), slog.Int("depth", cap(q.events)))
+	}
+	q.stats.Increase(StatDroppedHookEvents)
+	event.dropped()
+}
+
+func (e hookEvent) dropped() {
+	if e.cancel != nil {
+		e.cancel()
+	}
+}
+
+func (q *hookQueue) close() {
+	if q == nil {
+		return
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return
+	}
+
+	q.closed = true
+	close(q.events)
+}
+
+func (q *hookQueue) droppedEvents() int64 {
+	if q == nil {
+		return 0
+	}
+
+	return q.dropped.Load()
+}
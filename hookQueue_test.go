@@ -0,0 +1,145 @@
+package efincore
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newBlockedTestQueue returns a queue whose only worker is blocked until
+// the returned channel is closed
+func newBlockedTestQueue(t *testing.T, depth int, policy QueueFullPolicy) (*hookQueue, chan struct{}) {
+	t.Helper()
+
+	q := newHookQueue(depth, policy, 1, discardStats, nil)
+	t.Cleanup(q.close)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	q.enqueue(hookEvent{run: func() {
+		close(started)
+		<-release
+	}})
+	<-started
+
+	return q, release
+}
+
+func TestHookQueue_DropNewest(t *testing.T) {
+	q, release := newBlockedTestQueue(t, 1, QueueDropNewest)
+
+	var mutex sync.Mutex
+	got := []int{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	for i := range 3 {
+		q.enqueue(hookEvent{run: func() {
+			mutex.Lock()
+			got = append(got, i)
+			mutex.Unlock()
+			wg.Done()
+		}})
+	}
+	close(release)
+	wg.Wait()
+
+	if len(got) != 1 || got[0] != 0 {
+		t.Errorf("processed events: got '%v', expected '%v'", got, []int{0})
+	}
+
+	if q.droppedEvents() != 2 {
+		t.Errorf("dropped events: got '%d', expected '%d'", q.droppedEvents(), 2)
+	}
+}
+
+func TestHookQueue_DropOldest(t *testing.T) {
+	q, release := newBlockedTestQueue(t, 1, QueueDropOldest)
+
+	var mutex sync.Mutex
+	got := []int{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	for i := range 3 {
+		q.enqueue(hookEvent{run: func() {
+			mutex.Lock()
+			got = append(got, i)
+			mutex.Unlock()
+			wg.Done()
+		}})
+	}
+	close(release)
+	wg.Wait()
+
+	if len(got) != 1 || got[0] != 2 {
+		t.Errorf("processed events: got '%v', expected '%v'", got, []int{2})
+	}
+
+	if q.droppedEvents() != 2 {
+		t.Errorf("dropped events: got '%d', expected '%d'", q.droppedEvents(), 2)
+	}
+}
+
+func TestHookQueue_Block(t *testing.T) {
+	q, release := newBlockedTestQueue(t, 1, QueueBlock)
+
+	q.enqueue(hookEvent{run: func() {}})
+
+	enqueued := make(chan struct{})
+	go func() {
+		q.enqueue(hookEvent{run: func() {}})
+		close(enqueued)
+	}()
+
+	select {
+	case <-enqueued:
+		t.Fatalf("expected enqueue to block while the queue is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+
+	select {
+	case <-enqueued:
+	case <-time.After(time.Second):
+		t.Fatalf("expected enqueue to finish after the queue was drained")
+	}
+
+	if q.droppedEvents() != 0 {
+		t.Errorf("dropped events: got '%d', expected '%d'", q.droppedEvents(), 0)
+	}
+}
+
+func TestHookQueue_EnqueueAfterClose_IsIgnored(t *testing.T) {
+	q := newHookQueue(1, QueueBlock, 1, discardStats, nil)
+	q.close()
+
+	if q.enqueue(hookEvent{run: func() {}}) {
+		t.Errorf("expected enqueue to fail on a closed queue")
+	}
+}
+
+func TestHookQueue_FirstDrop_IsLogged(t *testing.T) {
+	q, release := newBlockedTestQueue(t, 1, QueueDropNewest)
+	defer close(release)
+
+	buf := &syncBuffer{}
+	q.logger = newEventLogger().with(slog.String(LogKeyHook, "slow-sink"))
+	q.logger.setLogger(slog.New(slog.NewTextHandler(buf, nil)))
+
+	for range 4 {
+		q.enqueue(hookEvent{run: func() {}})
+	}
+
+	got := buf.String()
+	if n := strings.Count(got, "hook queue full"); n != 1 {
+		t.Errorf("logged drops: got '%d', expected '%d'", n, 1)
+	}
+
+	if !strings.Contains(got, "hook=slow-sink policy=drop-newest depth=1") {
+		t.Errorf("log: got '%s', expected the hook, policy and depth", got)
+	}
+}
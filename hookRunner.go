@@ -0,0 +1,170 @@
+package efincore
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+type HookFailurePolicy int
+
+const (
+	// HookFailClosed makes the proxy answer 502 to the client when a mod
+	// hook fails
+	HookFailClosed HookFailurePolicy = iota
+
+	// HookFailOpen discards the changes of a failed mod hook and continues
+	// with the unmodified message
+	HookFailOpen
+
+	// HookDisableAfterFailures behaves like HookFailOpen and disables the
+	// hook after a configured number of failures
+	HookDisableAfterFailures
+)
+
+func (p HookFailurePolicy) String() string {
+	switch p {
+	case HookFailClosed:
+		return "fail-closed"
+	case HookFailOpen:
+		return "fail-open"
+	case HookDisableAfterFailures:
+		return "disable-after-failures"
+	}
+
+	return "unknown"
+}
+
+var (
+	ErrHookTimeout = errors.New("hook timed out")
+	ErrHookPanic   = errors.New("hook panicked")
+)
+
+type HookError struct {
+	Hook string
+	Kind HookKind
+	Err  error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s hook '%s' failed: %v", e.Kind, e.Hook, e.Err)
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// WithTimeout sets the maximum time a hook can run. Hooks that time out
+// are treated as failed; they keep running in the background but their
+// changes are discarded
+func WithTimeout(timeout time.Duration) RegisterOption {
+	return func(r *registration) {
+		r.timeout = timeout
+	}
+}
+
+func WithFailurePolicy(policy HookFailurePolicy) RegisterOption {
+	return func(r *registration) {
+		r.failurePolicy = policy
+	}
+}
+
+// WithDisableAfter sets the HookDisableAfterFailures policy, disabling the
+// hook after the given number of failures
+func WithDisableAfter(failures int) RegisterOption {
+	return func(r *registration) {
+		r.failurePolicy = HookDisableAfterFailures
+		r.maxFailures = int64(failures)
+	}
+}
+
+func (r *registration) isEnabled() bool {
+	return r.enabled && !r.autoDisabled.Load()
+}
+
+func (r *registration) failClosed() bool {
+	return r.failurePolicy == HookFailClosed
+}
+
+// run calls f recovering from panics and enforcing the hook timeout.
+// Failures are recorded and returned as a *HookError
+func (r *registration) run(kind HookKind, f func() error) error {
+	start := time.Now()
+	err := r.runWithTimeout(f)
+	r.stats.Observe(LabeledStat(HistogramHookLatency, r.name), time.Since(start))
+
+	if err == nil {
+		return nil
+	}
+
+	// rejections are not failures of the hook
+	if !errors.Is(err, ErrHookRejected) {
+		r.recordFailure(err)
+	}
+
+	return &HookError{
+		Hook: r.name,
+		Kind: kind,
+		Err:  err,
+	}
+}
+
+func (r *registration) runWithTimeout(f func() error) error {
+	if r.timeout <= 0 {
+		return runRecover(f)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- runRecover(f)
+	}()
+
+	timer := time.NewTimer(r.timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("%w after %s", ErrHookTimeout, r.timeout)
+	}
+}
+
+func runRecover(f func() error) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("%w: %v", ErrHookPanic, rec)
+		}
+	}()
+
+	return f()
+}
+
+func (r *registration) resetFailures() {
+	r.failures.Store(0)
+	if r.autoDisabled.CompareAndSwap(true, false) {
+		r.stats.Decrease(StatDisabledHooks)
+	}
+}
+
+func (r *registration) recordFailure(err error) {
+	stats := r.stats
+	stats.Increase(StatHookFailures)
+	if errors.Is(err, ErrHookTimeout) {
+		stats.Increase(StatHookTimeouts)
+	}
+	if errors.Is(err, ErrHookPanic) {
+		stats.Increase(StatHookPanics)
+	}
+
+	failures := r.failures.Add(1)
+	if r.failurePolicy != HookDisableAfterFailures || failures < r.maxFailures {
+		return
+	}
+
+	if r.autoDisabled.CompareAndSwap(false, true) {
+		stats.Increase(StatDisabledHooks)
+		r.logger.Warn("hook disabled after too many failures", slog.String(LogKeyHook, r.name), slog.Int64("failures", failures))
+	}
+}
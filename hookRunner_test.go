@@ -0,0 +1,65 @@
+package efincore
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegistrationRun_RecoversPanics(t *testing.T) {
+	reg := newRegistration(nil, []RegisterOption{WithName("panics")})
+
+	err := reg.run(HookKindRequestMod, func() error {
+		panic("boom")
+	})
+
+	if !errors.Is(err, ErrHookPanic) {
+		t.Fatalf("expected a panic error, got '%v'", err)
+	}
+
+	var hookErr *HookError
+	if !errors.As(err, &hookErr) || hookErr.Hook != "panics" || hookErr.Kind != HookKindRequestMod {
+		t.Errorf("unexpected hook error: %v", err)
+	}
+}
+
+func TestRegistrationRun_Timeout(t *testing.T) {
+	reg := newRegistration(nil, []RegisterOption{WithTimeout(10 * time.Millisecond)})
+
+	release := make(chan struct{})
+	defer close(release)
+
+	err := reg.run(HookKindRequestMod, func() error {
+		<-release
+		return nil
+	})
+
+	if !errors.Is(err, ErrHookTimeout) {
+		t.Errorf("expected a timeout error, got '%v'", err)
+	}
+}
+
+func TestRegistrationRun_DisableAfterFailures(t *testing.T) {
+	reg := newRegistration(nil, []RegisterOption{WithDisableAfter(2)})
+
+	fail := func() error { return errors.New("failed") }
+
+	reg.run(HookKindResponseMod, fail)
+	if !reg.isEnabled() {
+		t.Fatalf("expected hook to be enabled after 1 failure")
+	}
+
+	reg.run(HookKindResponseMod, fail)
+	if reg.isEnabled() {
+		t.Fatalf("expected hook to be disabled after 2 failures")
+	}
+
+	if reg.failClosed() {
+		t.Errorf("expected hook not to fail closed")
+	}
+
+	reg.resetFailures()
+	if !reg.isEnabled() {
+		t.Errorf("expected hook to be enabled after reset")
+	}
+}
@@ -1,11 +1,14 @@
 package efincore
 
 import (
-	"log"
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/sync/errgroup"
 )
 
 type HookRequestRead interface {
@@ -48,14 +51,95 @@ func (hf HookResponseModFunc) HookMod(r *http.Response, id uuid.UUID) error {
 	return hf(r, id)
 }
 
+// HookErrorRead receives the errors that prevented the proxy from
+// completing a request
+type HookErrorRead interface {
+	HookRead(*ProxyError) error
+}
+
+type HookErrorReadFunc func(*ProxyError) error
+
+func (hf HookErrorReadFunc) HookRead(e *ProxyError) error {
+	return hf(e)
+}
+
+type StreamDirection int
+
+const (
+	StreamToUpstream StreamDirection = iota
+	StreamToClient
+)
+
+func (d StreamDirection) String() string {
+	switch d {
+	case StreamToUpstream:
+		return "to-upstream"
+	case StreamToClient:
+		return "to-client"
+	}
+
+	return "unknown"
+}
+
+// StreamChunk is a piece of the data of a tunnel that is passed through
+// without being parsed as HTTP
+type StreamChunk struct {
+	ConnectionID uuid.UUID
+	Host         string
+	Direction    StreamDirection
+	Data         []byte
+}
+
+// HookStreamRead receives the data of the tunnels that do not carry HTTP
+type HookStreamRead interface {
+	HookRead(*StreamChunk) error
+}
+
+type HookStreamReadFunc func(*StreamChunk) error
+
+func (hf HookStreamReadFunc) HookRead(c *StreamChunk) error {
+	return hf(c)
+}
+
+// PassthroughConnection describes a tunnel that was passed through without
+// being inspected, once it is closed
+type PassthroughConnection struct {
+	ConnectionID    uuid.UUID
+	Host            string
+	BytesToUpstream int64
+	BytesToClient   int64
+	Start           time.Time
+	Duration        time.Duration
+}
+
+// HookConnectionRead receives the passthrough tunnels when they are closed
+type HookConnectionRead interface {
+	HookRead(*PassthroughConnection) error
+}
+
+type HookConnectionReadFunc func(*PassthroughConnection) error
+
+func (hf HookConnectionReadFunc) HookRead(c *PassthroughConnection) error {
+	return hf(c)
+}
+
 type hooks struct {
-	requestInHooks  []HookRequestRead
-	requestModHooks []HookRequestMod
-	requestOutHooks []HookRequestRead
+	requestInHooks  []hookEntry[HookRequestRead]
+	requestModHooks []hookEntry[HookRequestMod]
+	requestOutHooks []hookEntry[HookRequestRead]
+
+	responseInHooks  []hookEntry[HookResponseRead]
+	responseModHooks []hookEntry[HookResponseMod]
+	responseOutHooks []hookEntry[HookResponseRead]
 
-	responseInHooks  []HookResponseRead
-	responseModHooks []HookResponseMod
-	responseOutHooks []HookResponseRead
+	errorHooks      []hookEntry[HookErrorRead]
+	streamHooks     []hookEntry[HookStreamRead]
+	connectionHooks []hookEntry[HookConnectionRead]
+}
+
+type hookEntry[H any] struct {
+	registration
+	hook H
 }
 
 func (h *hooks) RunRequestHooks(r *http.Request, id uuid.UUID) error {
@@ -65,31 +149,50 @@ func (h *hooks) RunRequestHooks(r *http.Request, id uuid.UUID) error {
 
 	rbody := newRBody(r.Body)
 
-	inReq := cloneRequest(r)
-	go func() {
-		inGroup, _ := errgroup.WithContext(inReq.Context())
-		for _, hook := range h.requestInHooks {
-			tHook := hook
+	for _, hook := range h.requestInHooks {
+		if !hook.isEnabled() {
+			continue
+		}
+		tHook := hook
 
-			req := cloneRequest(inReq)
-			req.Body = rbody.Clone()
+		req := cloneRequest(r)
+		req.Body = rbody.Clone()
 
-			inGroup.Go(func() error {
-				return tHook.HookRead(req, id)
+		tHook.dispatchOrdered(r.Context(), id, func() {
+			err := tHook.run(HookKindRequestIn, func() error {
+				return tHook.hook.HookRead(req, id)
 			})
-		}
-
-		if err := inGroup.Wait(); err != nil {
-			log.Printf("ERROR: requestIn read hooks failed for request '%s': %v", id.String(), err)
-		}
-	}()
+			if err != nil {
+				tHook.logger.Error("request hook failed", hookErrorAttrs(r.Context(), err, id, tHook.name)...)
+			}
+		})
+	}
 
 	r.Body = rbody
 	for _, hook := range h.requestModHooks {
-		if err := hook.HookMod(r, id); err != nil {
-			return err
+		if !hook.isEnabled() {
+			continue
 		}
 
+		// hooks modify a copy of the request, so that changes made by
+		// failed hooks can be discarded
+		req := cloneRequest(r)
+		req.Body = r.Body.(*RBody).Clone()
+
+		err := hook.run(HookKindRequestMod, func() error {
+			return hook.hook.HookMod(req, id)
+		})
+		if err != nil {
+			if hook.failClosed() || errors.Is(err, ErrHookRejected) {
+				return err
+			}
+
+			hook.logger.Error("request hook failed, continuing with unmodified request", hookErrorAttrs(r.Context(), err, id, hook.name)...)
+			r.Body.(*RBody).Rewind()
+			continue
+		}
+
+		*r = *req
 		if b, ok := r.Body.(*RBody); ok {
 			b.Rewind()
 		} else {
@@ -98,24 +201,24 @@ func (h *hooks) RunRequestHooks(r *http.Request, id uuid.UUID) error {
 	}
 
 	outReqRBody := r.Body.(*RBody)
-	outReq := cloneRequest(r)
-	go func() {
-		outGroup, _ := errgroup.WithContext(r.Context())
-		for _, hook := range h.requestOutHooks {
-			tHook := hook
+	for _, hook := range h.requestOutHooks {
+		if !hook.isEnabled() {
+			continue
+		}
+		tHook := hook
 
-			req := cloneRequest(outReq)
-			req.Body = outReqRBody.Clone()
+		req := cloneRequest(r)
+		req.Body = outReqRBody.Clone()
 
-			outGroup.Go(func() error {
-				return tHook.HookRead(req, id)
+		tHook.dispatchOrdered(r.Context(), id, func() {
+			err := tHook.run(HookKindRequestOut, func() error {
+				return tHook.hook.HookRead(req, id)
 			})
-		}
-
-		if err := outGroup.Wait(); err != nil {
-			log.Printf("ERROR: requestOut read hooks failed for request '%s': %v", id.String(), err)
-		}
-	}()
+			if err != nil {
+				tHook.logger.Error("request hook failed", hookErrorAttrs(r.Context(), err, id, tHook.name)...)
+			}
+		})
+	}
 
 	return nil
 }
@@ -127,31 +230,50 @@ func (h *hooks) RunResponseHooks(r *http.Response, id uuid.UUID) error {
 
 	rbody := newRBody(r.Body)
 
-	inResp := cloneResponse(r)
-	go func() {
-		inGroup, _ := errgroup.WithContext(inResp.Request.Context())
-		for _, hook := range h.responseInHooks {
-			tHook := hook
+	for _, hook := range h.responseInHooks {
+		if !hook.isEnabled() {
+			continue
+		}
+		tHook := hook
 
-			resp := cloneResponse(inResp)
-			resp.Body = rbody.Clone()
+		resp := cloneResponse(r)
+		resp.Body = rbody.Clone()
 
-			inGroup.Go(func() error {
-				return tHook.HookRead(resp, id)
+		tHook.dispatchOrdered(responseContext(r), id, func() {
+			err := tHook.run(HookKindResponseIn, func() error {
+				return tHook.hook.HookRead(resp, id)
 			})
-		}
-
-		if err := inGroup.Wait(); err != nil {
-			log.Printf("ERROR: responseIn read hooks failed for response '%s': %v", id.String(), err)
-		}
-	}()
+			if err != nil {
+				tHook.logger.Error("response hook failed", hookErrorAttrs(responseContext(r), err, id, tHook.name)...)
+			}
+		})
+	}
 
 	r.Body = rbody
 	for _, hook := range h.responseModHooks {
-		if err := hook.HookMod(r, id); err != nil {
-			return err
+		if !hook.isEnabled() {
+			continue
+		}
+
+		// hooks modify a copy of the response, so that changes made by
+		// failed hooks can be discarded
+		resp := cloneResponse(r)
+		resp.Body = r.Body.(*RBody).Clone()
+
+		err := hook.run(HookKindResponseMod, func() error {
+			return hook.hook.HookMod(resp, id)
+		})
+		if err != nil {
+			if hook.failClosed() || errors.Is(err, ErrHookRejected) {
+				return err
+			}
+
+			hook.logger.Error("response hook failed, continuing with unmodified response", hookErrorAttrs(responseContext(r), err, id, hook.name)...)
+			r.Body.(*RBody).Rewind()
+			continue
 		}
 
+		*r = *resp
 		if b, ok := r.Body.(*RBody); ok {
 			b.Rewind()
 		} else {
@@ -160,26 +282,101 @@ func (h *hooks) RunResponseHooks(r *http.Response, id uuid.UUID) error {
 	}
 
 	outRespRBody := r.Body.(*RBody)
-	outResp := cloneResponse(r)
-	go func() {
-		outGroup, _ := errgroup.WithContext(r.Request.Context())
-		for _, hook := range h.responseOutHooks {
-			tHook := hook
+	for _, hook := range h.responseOutHooks {
+		if !hook.isEnabled() {
+			continue
+		}
+		tHook := hook
 
-			resp := cloneResponse(outResp)
-			resp.Body = outRespRBody.Clone()
+		resp := cloneResponse(r)
+		resp.Body = outRespRBody.Clone()
 
-			outGroup.Go(func() error {
-				return tHook.HookRead(resp, id)
+		tHook.dispatchOrdered(responseContext(r), id, func() {
+			err := tHook.run(HookKindResponseOut, func() error {
+				return tHook.hook.HookRead(resp, id)
 			})
+			if err != nil {
+				tHook.logger.Error("response hook failed", hookErrorAttrs(responseContext(r), err, id, tHook.name)...)
+			}
+		})
+	}
+
+	return nil
+}
+
+func (h *hooks) RunErrorHooks(e *ProxyError) {
+	if h == nil {
+		return
+	}
+
+	for _, hook := range h.errorHooks {
+		if !hook.isEnabled() {
+			continue
 		}
+		tHook := hook
+
+		tHook.dispatch(func() {
+			err := tHook.run(HookKindError, func() error {
+				return tHook.hook.HookRead(e)
+			})
+			if err != nil {
+				tHook.logger.Error("error hook failed", errAttr(err), slog.String(LogKeyHook, tHook.name))
+			}
+		})
+	}
+}
+
+// RunStreamHooks sends a chunk of a tunnel to the stream hooks. The data is
+// copied, so the caller can reuse its buffer
+func (h *hooks) RunStreamHooks(c *StreamChunk) {
+	if h == nil || len(h.streamHooks) == 0 {
+		return
+	}
 
-		if err := outGroup.Wait(); err != nil {
-			log.Printf("ERROR: responseOut read hooks failed for response '%s': %v", id.String(), err)
+	chunk := *c
+	chunk.Data = bytes.Clone(c.Data)
+
+	for _, hook := range h.streamHooks {
+		if !hook.isEnabled() {
+			continue
 		}
-	}()
+		tHook := hook
 
-	return nil
+		tHook.dispatch(func() {
+			err := tHook.run(HookKindStream, func() error {
+				return tHook.hook.HookRead(&chunk)
+			})
+			if err != nil {
+				tHook.logger.Error("stream hook failed", errAttr(err), hostAttr(chunk.Host), connectionIDAttr(chunk.ConnectionID), slog.String(LogKeyHook, tHook.name))
+			}
+		})
+	}
+}
+
+// RunConnectionHooks sends a closed passthrough tunnel to the connection
+// hooks
+func (h *hooks) RunConnectionHooks(c *PassthroughConnection) {
+	if h == nil || len(h.connectionHooks) == 0 {
+		return
+	}
+
+	conn := *c
+
+	for _, hook := range h.connectionHooks {
+		if !hook.isEnabled() {
+			continue
+		}
+		tHook := hook
+
+		tHook.dispatch(func() {
+			err := tHook.run(HookKindConnection, func() error {
+				return tHook.hook.HookRead(&conn)
+			})
+			if err != nil {
+				tHook.logger.Error("connection hook failed", errAttr(err), hostAttr(conn.Host), connectionIDAttr(conn.ConnectionID), slog.String(LogKeyHook, tHook.name))
+			}
+		})
+	}
 }
 
 func (h *hooks) clone() *hooks {
@@ -188,86 +385,283 @@ func (h *hooks) clone() *hooks {
 	}
 
 	return &hooks{
-		requestInHooks:  append([]HookRequestRead{}, h.requestInHooks...),
-		requestModHooks: append([]HookRequestMod{}, h.requestModHooks...),
-		requestOutHooks: append([]HookRequestRead{}, h.requestOutHooks...),
+		requestInHooks:  append([]hookEntry[HookRequestRead]{}, h.requestInHooks...),
+		requestModHooks: append([]hookEntry[HookRequestMod]{}, h.requestModHooks...),
+		requestOutHooks: append([]hookEntry[HookRequestRead]{}, h.requestOutHooks...),
+
+		responseInHooks:  append([]hookEntry[HookResponseRead]{}, h.responseInHooks...),
+		responseModHooks: append([]hookEntry[HookResponseMod]{}, h.responseModHooks...),
+		responseOutHooks: append([]hookEntry[HookResponseRead]{}, h.responseOutHooks...),
+
+		errorHooks:      append([]hookEntry[HookErrorRead]{}, h.errorHooks...),
+		streamHooks:     append([]hookEntry[HookStreamRead]{}, h.streamHooks...),
+		connectionHooks: append([]hookEntry[HookConnectionRead]{}, h.connectionHooks...),
+	}
+}
+
+func (h *hooks) AddRequestInHook(hook HookRequestRead, reg registration) *hooks {
+	newHooks := h.clone()
+	if newHooks == nil {
+		newHooks = &hooks{}
+	}
+
+	reg.startQueue()
+	newHooks.requestInHooks = append(newHooks.requestInHooks, hookEntry[HookRequestRead]{reg, hook})
+
+	return newHooks
+}
 
-		responseInHooks:  append([]HookResponseRead{}, h.responseInHooks...),
-		responseModHooks: append([]HookResponseMod{}, h.responseModHooks...),
-		responseOutHooks: append([]HookResponseRead{}, h.responseOutHooks...),
+func (h *hooks) AddRequestOutHook(hook HookRequestRead, reg registration) *hooks {
+	newHooks := h.clone()
+	if newHooks == nil {
+		newHooks = &hooks{}
 	}
+
+	reg.startQueue()
+	newHooks.requestOutHooks = append(newHooks.requestOutHooks, hookEntry[HookRequestRead]{reg, hook})
+
+	return newHooks
+}
+
+func (h *hooks) AddRequestModHook(hook HookRequestMod, reg registration) *hooks {
+	newHooks := h.clone()
+	if newHooks == nil {
+		newHooks = &hooks{}
+	}
+
+	newHooks.requestModHooks = orderHookEntries(append(newHooks.requestModHooks, hookEntry[HookRequestMod]{reg, hook}))
+
+	return newHooks
 }
 
-func (h *hooks) AddRequestInHook(hook HookRequestRead) *hooks {
+func (h *hooks) AddResponseInHook(hook HookResponseRead, reg registration) *hooks {
 	newHooks := h.clone()
 	if newHooks == nil {
 		newHooks = &hooks{}
 	}
 
-	newHooks.requestInHooks = append(newHooks.requestInHooks, hook)
+	reg.startQueue()
+	newHooks.responseInHooks = append(newHooks.responseInHooks, hookEntry[HookResponseRead]{reg, hook})
 
 	return newHooks
 }
 
-func (h *hooks) AddRequestOutHook(hook HookRequestRead) *hooks {
+func (h *hooks) AddResponseOutHook(hook HookResponseRead, reg registration) *hooks {
 	newHooks := h.clone()
 	if newHooks == nil {
 		newHooks = &hooks{}
 	}
 
-	newHooks.requestOutHooks = append(newHooks.requestOutHooks, hook)
+	reg.startQueue()
+	newHooks.responseOutHooks = append(newHooks.responseOutHooks, hookEntry[HookResponseRead]{reg, hook})
 
 	return newHooks
 }
 
-func (h *hooks) AddRequestModHook(hook HookRequestMod) *hooks {
+func (h *hooks) AddResponseModHook(hook HookResponseMod, reg registration) *hooks {
 	newHooks := h.clone()
 	if newHooks == nil {
 		newHooks = &hooks{}
 	}
 
-	newHooks.requestModHooks = append(newHooks.requestModHooks, hook)
+	newHooks.responseModHooks = orderHookEntries(append(newHooks.responseModHooks, hookEntry[HookResponseMod]{reg, hook}))
 
 	return newHooks
 }
 
-func (h *hooks) AddResponseInHook(hook HookResponseRead) *hooks {
+func (h *hooks) AddErrorHook(hook HookErrorRead, reg registration) *hooks {
 	newHooks := h.clone()
 	if newHooks == nil {
 		newHooks = &hooks{}
 	}
 
-	newHooks.responseInHooks = append(newHooks.responseInHooks, hook)
+	reg.startQueue()
+	newHooks.errorHooks = append(newHooks.errorHooks, hookEntry[HookErrorRead]{reg, hook})
 
 	return newHooks
 }
 
-func (h *hooks) AddResponseOutHook(hook HookResponseRead) *hooks {
+func (h *hooks) AddStreamHook(hook HookStreamRead, reg registration) *hooks {
 	newHooks := h.clone()
 	if newHooks == nil {
 		newHooks = &hooks{}
 	}
 
-	newHooks.responseOutHooks = append(newHooks.responseOutHooks, hook)
+	reg.startQueue()
+	newHooks.streamHooks = append(newHooks.streamHooks, hookEntry[HookStreamRead]{reg, hook})
 
 	return newHooks
 }
 
-func (h *hooks) AddResponseModHook(hook HookResponseMod) *hooks {
+func (h *hooks) AddConnectionHook(hook HookConnectionRead, reg registration) *hooks {
 	newHooks := h.clone()
 	if newHooks == nil {
 		newHooks = &hooks{}
 	}
 
-	newHooks.responseModHooks = append(newHooks.responseModHooks, hook)
+	reg.startQueue()
+	newHooks.connectionHooks = append(newHooks.connectionHooks, hookEntry[HookConnectionRead]{reg, hook})
+
+	return newHooks
+}
+
+func (h *hooks) Remove(id uint64) *hooks {
+	if h == nil {
+		return nil
+	}
+
+	return &hooks{
+		requestInHooks:  removeHookEntry(h.requestInHooks, id),
+		requestModHooks: removeHookEntry(h.requestModHooks, id),
+		requestOutHooks: removeHookEntry(h.requestOutHooks, id),
+
+		responseInHooks:  removeHookEntry(h.responseInHooks, id),
+		responseModHooks: removeHookEntry(h.responseModHooks, id),
+		responseOutHooks: removeHookEntry(h.responseOutHooks, id),
+
+		errorHooks:      removeHookEntry(h.errorHooks, id),
+		streamHooks:     removeHookEntry(h.streamHooks, id),
+		connectionHooks: removeHookEntry(h.connectionHooks, id),
+	}
+}
+
+func (h *hooks) SetEnabled(id uint64, enabled bool) *hooks {
+	newHooks := h.clone()
+	if newHooks == nil {
+		return nil
+	}
+
+	setHookEntryEnabled(newHooks.requestInHooks, id, enabled)
+	setHookEntryEnabled(newHooks.requestModHooks, id, enabled)
+	setHookEntryEnabled(newHooks.requestOutHooks, id, enabled)
+
+	setHookEntryEnabled(newHooks.responseInHooks, id, enabled)
+	setHookEntryEnabled(newHooks.responseModHooks, id, enabled)
+	setHookEntryEnabled(newHooks.responseOutHooks, id, enabled)
+
+	setHookEntryEnabled(newHooks.errorHooks, id, enabled)
+	setHookEntryEnabled(newHooks.streamHooks, id, enabled)
+	setHookEntryEnabled(newHooks.connectionHooks, id, enabled)
 
 	return newHooks
 }
 
+func (h *hooks) List() []HookInfo {
+	if h == nil {
+		return []HookInfo{}
+	}
+
+	result := []HookInfo{}
+	result = appendHookInfo(result, HookKindRequestIn, h.requestInHooks)
+	result = appendHookInfo(result, HookKindRequestMod, h.requestModHooks)
+	result = appendHookInfo(result, HookKindRequestOut, h.requestOutHooks)
+
+	result = appendHookInfo(result, HookKindResponseIn, h.responseInHooks)
+	result = appendHookInfo(result, HookKindResponseMod, h.responseModHooks)
+	result = appendHookInfo(result, HookKindResponseOut, h.responseOutHooks)
+
+	result = appendHookInfo(result, HookKindError, h.errorHooks)
+	result = appendHookInfo(result, HookKindStream, h.streamHooks)
+	result = appendHookInfo(result, HookKindConnection, h.connectionHooks)
+
+	return result
+}
+
+// close stops the workers of the read hooks. It is called when the whole
+// hook set is replaced
+func (h *hooks) close() {
+	if h == nil {
+		return
+	}
+
+	for _, e := range h.requestInHooks {
+		e.queue.close()
+	}
+	for _, e := range h.requestOutHooks {
+		e.queue.close()
+	}
+	for _, e := range h.responseInHooks {
+		e.queue.close()
+	}
+	for _, e := range h.responseOutHooks {
+		e.queue.close()
+	}
+	for _, e := range h.errorHooks {
+		e.queue.close()
+	}
+	for _, e := range h.streamHooks {
+		e.queue.close()
+	}
+	for _, e := range h.connectionHooks {
+		e.queue.close()
+	}
+}
+
+func removeHookEntry[H any](entries []hookEntry[H], id uint64) []hookEntry[H] {
+	result := []hookEntry[H]{}
+	for _, e := range entries {
+		if e.id == id {
+			e.resetFailures()
+			e.queue.close()
+			continue
+		}
+		result = append(result, e)
+	}
+
+	return result
+}
+
+func setHookEntryEnabled[H any](entries []hookEntry[H], id uint64, enabled bool) {
+	for i := range entries {
+		if entries[i].id != id {
+			continue
+		}
+
+		entries[i].enabled = enabled
+		if enabled {
+			entries[i].resetFailures()
+		}
+	}
+}
+
+func appendHookInfo[H any](infos []HookInfo, kind HookKind, entries []hookEntry[H]) []HookInfo {
+	for _, e := range entries {
+		infos = append(infos, HookInfo{
+			ID:            e.id,
+			Kind:          kind,
+			Name:          e.name,
+			Description:   e.description,
+			Enabled:       e.isEnabled(),
+			Priority:      e.priority,
+			Before:        e.before,
+			After:         e.after,
+			Timeout:       e.timeout,
+			FailurePolicy: e.failurePolicy,
+			Failures:      e.failures.Load(),
+			DroppedEvents: e.queue.droppedEvents(),
+			Ordering:      e.ordering,
+		})
+	}
+
+	return infos
+}
+
+func hookErrorAttrs(ctx context.Context, err error, id uuid.UUID, hook string) []any {
+	return append(contextAttrs(ctx), errAttr(err), requestIDAttr(id), slog.String(LogKeyHook, hook))
+}
+
 func cloneRequest(r *http.Request) *http.Request {
 	return r.Clone(r.Context())
 }
 
+func responseContext(r *http.Response) context.Context {
+	if r.Request == nil {
+		return context.Background()
+	}
+
+	return r.Request.Context()
+}
+
 func cloneResponse(r *http.Response) *http.Response {
 	result := *r
 	result.Header = r.Header.Clone()
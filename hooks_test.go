@@ -0,0 +1,73 @@
+package efincore
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestHooks_AddDoesNotModifyOriginal(t *testing.T) {
+	var h *hooks
+	h = h.AddRequestModHook(HookRequestModFunc(func(*http.Request, uuid.UUID) error { return nil }), newRegistration(nil, nil))
+
+	newHooks := h.AddRequestModHook(HookRequestModFunc(func(*http.Request, uuid.UUID) error { return nil }), newRegistration(nil, nil))
+
+	if len(h.List()) != 1 {
+		t.Errorf("original hooks: got %d hooks, expected %d", len(h.List()), 1)
+	}
+
+	if len(newHooks.List()) != 2 {
+		t.Errorf("new hooks: got %d hooks, expected %d", len(newHooks.List()), 2)
+	}
+}
+
+func TestHooks_SetEnabledAndRemove(t *testing.T) {
+	in := newRegistration(nil, []RegisterOption{WithName("in")})
+	out := newRegistration(nil, []RegisterOption{WithName("out"), WithDisabled()})
+
+	var h *hooks
+	h = h.AddRequestInHook(HookRequestReadFunc(func(*http.Request, uuid.UUID) error { return nil }), in)
+	h = h.AddResponseOutHook(HookResponseReadFunc(func(*http.Response, uuid.UUID) error { return nil }), out)
+
+	list := h.List()
+	if len(list) != 2 {
+		t.Fatalf("got %d hooks, expected %d", len(list), 2)
+	}
+
+	if list[0].Kind != HookKindRequestIn || !list[0].Enabled {
+		t.Errorf("unexpected first hook: %+v", list[0])
+	}
+
+	if list[1].Kind != HookKindResponseOut || list[1].Enabled {
+		t.Errorf("unexpected second hook: %+v", list[1])
+	}
+
+	enabled := h.SetEnabled(out.id, true)
+	if !enabled.List()[1].Enabled {
+		t.Errorf("expected hook '%s' to be enabled", out.name)
+	}
+
+	if h.List()[1].Enabled {
+		t.Errorf("expected original hooks not to be modified")
+	}
+
+	removed := h.Remove(in.id)
+	list = removed.List()
+	if len(list) != 1 || list[0].Name != "out" {
+		t.Errorf("unexpected hooks after remove: %v", list)
+	}
+}
+
+func TestDefaultRegistrationName_UsesFunctionName(t *testing.T) {
+	reg := newRegistration(HookRequestModFunc(testNamedHook), nil)
+
+	expected := "github.com/artilugio0/efincore.testNamedHook"
+	if reg.name != expected {
+		t.Errorf("got '%s', expected '%s'", reg.name, expected)
+	}
+}
+
+func testNamedHook(*http.Request, uuid.UUID) error {
+	return nil
+}
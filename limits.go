@@ -0,0 +1,232 @@
+package efincore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Limits configures the time limits of every phase of the connections of
+// the proxy and the resources clients can use. Zero values mean no limit
+type Limits struct {
+	// ClientReadTimeout is the time a client has to send a whole request,
+	// body included
+	ClientReadTimeout time.Duration
+
+	// HeaderTimeout is the time a client has to send the headers of a
+	// request once it starts sending it
+	HeaderTimeout time.Duration
+
+	// IdleTimeout is the time a client connection is kept open waiting for
+	// the next request
+	IdleTimeout time.Duration
+
+	// DialTimeout and TLSHandshakeTimeout limit the phases of the
+	// connections to upstream servers
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+
+	// ResponseHeaderTimeout is the time an upstream server has to send the
+	// headers of the response once the request was sent
+	ResponseHeaderTimeout time.Duration
+
+	// MaxHeaderBytes limits the size of the request headers. Larger
+	// requests get a 431 response
+	MaxHeaderBytes int
+
+	// MaxConnections limits the client connections served at the same time
+	// by all the listeners. Further connections are closed
+	MaxConnections int
+}
+
+var DefaultLimits = Limits{
+	HeaderTimeout:         30 * time.Second,
+	IdleTimeout:           2 * time.Minute,
+	DialTimeout:           30 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: 2 * time.Minute,
+	MaxHeaderBytes:        http.DefaultMaxHeaderBytes,
+}
+
+// errHeaderTooLarge is returned when the headers of a request exceed
+// MaxHeaderBytes
+var errHeaderTooLarge = errors.New("request headers too large")
+
+// SetLimits sets the time limits and resource limits of the proxy. The
+// HTTP listener only applies the limits set before it is started; idle
+// upstream connections of plain requests are closed
+func (m *mitm) SetLimits(l Limits) {
+	m.limits.Store(&l)
+
+	old := m.client.Swap(m.newClient(m.pool.getConfig(), l))
+	if old != nil {
+		old.CloseIdleConnections()
+	}
+}
+
+func (m *mitm) getLimits() Limits {
+	return *m.limits.Load()
+}
+
+// newServer returns the server of the HTTP listener
+func (m *mitm) newServer(addr string) *http.Server {
+	l := m.getLimits()
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           m,
+		ReadTimeout:       l.ClientReadTimeout,
+		ReadHeaderTimeout: l.HeaderTimeout,
+		IdleTimeout:       l.IdleTimeout,
+		MaxHeaderBytes:    l.MaxHeaderBytes,
+	}
+}
+
+// dial connects to addr, through an upstream proxy if one is configured,
+// within the dial timeout
+func (m *mitm) dial(ctx context.Context, addr string) (net.Conn, error) {
+	return m.dialWith(ctx, m.upstreamProxies.Load(), addr)
+}
+
+func (m *mitm) dialWith(ctx context.Context, upstream *UpstreamProxies, addr string) (net.Conn, error) {
+	if timeout := m.getLimits().DialTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return dialUpstream(ctx, upstream, m.resolver.Load(), addr)
+}
+
+// limitListener closes the connections accepted by l when there are
+// already MaxConnections client connections
+func (m *mitm) limitListener(l net.Listener) net.Listener {
+	return &limitedListener{Listener: l, m: m}
+}
+
+type limitedListener struct {
+	net.Listener
+	m *mitm
+}
+
+func (l *limitedListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		n := l.m.connections.Add(1)
+		if max := l.m.getLimits().MaxConnections; max <= 0 || n <= int64(max) {
+			return &limitedConn{Conn: conn, m: l.m, once: &sync.Once{}}, nil
+		}
+
+		l.m.connections.Add(-1)
+		l.m.stats.Increase(StatRejectedConnections)
+		l.m.logger.Warn("too many connections, closing client connection", "client", conn.RemoteAddr().String())
+		conn.Close()
+	}
+}
+
+// limitedConn frees its place in the connection limit when it is closed
+type limitedConn struct {
+	net.Conn
+	m    *mitm
+	once *sync.Once
+}
+
+func (c *limitedConn) Close() error {
+	c.once.Do(func() {
+		c.m.connections.Add(-1)
+	})
+
+	return c.Conn.Close()
+}
+
+func (c *limitedConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// tcpConnOf returns the TCP connection under the wrappers of conn, such as
+// limitedConn and tls.Conn
+func tcpConnOf(conn net.Conn) (*net.TCPConn, bool) {
+	for {
+		switch c := conn.(type) {
+		case *net.TCPConn:
+			return c, true
+
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+
+		default:
+			return nil, false
+		}
+	}
+}
+
+// clientReader limits the data read from a client while the headers of a
+// request are read
+type clientReader struct {
+	r         io.Reader
+	remaining int64
+	limited   bool
+}
+
+func (r *clientReader) Read(p []byte) (int, error) {
+	if !r.limited {
+		return r.r.Read(p)
+	}
+
+	if r.remaining <= 0 {
+		return 0, errHeaderTooLarge
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	n, err := r.r.Read(p)
+	r.remaining -= int64(n)
+
+	return n, err
+}
+
+// limitHeader limits the data read until the headers of the next request
+// are read. As in net/http, some extra bytes are allowed for the data
+// buffered along with the headers
+func (r *clientReader) limitHeader(maxHeaderBytes int) {
+	r.limited = maxHeaderBytes > 0
+	r.remaining = int64(maxHeaderBytes) + 4096
+}
+
+func (r *clientReader) unlimit() {
+	r.limited = false
+}
+
+// writeStatusResponse answers a request that could not be read, closing
+// the connection
+func writeStatusResponse(w io.Writer, code int) error {
+	_, err := fmt.Fprintf(w, "HTTP/1.1 %d %s\r\nConnection: close\r\nContent-Length: 0\r\n\r\n", code, http.StatusText(code))
+	return err
+}
+
+// isTimeout reports whether err was caused by a deadline
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// deadline returns the time after timeout, or the zero time if there is no
+// timeout
+func deadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(timeout)
+}
@@ -0,0 +1,192 @@
+package efincore
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testLimits(f func(l *Limits)) Limits {
+	l := DefaultLimits
+	f(&l)
+	return l
+}
+
+// testHTTPTunnel opens a TLS tunnel through proxy to an echo server. The
+// client offers HTTP, so the tunnel is inspected
+func testHTTPTunnel(t *testing.T, proxy *Proxy) *tls.Conn {
+	t.Helper()
+
+	tlsConn, err := testTLSTunnel(t, proxy, runTestTLSEchoServer(t), &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{"http/1.1"},
+	})
+	if err != nil {
+		t.Fatalf("TLS handshake failed: %v", err)
+	}
+
+	return tlsConn
+}
+
+func TestLimits_ResponseHeaderTimeout_ClientGets504(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}
+	servers := map[string]string{
+		"http":  newTestServer(handler).URL,
+		"https": newTestServerHTTPS(t, handler).URL,
+	}
+
+	for name, serverURL := range servers {
+		t.Run(name, func(t *testing.T) {
+			proxy := runTestProxy(t)
+			proxy.SetLimits(testLimits(func(l *Limits) {
+				l.ResponseHeaderTimeout = 50 * time.Millisecond
+			}))
+
+			resp, err := newTestClientProxy(t, proxy.URL().String()).Get(serverURL)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusGatewayTimeout {
+				t.Errorf("response status code: got '%d', expected '%d'", resp.StatusCode, http.StatusGatewayTimeout)
+			}
+
+			if got := proxy.GetStats()[ErrorStat(ErrorKindTimeout)]; got != 1 {
+				t.Errorf("timeout errors: got '%d', expected '%d'", got, 1)
+			}
+		})
+	}
+}
+
+func TestLimits_IdleTunnelIsClosed(t *testing.T) {
+	proxy := runTestProxy(t)
+	proxy.SetLimits(testLimits(func(l *Limits) {
+		l.IdleTimeout = 50 * time.Millisecond
+	}))
+
+	tlsConn := testHTTPTunnel(t, proxy)
+	tlsConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	if _, err := tlsConn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("read from idle tunnel: got '%v', expected '%v'", err, io.EOF)
+	}
+
+	if got := proxy.GetStats()[StatClientTimeouts]; got != 1 {
+		t.Errorf("client timeouts: got '%d', expected '%d'", got, 1)
+	}
+}
+
+func TestLimits_TunnelRequestErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   func(l *Limits)
+		request  string
+		expected int
+		stat     string
+	}{
+		{
+			name:     "header timeout",
+			limits:   func(l *Limits) { l.HeaderTimeout = 50 * time.Millisecond },
+			request:  "GET / HTTP/1.1\r\nHost: example.com\r\n",
+			expected: http.StatusRequestTimeout,
+			stat:     StatClientTimeouts,
+		},
+		{
+			name:     "header too large",
+			limits:   func(l *Limits) { l.MaxHeaderBytes = 1024 },
+			request:  "GET / HTTP/1.1\r\nHost: example.com\r\nX-Large: " + strings.Repeat("a", 8192) + "\r\n\r\n",
+			expected: http.StatusRequestHeaderFieldsTooLarge,
+			stat:     StatOversizedHeaders,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := runTestProxy(t)
+			proxy.SetLimits(testLimits(tt.limits))
+
+			tlsConn := testHTTPTunnel(t, proxy)
+			tlsConn.SetDeadline(time.Now().Add(2 * time.Second))
+
+			if _, err := tlsConn.Write([]byte(tt.request)); err != nil {
+				t.Fatalf("could not write to tunnel: %v", err)
+			}
+
+			resp, err := http.ReadResponse(bufio.NewReader(tlsConn), nil)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			if resp.StatusCode != tt.expected {
+				t.Errorf("response status code: got '%d', expected '%d'", resp.StatusCode, tt.expected)
+			}
+
+			if got := proxy.GetStats()[tt.stat]; got != 1 {
+				t.Errorf("stat '%s': got '%d', expected '%d'", tt.stat, got, 1)
+			}
+		})
+	}
+}
+
+func TestLimits_MaxConnections(t *testing.T) {
+	proxy := runTestProxy(t)
+
+	// free the connection used to check that the proxy is ready
+	http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+	deadline := time.Now().Add(time.Second)
+	for proxy.mitm.connections.Load() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("ready check connection not closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	proxy.SetLimits(testLimits(func(l *Limits) {
+		l.MaxConnections = 1
+	}))
+
+	first, err := net.Dial("tcp", proxy.Addr())
+	if err != nil {
+		t.Fatalf("could not connect to proxy: %v", err)
+	}
+	defer first.Close()
+
+	second, err := net.Dial("tcp", proxy.Addr())
+	if err != nil {
+		t.Fatalf("could not connect to proxy: %v", err)
+	}
+	defer second.Close()
+
+	second.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := second.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("read from rejected connection: got '%v', expected '%v'", err, io.EOF)
+	}
+
+	if got := proxy.GetStats()[StatRejectedConnections]; got != 1 {
+		t.Errorf("rejected connections: got '%d', expected '%d'", got, 1)
+	}
+
+	// the place of a closed connection can be taken by a new one
+	first.Close()
+	deadline = time.Now().Add(time.Second)
+	for proxy.mitm.connections.Load() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("closed connection not released")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	resp, err := http.Get("http://" + proxy.Addr() + "/ready")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,239 @@
+package efincore
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// keys of the attributes added to log records
+const (
+	LogKeyRequestID    = "request_id"
+	LogKeyConnectionID = "connection_id"
+	LogKeyHost         = "host"
+	LogKeyHook         = "hook"
+	LogKeyError        = "error"
+)
+
+const errorEventsBufferSize = 64
+
+// ErrorEvent is published for every record logged with level error or
+// higher. Fields are empty when the record does not have the attribute
+type ErrorEvent struct {
+	Time         time.Time
+	Level        slog.Level
+	Message      string
+	Err          error
+	Kind         ErrorKind
+	RequestID    string
+	ConnectionID string
+	Host         string
+	Hook         string
+}
+
+// eventLogger wraps the slog.Logger of a component and publishes the
+// error records to the subscribers. It is shared by the parts of a proxy
+// so that the logger can be replaced after hooks are registered
+type eventLogger struct {
+	logger *atomic.Pointer[slog.Logger]
+	events *errorEvents
+	attrs  []any
+}
+
+type errorEvents struct {
+	mutex       *sync.Mutex
+	subscribers []chan ErrorEvent
+}
+
+func newEventLogger() *eventLogger {
+	l := &eventLogger{
+		logger: &atomic.Pointer[slog.Logger]{},
+		events: &errorEvents{mutex: &sync.Mutex{}},
+	}
+	l.logger.Store(slog.Default())
+
+	return l
+}
+
+// with returns a logger adding the given attributes to every record
+func (l *eventLogger) with(args ...any) *eventLogger {
+	if l == nil {
+		return nil
+	}
+
+	return &eventLogger{
+		logger: l.logger,
+		events: l.events,
+		attrs:  append(slices.Clip(l.attrs), args...),
+	}
+}
+
+// setLogger replaces the logger, nil discards every record. Error events
+// are published anyway
+func (l *eventLogger) setLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.New(discardHandler{})
+	}
+
+	l.logger.Store(logger)
+}
+
+func (l *eventLogger) get() *slog.Logger {
+	if l == nil {
+		return slog.Default()
+	}
+
+	return l.logger.Load()
+}
+
+func (l *eventLogger) Debug(msg string, args ...any) {
+	l.log(slog.LevelDebug, msg, args...)
+}
+
+func (l *eventLogger) Info(msg string, args ...any) {
+	l.log(slog.LevelInfo, msg, args...)
+}
+
+func (l *eventLogger) Warn(msg string, args ...any) {
+	l.log(slog.LevelWarn, msg, args...)
+}
+
+func (l *eventLogger) Error(msg string, args ...any) {
+	l.log(slog.LevelError, msg, args...)
+}
+
+func (l *eventLogger) log(level slog.Level, msg string, args ...any) {
+	ctx := context.Background()
+	logger := l.get()
+
+	r := slog.NewRecord(time.Now(), level, msg, 0)
+	if l != nil {
+		r.Add(l.attrs...)
+	}
+	r.Add(args...)
+
+	if logger.Enabled(ctx, level) {
+		_ = logger.Handler().Handle(ctx, r)
+	}
+
+	if level >= slog.LevelError && l != nil {
+		l.events.publish(newErrorEvent(r))
+	}
+}
+
+// subscribe returns a channel receiving the error events and a function
+// to stop receiving them. Events are dropped if the channel is full
+func (l *eventLogger) subscribe() (<-chan ErrorEvent, func()) {
+	return l.events.subscribe()
+}
+
+func (ev *errorEvents) subscribe() (<-chan ErrorEvent, func()) {
+	ev.mutex.Lock()
+	defer ev.mutex.Unlock()
+
+	c := make(chan ErrorEvent, errorEventsBufferSize)
+	ev.subscribers = append(ev.subscribers, c)
+
+	unsubscribe := func() {
+		ev.mutex.Lock()
+		defer ev.mutex.Unlock()
+
+		newSubscribers := []chan ErrorEvent{}
+		for _, s := range ev.subscribers {
+			if s == c {
+				close(c)
+				continue
+			}
+			newSubscribers = append(newSubscribers, s)
+		}
+		ev.subscribers = newSubscribers
+	}
+
+	return c, unsubscribe
+}
+
+func (ev *errorEvents) publish(e ErrorEvent) {
+	ev.mutex.Lock()
+	defer ev.mutex.Unlock()
+
+	for _, c := range ev.subscribers {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+}
+
+func newErrorEvent(r slog.Record) ErrorEvent {
+	e := ErrorEvent{
+		Time:    r.Time,
+		Level:   r.Level,
+		Message: r.Message,
+	}
+
+	r.Attrs(func(a slog.Attr) bool {
+		switch a.Key {
+		case LogKeyError:
+			if err, ok := a.Value.Any().(error); ok {
+				e.Err = err
+			}
+
+			var proxyErr *ProxyError
+			if errors.As(e.Err, &proxyErr) {
+				e.Kind = proxyErr.Kind
+			}
+		case LogKeyRequestID:
+			e.RequestID = a.Value.String()
+		case LogKeyConnectionID:
+			e.ConnectionID = a.Value.String()
+		case LogKeyHost:
+			e.Host = a.Value.String()
+		case LogKeyHook:
+			e.Hook = a.Value.String()
+		}
+
+		return true
+	})
+
+	return e
+}
+
+func errAttr(err error) slog.Attr {
+	return slog.Any(LogKeyError, err)
+}
+
+func requestIDAttr(id uuid.UUID) slog.Attr {
+	return slog.String(LogKeyRequestID, id.String())
+}
+
+func connectionIDAttr(id uuid.UUID) slog.Attr {
+	return slog.String(LogKeyConnectionID, id.String())
+}
+
+func hostAttr(host string) slog.Attr {
+	return slog.String(LogKeyHost, host)
+}
+
+// contextAttrs returns the attributes of a request context, such as the
+// ID of the client connection
+func contextAttrs(ctx context.Context) []any {
+	result := []any{}
+	if id, ok := ConnectionID(ctx); ok {
+		result = append(result, connectionIDAttr(id))
+	}
+
+	return result
+}
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
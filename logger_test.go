@@ -0,0 +1,137 @@
+package efincore
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type syncBuffer struct {
+	mutex sync.Mutex
+	b     bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	return sb.b.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+
+	return sb.b.String()
+}
+
+func TestEventLogger_RespectsLevelAndAddsAttributes(t *testing.T) {
+	buf := &syncBuffer{}
+
+	l := newEventLogger()
+	l.setLogger(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	connLogger := l.with(hostAttr("example.com"))
+	connLogger.Info("not logged")
+	connLogger.Warn("logged", slog.String("x", "y"))
+
+	got := buf.String()
+	if strings.Contains(got, "not logged") {
+		t.Errorf("info record logged with warn level: '%s'", got)
+	}
+
+	if !strings.Contains(got, "msg=logged host=example.com x=y") {
+		t.Errorf("log output: got '%s', expected the warn record with its attributes", got)
+	}
+}
+
+func TestEventLogger_ErrorsArePublished(t *testing.T) {
+	l := newEventLogger()
+	l.setLogger(nil)
+
+	events, unsubscribe := l.subscribe()
+
+	id := uuid.New()
+	err := errors.New("failed")
+	l.with(hostAttr("example.com")).Warn("warning")
+	l.with(hostAttr("example.com")).Error("something failed", errAttr(err), requestIDAttr(id))
+
+	select {
+	case e := <-events:
+		if e.Message != "something failed" {
+			t.Errorf("message: got '%s', expected '%s'", e.Message, "something failed")
+		}
+
+		if e.Err != err {
+			t.Errorf("error: got '%v', expected '%v'", e.Err, err)
+		}
+
+		if e.Host != "example.com" {
+			t.Errorf("host: got '%s', expected '%s'", e.Host, "example.com")
+		}
+
+		if e.RequestID != id.String() {
+			t.Errorf("request id: got '%s', expected '%s'", e.RequestID, id.String())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("error event not published")
+	}
+
+	unsubscribe()
+	if _, ok := <-events; ok {
+		t.Errorf("unexpected event after unsubscribe")
+	}
+}
+
+func TestFailingRequestModHook_ErrorEventHasRequestAttributes(t *testing.T) {
+	proxy := runTestProxy(t)
+
+	buf := &syncBuffer{}
+	proxy.SetLogger(slog.New(slog.NewTextHandler(buf, nil)))
+
+	events, unsubscribe := proxy.SubscribeErrors()
+	defer unsubscribe()
+
+	var hookReqID uuid.UUID
+	proxy.AddRequestModHook(HookRequestModFunc(func(r *http.Request, id uuid.UUID) error {
+		hookReqID = id
+		return errors.New("failed")
+	}), WithFailurePolicy(HookFailOpen), WithName("failing-hook"))
+
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {})
+	client := newTestClientProxy(t, proxy.URL().String())
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	select {
+	case e := <-events:
+		if e.Hook != "failing-hook" {
+			t.Errorf("hook: got '%s', expected '%s'", e.Hook, "failing-hook")
+		}
+
+		if e.RequestID != hookReqID.String() {
+			t.Errorf("request id: got '%s', expected '%s'", e.RequestID, hookReqID.String())
+		}
+
+		if e.ConnectionID == "" {
+			t.Errorf("connection id: got '', expected a connection id")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("error event not published")
+	}
+
+	if !strings.Contains(buf.String(), "hook=failing-hook") {
+		t.Errorf("log output: got '%s', expected a record of the failing hook", buf.String())
+	}
+}
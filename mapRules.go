@@ -0,0 +1,398 @@
+package efincore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultMapRulesReloadInterval = time.Second
+
+// MapRule serves the requests matching Scheme, Host, Port, Path and Method
+// from the local file or directory Local (map local), or sends them to the
+// URL Remote instead of their destination (map remote). Empty fields match
+// anything.
+//
+// Path is a prefix of whole path segments unless it contains glob
+// characters, as in ScopeRule: "/api" matches "/api" and "/api/users" but
+// not "/apix". When it is a prefix, the rest of the request path is
+// appended to Local, mapping a whole directory, or to the path of Remote
+type MapRule struct {
+	Name     string `json:"name,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+
+	Scheme string `json:"scheme,omitempty"`
+	Host   string `json:"host,omitempty"`
+	Port   int    `json:"port,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Method string `json:"method,omitempty"`
+
+	Local  string `json:"local,omitempty"`
+	Remote string `json:"remote,omitempty"`
+}
+
+type compiledMapRule struct {
+	rule MapRule
+
+	match  compiledScopeRule
+	prefix bool
+	remote *url.URL
+}
+
+// MapRules is a set of map local and map remote rules checked before a
+// request is sent upstream. The first matching rule is used
+type MapRules struct {
+	rules *atomic.Pointer[[]compiledMapRule]
+
+	// path of the file the rules are loaded from, if any
+	path string
+}
+
+type mapRulesFile struct {
+	Rules []MapRule `json:"rules"`
+}
+
+func NewMapRules(rules ...MapRule) (*MapRules, error) {
+	mr := &MapRules{
+		rules: &atomic.Pointer[[]compiledMapRule]{},
+	}
+
+	if err := mr.SetRules(rules...); err != nil {
+		return nil, err
+	}
+
+	return mr, nil
+}
+
+func ParseMapRules(r io.Reader) (*MapRules, error) {
+	rules, err := decodeMapRules(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMapRules(rules...)
+}
+
+// LoadMapRules reads the rules in the JSON file at path. Relative Local
+// paths are relative to the directory of the file. The rules can be
+// reloaded with Reload or Watch
+func LoadMapRules(path string) (*MapRules, error) {
+	mr, err := NewMapRules()
+	if err != nil {
+		return nil, err
+	}
+	mr.path = path
+
+	if err := mr.Reload(); err != nil {
+		return nil, err
+	}
+
+	return mr, nil
+}
+
+func decodeMapRules(r io.Reader) ([]MapRule, error) {
+	var f mapRulesFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("could not decode map rules: %v", err)
+	}
+
+	return f.Rules, nil
+}
+
+// Reload reads the rules again from the file they were loaded from. The
+// rules are left unchanged if the file is invalid
+func (mr *MapRules) Reload() error {
+	if mr.path == "" {
+		return errors.New("map rules not loaded from a file")
+	}
+
+	f, err := os.Open(mr.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rules, err := decodeMapRules(f)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(mr.path)
+	for i, r := range rules {
+		if r.Local != "" && !filepath.IsAbs(r.Local) {
+			rules[i].Local = filepath.Join(dir, r.Local)
+		}
+	}
+
+	return mr.SetRules(rules...)
+}
+
+// Watch reloads the rules every time their file changes, checking it
+// every interval (DefaultMapRulesReloadInterval if interval <= 0) until
+// ctx is done. The errors of failed reloads are sent to the returned
+// channel, and dropped if it is not read. The channel is closed when ctx
+// is done
+func (mr *MapRules) Watch(ctx context.Context, interval time.Duration) <-chan error {
+	if interval <= 0 {
+		interval = DefaultMapRulesReloadInterval
+	}
+
+	last, _ := os.Stat(mr.path)
+
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(mr.path)
+			if err == nil && last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+				continue
+			}
+
+			if err == nil {
+				last = info
+				err = mr.Reload()
+			}
+			if err != nil {
+				select {
+				case errs <- err:
+				default:
+				}
+			}
+		}
+	}()
+
+	return errs
+}
+
+// SetRules replaces every rule. The rules are left unchanged if any of the
+// new ones is invalid
+func (mr *MapRules) SetRules(rules ...MapRule) error {
+	compiled := make([]compiledMapRule, 0, len(rules))
+	for i, r := range rules {
+		cr, err := compileMapRule(r)
+		if err != nil {
+			return fmt.Errorf("invalid map rule %d: %v", i, err)
+		}
+
+		compiled = append(compiled, cr)
+	}
+
+	mr.rules.Store(&compiled)
+	return nil
+}
+
+func (mr *MapRules) Rules() []MapRule {
+	rules := *mr.rules.Load()
+
+	result := make([]MapRule, len(rules))
+	for i, r := range rules {
+		result[i] = r.rule
+	}
+
+	return result
+}
+
+func compileMapRule(r MapRule) (compiledMapRule, error) {
+	cr := compiledMapRule{rule: r}
+
+	if (r.Local == "") == (r.Remote == "") {
+		return cr, errors.New("exactly one of local and remote has to be set")
+	}
+
+	match, err := compileScopeRule(ScopeRule{
+		Scheme: r.Scheme,
+		Host:   r.Host,
+		Port:   r.Port,
+		Path:   r.Path,
+		Method: r.Method,
+	})
+	if err != nil {
+		return cr, err
+	}
+	cr.match = match
+	cr.prefix = match.pathRe == nil
+
+	if r.Remote != "" {
+		remote, err := url.Parse(r.Remote)
+		if err != nil || remote.Scheme != "http" && remote.Scheme != "https" || remote.Host == "" {
+			return cr, fmt.Errorf("invalid remote URL '%s'", r.Remote)
+		}
+		cr.remote = remote
+	}
+
+	return cr, nil
+}
+
+// find returns the first rule matching r
+func (mr *MapRules) find(r *http.Request) (compiledMapRule, bool) {
+	if mr == nil {
+		return compiledMapRule{}, false
+	}
+
+	scheme, host, port := requestTarget(r)
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	for _, rule := range *mr.rules.Load() {
+		if !rule.rule.Disabled && rule.match.matches(scheme, host, port, r.URL.Path, method) && rule.atSegment(r.URL.Path) {
+			return rule, true
+		}
+	}
+
+	return compiledMapRule{}, false
+}
+
+// atSegment reports whether the path prefix of the rule ends at the end of
+// a segment of reqPath, so that "/api" matches "/api/users" but not
+// "/apix"
+func (r compiledMapRule) atSegment(reqPath string) bool {
+	prefix := r.rule.Path
+	if !r.prefix || prefix == "" || strings.HasSuffix(prefix, "/") || len(reqPath) == len(prefix) {
+		return true
+	}
+
+	return len(reqPath) > len(prefix) && reqPath[len(prefix)] == '/'
+}
+
+// rest returns the part of the path of a request that is not matched by
+// the path prefix of the rule
+func (r compiledMapRule) rest(reqPath string) string {
+	if !r.prefix {
+		return ""
+	}
+
+	return strings.TrimPrefix(reqPath, r.rule.Path)
+}
+
+// localResponse builds the response to r from the mapped file. Files that
+// do not exist get a 404 response
+func (r compiledMapRule) localResponse(req *http.Request) (*http.Response, error) {
+	name := r.rule.Local
+	info, err := os.Stat(name)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err == nil && info.IsDir() {
+		// path.Clean of a rooted path keeps the file inside the directory
+		name = filepath.Join(name, filepath.FromSlash(path.Clean("/"+r.rest(req.URL.Path))))
+		if info, err = os.Stat(name); err == nil && info.IsDir() {
+			name = filepath.Join(name, "index.html")
+		}
+	}
+
+	body, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return newLocalResponse(req, http.StatusNotFound, "text/plain; charset=utf-8", []byte("404 page not found\n")), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+
+	return newLocalResponse(req, http.StatusOK, contentType, body), nil
+}
+
+// newLocalResponse builds a response generated by the proxy. Responses to
+// HEAD requests keep the Content-Length of body but have no body
+func newLocalResponse(req *http.Request, code int, contentType string, body []byte) *http.Response {
+	length := len(body)
+	if req.Method == http.MethodHead {
+		body = nil
+	}
+
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode: code,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Content-Type":   {contentType},
+			"Content-Length": {strconv.Itoa(length)},
+		},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(length),
+		Request:       req,
+	}
+}
+
+// remoteRequest returns a copy of req sent to the mapped URL
+func (r compiledMapRule) remoteRequest(req *http.Request) *http.Request {
+	u := *r.remote
+	if r.prefix {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(r.rest(req.URL.Path), "/")
+		u.RawPath = ""
+	}
+	if u.RawQuery == "" {
+		u.RawQuery = req.URL.RawQuery
+	}
+
+	out := req.Clone(req.Context())
+	out.URL = &u
+	out.Host = ""
+	out.RequestURI = ""
+
+	return out
+}
+
+// SetMapRules sets the map local and map remote rules checked before the
+// requests are sent upstream; nil disables them
+func (m *mitm) SetMapRules(mr *MapRules) {
+	m.mapRules.Store(mr)
+}
+
+// mapRequest returns the response to r given by the first map rule
+// matching it, or nil if no rule matches. r must have an absolute URL
+func (m *mitm) mapRequest(r *http.Request, logger *eventLogger) (*http.Response, error) {
+	rule, ok := m.mapRules.Load().find(r)
+	if !ok {
+		return nil, nil
+	}
+
+	m.stats.Increase(StatMappedRequests)
+
+	if rule.rule.Local != "" {
+		logger.Debug("serving request from local file", "rule", rule.rule.Name, "url", r.URL.String())
+
+		// the body has to be consumed before reading the next request
+		io.Copy(io.Discard, r.Body)
+		return rule.localResponse(r)
+	}
+
+	out := rule.remoteRequest(r)
+	logger.Debug("sending request to mapped URL", "rule", rule.rule.Name, "url", r.URL.String(), "remote", out.URL.String())
+
+	// redirects are not followed, they are sent to the client
+	return m.client.Load().Transport.RoundTrip(out)
+}
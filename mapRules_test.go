@@ -0,0 +1,304 @@
+package efincore
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func writeTestFile(t *testing.T, name, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+}
+
+func TestMapRules_LocalDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "static", "app.js"), "console.log(1)")
+	writeTestFile(t, filepath.Join(dir, "static", "index.html"), "<html></html>")
+	writeTestFile(t, filepath.Join(dir, "static", "data"), `{"a": 1}`)
+	writeTestFile(t, filepath.Join(dir, "secret"), "secret")
+
+	mr, err := NewMapRules(MapRule{Host: "example.com", Path: "/assets/", Local: filepath.Join(dir, "static")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		path        string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"/assets/app.js", http.StatusOK, "text/javascript; charset=utf-8", "console.log(1)"},
+		{"/assets/", http.StatusOK, "text/html; charset=utf-8", "<html></html>"},
+		{"/assets/data", http.StatusOK, "text/plain; charset=utf-8", `{"a": 1}`},
+		{"/assets/missing.css", http.StatusNotFound, "text/plain; charset=utf-8", "404 page not found\n"},
+		{"/assets/../secret", http.StatusNotFound, "text/plain; charset=utf-8", "404 page not found\n"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+		r.URL.Path = tt.path
+
+		rule, ok := mr.find(r)
+		if !ok {
+			t.Errorf("no rule found for '%s'", tt.path)
+			continue
+		}
+
+		resp, err := rule.localResponse(r)
+		if err != nil {
+			t.Errorf("unexpected error for '%s': %v", tt.path, err)
+			continue
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != tt.status || resp.Header.Get("Content-Type") != tt.contentType || string(body) != tt.body {
+			t.Errorf("response to '%s': got '%d' '%s' '%s', expected '%d' '%s' '%s'",
+				tt.path, resp.StatusCode, resp.Header.Get("Content-Type"), body, tt.status, tt.contentType, tt.body)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "https://other.example.com/assets/app.js", nil)
+	if _, ok := mr.find(r); ok {
+		t.Errorf("rule found for another host")
+	}
+}
+
+func TestMapRules_PrefixMatchesWholeSegments(t *testing.T) {
+	mr, err := NewMapRules(MapRule{Path: "/api", Local: t.TempDir()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := map[string]bool{
+		"/api":       true,
+		"/api/":      true,
+		"/api/users": true,
+		"/apix":      false,
+		"/apix/a":    false,
+	}
+
+	for path, expected := range tests {
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+		r.URL.Path = path
+
+		if _, got := mr.find(r); got != expected {
+			t.Errorf("rule found for '%s': got '%t', expected '%t'", path, got, expected)
+		}
+	}
+}
+
+func TestProxy_MapLocal_HEADRequestHasNoBody(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "a.txt"), "hello")
+
+	proxy := runTestProxy(t)
+
+	mr, err := NewMapRules(MapRule{Path: "/a.txt", Local: filepath.Join(dir, "a.txt")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	proxy.SetMapRules(mr)
+
+	bodies := make(chan string, 2)
+	proxy.AddResponseInHook(HookResponseReadFunc(func(r *http.Response, id uuid.UUID) error {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+		return nil
+	}))
+
+	tlsConn := testHTTPTunnel(t, proxy)
+	tlsConn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	// the GET request is answered on the same connection after the HEAD one
+	reader := bufio.NewReader(tlsConn)
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, _ := http.NewRequest(method, "https://example.com/a.txt", nil)
+		if err := req.Write(tlsConn); err != nil {
+			t.Fatalf("could not write %s request: %v", method, err)
+		}
+
+		resp, err := http.ReadResponse(reader, req)
+		if err != nil {
+			t.Fatalf("could not read response to %s: %v", method, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("could not read response body of %s: %v", method, err)
+		}
+
+		expected := "hello"
+		if method == http.MethodHead {
+			expected = ""
+		}
+
+		if resp.ContentLength != 5 || string(body) != expected {
+			t.Errorf("response to %s: got length '%d' and body '%s', expected '%d' and '%s'", method, resp.ContentLength, body, 5, expected)
+		}
+
+		select {
+		case got := <-bodies:
+			if got != expected {
+				t.Errorf("hook body of %s: got '%s', expected '%s'", method, got, expected)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("response hook not called")
+		}
+	}
+}
+
+func TestProxy_MapLocal_PlainRequest(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "mock.json"), `{"mocked": true}`)
+
+	proxy := runTestProxy(t)
+
+	mr, err := NewMapRules(MapRule{Host: "api.example.test", Path: "/users", Local: filepath.Join(dir, "mock.json")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	proxy.SetMapRules(mr)
+
+	resp, err := newTestClientProxy(t, proxy.URL().String()).Get("http://api.example.test/users")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read response body: %v", err)
+	}
+
+	if string(body) != `{"mocked": true}` {
+		t.Errorf("response body: got '%s', expected '%s'", body, `{"mocked": true}`)
+	}
+
+	if got := resp.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("content type: got '%s', expected '%s'", got, "application/json")
+	}
+
+	if got := proxy.GetStats()[StatMappedRequests]; got != 1 {
+		t.Errorf("mapped requests: got '%d', expected '%d'", got, 1)
+	}
+}
+
+func TestProxy_MapRemote_Tunnel(t *testing.T) {
+	original := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("original"))
+	})
+
+	remote := newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("remote " + r.URL.RequestURI()))
+	})
+	defer remote.Close()
+
+	proxy := runTestProxy(t)
+
+	mr, err := NewMapRules(MapRule{Path: "/api/", Remote: remote.URL + "/v2/"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	proxy.SetMapRules(mr)
+
+	client := newTestClientProxy(t, proxy.URL().String())
+
+	tests := map[string]string{
+		"/api/users?id=1": "remote /v2/users?id=1",
+		"/other":          "original",
+	}
+
+	for path, expected := range tests {
+		resp, err := client.Get(original.URL + path)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("could not read response body: %v", err)
+		}
+
+		if string(body) != expected {
+			t.Errorf("response body of '%s': got '%s', expected '%s'", path, body, expected)
+		}
+	}
+}
+
+func TestMapRules_Watch(t *testing.T) {
+	dir := t.TempDir()
+	rulesFile := filepath.Join(dir, "rules.json")
+	writeTestFile(t, rulesFile, `{"rules": [{"path": "/a", "local": "a.json"}]}`)
+
+	mr, err := LoadMapRules(rulesFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := mr.Rules(); len(got) != 1 || got[0].Local != filepath.Join(dir, "a.json") {
+		t.Fatalf("rules: got '%+v', expected a rule with local '%s'", got, filepath.Join(dir, "a.json"))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := mr.Watch(ctx, 10*time.Millisecond)
+
+	writeTestFile(t, rulesFile, `{"rules": [{"path": "/b", "remote": "http://example.com"}]}`)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(mr.Rules()) != 1 || mr.Rules()[0].Path != "/b" {
+		if time.Now().After(deadline) {
+			t.Fatalf("rules not reloaded: got '%+v'", mr.Rules())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	writeTestFile(t, rulesFile, `{"rules": [{"path": "/c"}]}`)
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Errorf("expected reload error")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("reload error not reported")
+	}
+
+	if got := mr.Rules(); len(got) != 1 || got[0].Path != "/b" {
+		t.Errorf("rules after invalid reload: got '%+v', expected the previous rules", got)
+	}
+}
+
+func TestNewMapRules_InvalidRules(t *testing.T) {
+	rules := []MapRule{
+		{Path: "/a"},
+		{Path: "/a", Local: "a.json", Remote: "http://example.com"},
+		{Path: "/a", Remote: "ftp://example.com"},
+		{Path: "/a", Remote: "/relative"},
+		{Port: 70000, Local: "a.json"},
+	}
+
+	for _, r := range rules {
+		if _, err := NewMapRules(r); err == nil {
+			t.Errorf("invalid rule '%+v' accepted", r)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package efincore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+type MatchReplaceTarget string
+
+const (
+	TargetRequestHeader  MatchReplaceTarget = "request-header"
+	TargetRequestURL     MatchReplaceTarget = "request-url"
+	TargetRequestBody    MatchReplaceTarget = "request-body"
+	TargetResponseHeader MatchReplaceTarget = "response-header"
+	TargetResponseStatus MatchReplaceTarget = "response-status"
+	TargetResponseBody   MatchReplaceTarget = "response-body"
+)
+
+type MatchReplaceAction string
+
+const (
+	ActionReplace MatchReplaceAction = "replace"
+	ActionAdd     MatchReplaceAction = "add"
+	ActionRemove  MatchReplaceAction = "remove"
+)
+
+// MatchReplaceRule modifies a part of the requests or responses in its
+// scope. Match is a regular expression whose matches are replaced with
+// Replace, which can refer to submatches as in regexp.Expand ("$1").
+//
+// Header rules act on the values of the header Header: add adds Replace as
+// a new value, remove deletes the values matching Match (all of them if
+// Match is empty) and replace edits the values. The status line of
+// responses is matched as "HTTP/1.1 200 OK". Compressed bodies are left
+// untouched
+type MatchReplaceRule struct {
+	Name     string             `json:"name,omitempty" yaml:"name,omitempty"`
+	Disabled bool               `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	Target   MatchReplaceTarget `json:"target" yaml:"target"`
+	Action   MatchReplaceAction `json:"action,omitempty" yaml:"action,omitempty"`
+	Header   string             `json:"header,omitempty" yaml:"header,omitempty"`
+	Match    string             `json:"match,omitempty" yaml:"match,omitempty"`
+	Replace  string             `json:"replace,omitempty" yaml:"replace,omitempty"`
+
+	// Scope limits the requests the rule applies to, as a Scope does.
+	// Response rules are checked against the request of the response
+	Scope []ScopeRule `json:"scope,omitempty" yaml:"scope,omitempty"`
+}
+
+type compiledMatchReplaceRule struct {
+	rule MatchReplaceRule
+
+	match *regexp.Regexp
+	scope *Scope
+}
+
+// MatchReplace applies match and replace rules to requests and responses.
+// Its hooks are added to a proxy as mod hooks with Proxy.AddMatchReplace
+// or MatchReplace.Hooks, and its rules can be changed while they run
+type MatchReplace struct {
+	rules *atomic.Pointer[[]compiledMatchReplaceRule]
+}
+
+type matchReplaceFile struct {
+	Rules []MatchReplaceRule `json:"rules" yaml:"rules"`
+}
+
+func NewMatchReplace(rules ...MatchReplaceRule) (*MatchReplace, error) {
+	mr := &MatchReplace{
+		rules: &atomic.Pointer[[]compiledMatchReplaceRule]{},
+	}
+
+	if err := mr.SetRules(rules...); err != nil {
+		return nil, err
+	}
+
+	return mr, nil
+}
+
+// ParseMatchReplace reads rules in JSON format
+func ParseMatchReplace(r io.Reader) (*MatchReplace, error) {
+	var f matchReplaceFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("could not decode match and replace rules: %v", err)
+	}
+
+	return NewMatchReplace(f.Rules...)
+}
+
+// ParseMatchReplaceYAML reads rules in YAML format, with the same fields
+// as the JSON format
+func ParseMatchReplaceYAML(r io.Reader) (*MatchReplace, error) {
+	var f matchReplaceFile
+	if err := yaml.NewDecoder(r).Decode(&f); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("could not decode match and replace rules: %v", err)
+	}
+
+	return NewMatchReplace(f.Rules...)
+}
+
+// LoadMatchReplace reads the rules in the file at path, in YAML format if
+// its extension is .yaml or .yml and in JSON format otherwise
+func LoadMatchReplace(path string) (*MatchReplace, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParseMatchReplaceYAML(f)
+	}
+
+	return ParseMatchReplace(f)
+}
+
+// SetRules replaces every rule. The rules are left unchanged if any of the
+// new ones is invalid
+func (mr *MatchReplace) SetRules(rules ...MatchReplaceRule) error {
+	compiled := make([]compiledMatchReplaceRule, 0, len(rules))
+	for i, r := range rules {
+		cr, err := compileMatchReplaceRule(r)
+		if err != nil {
+			return fmt.Errorf("invalid match and replace rule %d: %v", i, err)
+		}
+
+		compiled = append(compiled, cr)
+	}
+
+	mr.rules.Store(&compiled)
+	return nil
+}
+
+func (mr *MatchReplace) Rules() []MatchReplaceRule {
+	rules := *mr.rules.Load()
+
+	result := make([]MatchReplaceRule, len(rules))
+	for i, r := range rules {
+		result[i] = r.rule
+	}
+
+	return result
+}
+
+// Hooks returns the registrations of the request and response mod hooks
+// applying the rules. They are named "match-replace" unless a name is
+// given in opts
+func (mr *MatchReplace) Hooks(opts ...RegisterOption) []HookRegistration {
+	opts = append([]RegisterOption{WithName("match-replace")}, opts...)
+
+	return []HookRegistration{
+		RequestModHook(HookRequestModFunc(mr.ModifyRequest), opts...),
+		ResponseModHook(HookResponseModFunc(mr.ModifyResponse), opts...),
+	}
+}
+
+// ModifyRequest applies the request rules in scope to r
+func (mr *MatchReplace) ModifyRequest(r *http.Request, id uuid.UUID) error {
+	for _, rule := range *mr.rules.Load() {
+		if rule.rule.Disabled || !rule.scope.InScope(r) {
+			continue
+		}
+
+		var err error
+		switch rule.rule.Target {
+		case TargetRequestHeader:
+			rule.applyHeader(r.Header)
+
+		case TargetRequestURL:
+			err = rule.applyURL(r)
+
+		case TargetRequestBody:
+			if isEncoded(r.Header) {
+				continue
+			}
+
+			var body []byte
+			body, err = rule.applyBody(r.Body)
+			if err == nil {
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				r.ContentLength = int64(len(body))
+				setContentLength(r.Header, len(body))
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("match and replace rule '%s': %w", rule.rule.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// ModifyResponse applies the response rules in scope to r
+func (mr *MatchReplace) ModifyResponse(r *http.Response, id uuid.UUID) error {
+	for _, rule := range *mr.rules.Load() {
+		if rule.rule.Disabled || r.Request != nil && !rule.scope.InScope(r.Request) {
+			continue
+		}
+
+		var err error
+		switch rule.rule.Target {
+		case TargetResponseHeader:
+			rule.applyHeader(r.Header)
+
+		case TargetResponseStatus:
+			err = rule.applyStatus(r)
+
+		case TargetResponseBody:
+			if isEncoded(r.Header) {
+				continue
+			}
+
+			var body []byte
+			body, err = rule.applyBody(r.Body)
+			if err == nil {
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				r.ContentLength = int64(len(body))
+				setContentLength(r.Header, len(body))
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("match and replace rule '%s': %w", rule.rule.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func compileMatchReplaceRule(r MatchReplaceRule) (compiledMatchReplaceRule, error) {
+	cr := compiledMatchReplaceRule{rule: r}
+
+	if cr.rule.Action == "" {
+		cr.rule.Action = ActionReplace
+	}
+
+	switch r.Target {
+	case TargetRequestHeader, TargetResponseHeader:
+		if r.Header == "" {
+			return cr, errors.New("header rules need a header name")
+		}
+
+		switch cr.rule.Action {
+		case ActionAdd, ActionRemove:
+		case ActionReplace:
+			if r.Match == "" {
+				return cr, errors.New("replace rules need a match expression")
+			}
+		default:
+			return cr, fmt.Errorf("unknown action '%s'", r.Action)
+		}
+
+	case TargetRequestURL, TargetRequestBody, TargetResponseStatus, TargetResponseBody:
+		if cr.rule.Action != ActionReplace {
+			return cr, fmt.Errorf("action '%s' can only be used in header rules", r.Action)
+		}
+
+		if r.Match == "" {
+			return cr, errors.New("replace rules need a match expression")
+		}
+
+	default:
+		return cr, fmt.Errorf("unknown target '%s'", r.Target)
+	}
+
+	if r.Match != "" {
+		match, err := regexp.Compile(r.Match)
+		if err != nil {
+			return cr, fmt.Errorf("invalid match expression: %v", err)
+		}
+		cr.match = match
+	}
+
+	if len(r.Scope) > 0 {
+		scope, err := NewScope(r.Scope...)
+		if err != nil {
+			return cr, err
+		}
+		cr.scope = scope
+	}
+
+	return cr, nil
+}
+
+func (r compiledMatchReplaceRule) applyHeader(h http.Header) {
+	name := http.CanonicalHeaderKey(r.rule.Header)
+
+	switch r.rule.Action {
+	case ActionAdd:
+		h.Add(name, r.rule.Replace)
+
+	case ActionRemove:
+		if r.match == nil {
+			h.Del(name)
+			return
+		}
+
+		values := []string{}
+		for _, v := range h.Values(name) {
+			if !r.match.MatchString(v) {
+				values = append(values, v)
+			}
+		}
+
+		if len(values) == 0 {
+			h.Del(name)
+		} else {
+			h[name] = values
+		}
+
+	case ActionReplace:
+		values := h.Values(name)
+		for i, v := range values {
+			values[i] = r.match.ReplaceAllString(v, r.rule.Replace)
+		}
+	}
+}
+
+func (r compiledMatchReplaceRule) applyURL(req *http.Request) error {
+	rawURL := req.URL.String()
+
+	newURL := r.match.ReplaceAllString(rawURL, r.rule.Replace)
+	if newURL == rawURL {
+		return nil
+	}
+
+	u, err := url.Parse(newURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL '%s': %w", newURL, err)
+	}
+
+	if u.Host != req.URL.Host && u.Host != "" {
+		req.Host = u.Host
+	}
+	req.URL = u
+
+	return nil
+}
+
+func (r compiledMatchReplaceRule) applyBody(body io.ReadCloser) ([]byte, error) {
+	if body == nil {
+		body = http.NoBody
+	}
+	defer body.Close()
+
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.match.ReplaceAll(b, []byte(r.rule.Replace)), nil
+}
+
+func (r compiledMatchReplaceRule) applyStatus(resp *http.Response) error {
+	proto := resp.Proto
+	if proto == "" {
+		proto = fmt.Sprintf("HTTP/%d.%d", resp.ProtoMajor, resp.ProtoMinor)
+	}
+
+	status := resp.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	line := proto + " " + status
+	newLine := r.match.ReplaceAllString(line, r.rule.Replace)
+	if newLine == line {
+		return nil
+	}
+
+	newProto, newStatus, _ := strings.Cut(newLine, " ")
+	major, minor, ok := http.ParseHTTPVersion(newProto)
+	if !ok {
+		return fmt.Errorf("invalid status line '%s'", newLine)
+	}
+
+	code, _, _ := strings.Cut(newStatus, " ")
+	statusCode, err := strconv.Atoi(code)
+	if err != nil || len(code) != 3 || statusCode < 100 {
+		return fmt.Errorf("invalid status line '%s'", newLine)
+	}
+
+	resp.Proto = newProto
+	resp.ProtoMajor = major
+	resp.ProtoMinor = minor
+	resp.Status = newStatus
+	resp.StatusCode = statusCode
+
+	return nil
+}
+
+// isEncoded reports whether a body has a content encoding other than
+// identity
+func isEncoded(h http.Header) bool {
+	encoding := h.Get("Content-Encoding")
+	return encoding != "" && !strings.EqualFold(encoding, "identity")
+}
+
+func setContentLength(h http.Header, length int) {
+	if h.Get("Transfer-Encoding") == "" {
+		h.Set("Content-Length", strconv.Itoa(length))
+	}
+}
@@ -0,0 +1,310 @@
+package efincore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/artilugio0/efincore/proto"
+	"github.com/google/uuid"
+)
+
+func TestMatchReplace_ModifyRequest(t *testing.T) {
+	mr, err := ParseMatchReplace(strings.NewReader(`{"rules": [
+		{"target": "request-header", "action": "add", "header": "X-Debug", "replace": "1"},
+		{"target": "request-header", "action": "remove", "header": "Cookie"},
+		{"target": "request-header", "header": "User-Agent", "match": "Chrome/[0-9.]+", "replace": "Chrome/1.0"},
+		{"target": "request-url", "match": "/v1/", "replace": "/v2/"},
+		{"target": "request-body", "match": "\"debug\":false", "replace": "\"debug\":true"},
+		{"target": "request-header", "action": "add", "header": "X-Other", "replace": "1",
+		 "scope": [{"host": "other.example.com"}]},
+		{"target": "request-header", "action": "add", "header": "X-Disabled", "replace": "1", "disabled": true}
+	]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "https://api.example.com/v1/users", strings.NewReader(`{"debug":false}`))
+	r.Header.Set("Cookie", "session=1")
+	r.Header.Set("User-Agent", "Mozilla/5.0 Chrome/120.0.1")
+
+	if err := mr.ModifyRequest(r, uuid.New()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedHeaders := map[string]string{
+		"X-Debug":    "1",
+		"Cookie":     "",
+		"User-Agent": "Mozilla/5.0 Chrome/1.0",
+		"X-Other":    "",
+		"X-Disabled": "",
+	}
+	for name, expected := range expectedHeaders {
+		if got := r.Header.Get(name); got != expected {
+			t.Errorf("header '%s': got '%s', expected '%s'", name, got, expected)
+		}
+	}
+
+	if got := r.URL.String(); got != "https://api.example.com/v2/users" {
+		t.Errorf("URL: got '%s', expected '%s'", got, "https://api.example.com/v2/users")
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatalf("could not read body: %v", err)
+	}
+
+	if string(body) != `{"debug":true}` {
+		t.Errorf("body: got '%s', expected '%s'", body, `{"debug":true}`)
+	}
+
+	if r.ContentLength != int64(len(body)) {
+		t.Errorf("content length: got '%d', expected '%d'", r.ContentLength, len(body))
+	}
+}
+
+func TestLoadMatchReplace_YAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	writeTestFile(t, path, `
+rules:
+  - name: debug header
+    target: request-header
+    action: add
+    header: X-Debug
+    replace: "1"
+    scope:
+      - action: exclude
+        host: "*.internal.example.com"
+  - target: request-url
+    match: /v1/
+    replace: /v2/
+`)
+
+	mr, err := LoadMatchReplace(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rules := mr.Rules()
+	if len(rules) != 2 || rules[0].Name != "debug header" || rules[1].Target != TargetRequestURL {
+		t.Fatalf("rules: got '%+v', expected the rules of the file", rules)
+	}
+
+	if len(rules[0].Scope) != 1 || rules[0].Scope[0].Action != ScopeExclude {
+		t.Errorf("rule scope: got '%+v', expected an exclude rule", rules[0].Scope)
+	}
+
+	tests := map[string]string{
+		"https://api.example.com/v1/users":          "1",
+		"https://api.internal.example.com/v1/users": "",
+	}
+
+	for rawURL, expected := range tests {
+		r := httptest.NewRequest(http.MethodGet, rawURL, nil)
+		if err := mr.ModifyRequest(r, uuid.New()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := r.Header.Get("X-Debug"); got != expected {
+			t.Errorf("X-Debug header of '%s': got '%s', expected '%s'", rawURL, got, expected)
+		}
+
+		if !strings.Contains(r.URL.Path, "/v2/") {
+			t.Errorf("URL path of '%s': got '%s', expected it to contain '%s'", rawURL, r.URL.Path, "/v2/")
+		}
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.yml")
+	writeTestFile(t, invalid, "rules:\n  - target: request-url\n    match: \"(\"\n")
+	if _, err := LoadMatchReplace(invalid); err == nil {
+		t.Errorf("invalid rules accepted")
+	}
+}
+
+func TestMatchReplace_ModifyResponse(t *testing.T) {
+	mr, err := NewMatchReplace(
+		MatchReplaceRule{Target: TargetResponseStatus, Match: `^(HTTP/1\.1) 403 .*$`, Replace: "$1 200 OK"},
+		MatchReplaceRule{Target: TargetResponseHeader, Action: ActionRemove, Header: "Set-Cookie", Match: "^tracking="},
+		MatchReplaceRule{Target: TargetResponseBody, Match: "denied", Replace: "allowed"},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp := &http.Response{
+		Status:     "403 Forbidden",
+		StatusCode: http.StatusForbidden,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Set-Cookie": {"session=1", "tracking=2"},
+		},
+		Body:    io.NopCloser(strings.NewReader("access denied")),
+		Request: httptest.NewRequest(http.MethodGet, "https://api.example.com/", nil),
+	}
+
+	if err := mr.ModifyResponse(resp, uuid.New()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || resp.Status != "200 OK" {
+		t.Errorf("status: got '%d' '%s', expected '%d' '%s'", resp.StatusCode, resp.Status, http.StatusOK, "200 OK")
+	}
+
+	if got := resp.Header.Values("Set-Cookie"); len(got) != 1 || got[0] != "session=1" {
+		t.Errorf("Set-Cookie headers: got '%v', expected '%v'", got, []string{"session=1"})
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read body: %v", err)
+	}
+
+	if string(body) != "access allowed" {
+		t.Errorf("body: got '%s', expected '%s'", body, "access allowed")
+	}
+}
+
+func TestMatchReplace_EncodedBodyIsNotModified(t *testing.T) {
+	mr, err := NewMatchReplace(MatchReplaceRule{Target: TargetResponseBody, Match: "a", Replace: "b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Encoding": {"gzip"}},
+		Body:       io.NopCloser(strings.NewReader("aaa")),
+	}
+
+	if err := mr.ModifyResponse(resp, uuid.New()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read body: %v", err)
+	}
+
+	if string(body) != "aaa" {
+		t.Errorf("body: got '%s', expected '%s'", body, "aaa")
+	}
+}
+
+func TestNewMatchReplace_InvalidRules(t *testing.T) {
+	rules := []MatchReplaceRule{
+		{Target: "request-cookie", Match: "a"},
+		{Target: TargetRequestHeader, Action: ActionAdd},
+		{Target: TargetRequestHeader, Header: "X-Test"},
+		{Target: TargetResponseHeader, Action: "rename", Header: "X-Test"},
+		{Target: TargetRequestBody, Action: ActionAdd, Match: "a"},
+		{Target: TargetRequestURL, Match: "("},
+		{Target: TargetRequestURL, Match: "a", Scope: []ScopeRule{{Port: 70000}}},
+	}
+
+	for _, r := range rules {
+		if _, err := NewMatchReplace(r); err == nil {
+			t.Errorf("invalid rule '%+v' accepted", r)
+		}
+	}
+
+	mr, err := NewMatchReplace(MatchReplaceRule{Target: TargetRequestURL, Match: "a", Replace: "b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := mr.SetRules(rules[0]); err == nil {
+		t.Errorf("invalid rule accepted")
+	}
+
+	if got := mr.Rules(); len(got) != 1 || got[0].Match != "a" {
+		t.Errorf("rules after invalid update: got '%+v', expected the previous rules", got)
+	}
+}
+
+func TestProxy_MatchReplace(t *testing.T) {
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("server " + r.Header.Get("X-Injected")))
+	})
+
+	proxy := runTestProxy(t)
+
+	mr, err := NewMatchReplace(
+		MatchReplaceRule{Target: TargetRequestHeader, Action: ActionAdd, Header: "X-Injected", Replace: "header"},
+		MatchReplaceRule{Target: TargetResponseBody, Match: "^server", Replace: "proxy"},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	handles := proxy.AddMatchReplace(mr)
+	if len(handles) != 2 {
+		t.Fatalf("handles: got '%d', expected '%d'", len(handles), 2)
+	}
+
+	for _, h := range proxy.Hooks() {
+		if h.Name != "match-replace" {
+			t.Errorf("hook name: got '%s', expected '%s'", h.Name, "match-replace")
+		}
+	}
+
+	resp, err := newTestClientProxy(t, proxy.URL().String()).Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read response body: %v", err)
+	}
+
+	if string(body) != "proxy header" {
+		t.Errorf("response body: got '%s', expected '%s'", body, "proxy header")
+	}
+}
+
+func TestGRPCServerMatchReplaceRules(t *testing.T) {
+	mr, err := NewMatchReplace()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server := NewGRPCServer("127.0.0.1:0")
+	server.SetMatchReplaceService(mr)
+
+	rule := &proto.MatchReplaceRule{
+		Name:    "api version",
+		Target:  "request-url",
+		Match:   "/v1/",
+		Replace: "/v2/",
+		Scope:   []*proto.ScopeRule{{Action: "include", Host: "*.example.com"}},
+	}
+
+	if _, err := server.SetMatchReplaceRules(context.Background(), &proto.SetMatchReplaceRulesInput{Rules: []*proto.MatchReplaceRule{rule}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out, err := server.GetMatchReplaceRules(context.Background(), &proto.GetMatchReplaceRulesInput{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(out.Rules) != 1 || out.Rules[0].Name != "api version" || out.Rules[0].Action != "replace" {
+		t.Fatalf("rules: got '%v', expected '%v'", out.Rules, rule)
+	}
+
+	if len(out.Rules[0].Scope) != 1 || out.Rules[0].Scope[0].Host != "*.example.com" {
+		t.Errorf("rule scope: got '%v', expected '%v'", out.Rules[0].Scope, rule.Scope)
+	}
+
+	invalid := &proto.MatchReplaceRule{Target: "request-url", Match: "a", Scope: []*proto.ScopeRule{{Action: "maybe"}}}
+	if _, err := server.SetMatchReplaceRules(context.Background(), &proto.SetMatchReplaceRulesInput{Rules: []*proto.MatchReplaceRule{invalid}}); err == nil {
+		t.Errorf("invalid rule accepted")
+	}
+}
@@ -0,0 +1,200 @@
+package efincore
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	MetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	metricsPrefix      = "efincore_"
+)
+
+// MetricsSource provides gauges that are computed when the metrics are
+// scraped, such as cache sizes. Both Proxy and GRPCServer implement it
+type MetricsSource interface {
+	Metrics() Stats
+}
+
+// metricLabels maps labeled stats and histograms to the name of their label
+var metricLabels = map[string]string{
+	StatRequestsByHost:    "host",
+	StatResponsesByStatus: "status",
+	StatErrorsByKind:      "kind",
+	StatGRPCSubscribers:   "stream",
+	HistogramHookLatency:  "hook",
+}
+
+type metricsHandler struct {
+	stats   StatsService
+	sources []MetricsSource
+	logger  *eventLogger
+}
+
+// NewMetricsHandler returns a handler that exports the stats, histograms
+// and the gauges of the given sources in OpenMetrics text format
+func NewMetricsHandler(stats StatsService, sources ...MetricsSource) http.Handler {
+	return &metricsHandler{
+		stats:   stats,
+		sources: sources,
+	}
+}
+
+func (h *metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b := &bytes.Buffer{}
+
+	gauges := Stats{}
+	for _, s := range h.sources {
+		for k, v := range s.Metrics() {
+			gauges[k] = v
+		}
+	}
+
+	writeMetrics(b, h.stats.Get(), gauges, h.stats.GetHistograms())
+
+	w.Header().Set("Content-Type", MetricsContentType)
+	if _, err := w.Write(b.Bytes()); err != nil {
+		h.logger.Warn("could not send metrics to client", errAttr(err))
+		h.stats.Increase(ErrorStat(ErrorKindClient))
+	}
+}
+
+type metricSample struct {
+	label string
+	value string
+}
+
+type metricFamily struct {
+	name    string
+	typ     string
+	samples []metricSample
+
+	histograms []labeledHistogram
+}
+
+type labeledHistogram struct {
+	label string
+	h     Histogram
+}
+
+func writeMetrics(b *bytes.Buffer, stats Stats, gauges Stats, histograms map[string]Histogram) {
+	families := map[string]*metricFamily{}
+	family := func(stat, typ string) *metricFamily {
+		f, ok := families[stat]
+		if !ok {
+			f = &metricFamily{name: metricName(stat), typ: typ}
+			if typ == "histogram" {
+				f.name += "_seconds"
+			}
+			families[stat] = f
+		}
+
+		return f
+	}
+
+	for k, v := range stats {
+		name, label, _ := SplitLabeledStat(k)
+
+		typ := "counter"
+		if isGauge(name) {
+			typ = "gauge"
+		}
+
+		f := family(name, typ)
+		f.samples = append(f.samples, metricSample{label: label, value: strconv.Itoa(v)})
+	}
+
+	for k, v := range gauges {
+		name, label, _ := SplitLabeledStat(k)
+
+		f := family(name, "gauge")
+		f.samples = append(f.samples, metricSample{label: label, value: strconv.Itoa(v)})
+	}
+
+	for k, h := range histograms {
+		name, label, _ := SplitLabeledStat(k)
+
+		f := family(name, "histogram")
+		f.histograms = append(f.histograms, labeledHistogram{label: label, h: h})
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, stat := range names {
+		families[stat].write(b, metricLabels[stat])
+	}
+
+	b.WriteString("# EOF\n")
+}
+
+func (f *metricFamily) write(b *bytes.Buffer, labelName string) {
+	if labelName == "" {
+		labelName = "label"
+	}
+
+	fmt.Fprintf(b, "# TYPE %s %s\n", f.name, f.typ)
+
+	suffix := ""
+	if f.typ == "counter" {
+		suffix = "_total"
+	}
+
+	sort.Slice(f.samples, func(i, j int) bool {
+		return f.samples[i].label < f.samples[j].label
+	})
+	for _, s := range f.samples {
+		fmt.Fprintf(b, "%s%s%s %s\n", f.name, suffix, formatLabels(labelName, s.label), s.value)
+	}
+
+	sort.Slice(f.histograms, func(i, j int) bool {
+		return f.histograms[i].label < f.histograms[j].label
+	})
+	for _, lh := range f.histograms {
+		labels := formatLabels(labelName, lh.label)
+
+		// OpenMetrics buckets are cumulative
+		var cumulative uint64
+		for i, count := range lh.h.Counts {
+			cumulative += count
+
+			le := "+Inf"
+			if i < len(lh.h.Buckets) {
+				le = strconv.FormatFloat(lh.h.Buckets[i], 'g', -1, 64)
+			}
+
+			bucketLabels := `{le="` + le + `"}`
+			if labels != "" {
+				bucketLabels = strings.TrimSuffix(labels, "}") + `,le="` + le + `"}`
+			}
+
+			fmt.Fprintf(b, "%s_bucket%s %d\n", f.name, bucketLabels, cumulative)
+		}
+
+		fmt.Fprintf(b, "%s_sum%s %s\n", f.name, labels, strconv.FormatFloat(lh.h.Sum, 'g', -1, 64))
+		fmt.Fprintf(b, "%s_count%s %d\n", f.name, labels, lh.h.Count)
+	}
+}
+
+func metricName(stat string) string {
+	return metricsPrefix + strings.ReplaceAll(stat, "-", "_")
+}
+
+func formatLabels(name, value string) string {
+	if value == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(`{%s="%s"}`, name, escapeLabelValue(value))
+}
+
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
@@ -0,0 +1,145 @@
+package efincore
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testMetricsSource Stats
+
+func (s testMetricsSource) Metrics() Stats {
+	return Stats(s)
+}
+
+func TestMetricsHandler_ExportsOpenMetrics(t *testing.T) {
+	stats := NewStatsService()
+	stats.Increase(StatActiveConnections)
+	stats.Add(StatInterceptedRequests, 3)
+	stats.Increase(HostStat("example.com"))
+	stats.Increase(ErrorStat(ErrorKindUpstream))
+	stats.Observe(LabeledStat(HistogramHookLatency, "my-hook"), 2*time.Millisecond)
+	stats.Observe(LabeledStat(HistogramHookLatency, "my-hook"), time.Minute)
+
+	source := testMetricsSource{
+		StatCACacheSize: 2,
+		LabeledStat(StatGRPCSubscribers, "requests-in"): 1,
+	}
+
+	handler := NewMetricsHandler(stats, source)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if got := recorder.Header().Get("Content-Type"); got != MetricsContentType {
+		t.Errorf("content type: got '%s', expected '%s'", got, MetricsContentType)
+	}
+
+	body := recorder.Body.String()
+	expectedLines := []string{
+		"# TYPE efincore_active_connections gauge",
+		"efincore_active_connections 1",
+		"# TYPE efincore_intercepted_requests counter",
+		"efincore_intercepted_requests_total 3",
+		`efincore_requests_by_host_total{host="example.com"} 1`,
+		`efincore_errors_by_kind_total{kind="upstream"} 1`,
+		"efincore_ca_cache_size 2",
+		`efincore_grpc_subscribers{stream="requests-in"} 1`,
+		"# TYPE efincore_hook_latency_seconds histogram",
+		`efincore_hook_latency_seconds_bucket{hook="my-hook",le="0.001"} 0`,
+		`efincore_hook_latency_seconds_bucket{hook="my-hook",le="0.005"} 1`,
+		`efincore_hook_latency_seconds_bucket{hook="my-hook",le="10"} 1`,
+		`efincore_hook_latency_seconds_bucket{hook="my-hook",le="+Inf"} 2`,
+		`efincore_hook_latency_seconds_count{hook="my-hook"} 2`,
+	}
+
+	lines := strings.Split(body, "\n")
+	for _, expected := range expectedLines {
+		found := false
+		for _, l := range lines {
+			if l == expected {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			t.Errorf("line '%s' not found in metrics:\n%s", expected, body)
+		}
+	}
+
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("metrics do not end with '# EOF'")
+	}
+}
+
+func TestMetricsHandler_EscapesLabelValues(t *testing.T) {
+	stats := NewStatsService()
+	stats.Increase(HostStat("a\"b\\c"))
+
+	recorder := httptest.NewRecorder()
+	NewMetricsHandler(stats).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	expected := `efincore_requests_by_host_total{host="a\"b\\c"} 1`
+	if !strings.Contains(recorder.Body.String(), expected) {
+		t.Errorf("metrics: got '%s', expected to contain '%s'", recorder.Body.String(), expected)
+	}
+}
+
+type failingResponseWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingResponseWriter) Write(b []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestMetricsHandler_FailedWrite_CountsClientError(t *testing.T) {
+	stats := NewStatsService()
+
+	w := failingResponseWriter{httptest.NewRecorder()}
+	NewMetricsHandler(stats).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if got := stats.Get()[ErrorStat(ErrorKindClient)]; got != 1 {
+		t.Errorf("client errors: got '%d', expected '%d'", got, 1)
+	}
+}
+
+func TestProxyMetricsHandler_ExportsCACacheSizeAndGRPCSubscribers(t *testing.T) {
+	proxy := NewProxy("127.0.0.1:0")
+	grpcServer := NewGRPCServer("127.0.0.1:0")
+
+	if _, err := proxy.mitm.ca.GetCertificateFor("example.com"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c := grpcServer.addRequestOutClient()
+	defer grpcServer.removeRequestOutClient(c)
+
+	server := httptest.NewServer(proxy.MetricsHandler(grpcServer))
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer response.Body.Close()
+
+	bodyBytes, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("could not read response body: %v", err)
+	}
+	body := string(bodyBytes)
+
+	for _, expected := range []string{
+		"efincore_ca_cache_size 1\n",
+		`efincore_grpc_subscribers{stream="requests-out"} 1` + "\n",
+		`efincore_grpc_subscribers{stream="requests-in"} 0` + "\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("metrics: expected to contain '%s', got:\n%s", expected, body)
+		}
+	}
+}
@@ -3,24 +3,43 @@ package efincore
 import (
 	"bufio"
 	"bytes"
-	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type mitm struct {
 	ca            *CA
-	client        *http.Client
+	client        *atomic.Pointer[http.Client]
+	pool          *upstreamPool
 	readyEndpoint string
+	stats         StatsService
+	hostStats     *hostStats
+	logger        *eventLogger
+	errorPage     *atomic.Value
+
+	credentialChecker *atomic.Pointer[CredentialChecker]
+	upstreamProxies   *atomic.Pointer[UpstreamProxies]
+
+	limits      *atomic.Pointer[Limits]
+	connections *atomic.Int64
+	resolver    *atomic.Pointer[Resolver]
+	mapRules    *atomic.Pointer[MapRules]
+	faults      *atomic.Pointer[Faults]
+
+	originalDestination *atomic.Pointer[OriginalDestination]
+	passthroughHosts    *passthroughHosts
 
 	criteria      *criteria
 	criteriaMutex *sync.Mutex
@@ -29,30 +48,59 @@ type mitm struct {
 	hooksMutex *sync.Mutex
 }
 
-func newMitm() *mitm {
-	return &mitm{
-		client: &http.Client{},
-		ca:     NewCA(),
+func newMitm(stats StatsService, logger *eventLogger) *mitm {
+	m := &mitm{
+		ca:        NewCA(),
+		client:    &atomic.Pointer[http.Client]{},
+		pool:      newUpstreamPool(DefaultPoolConfig, stats),
+		stats:     stats,
+		hostStats: newHostStats(DefaultMaxHostStats),
+		logger:    logger,
+		errorPage: &atomic.Value{},
+
+		credentialChecker: &atomic.Pointer[CredentialChecker]{},
+		upstreamProxies:   &atomic.Pointer[UpstreamProxies]{},
+
+		limits:      &atomic.Pointer[Limits]{},
+		connections: &atomic.Int64{},
+		resolver:    &atomic.Pointer[Resolver]{},
+		mapRules:    &atomic.Pointer[MapRules]{},
+		faults:      &atomic.Pointer[Faults]{},
+
+		originalDestination: &atomic.Pointer[OriginalDestination]{},
+		passthroughHosts:    newPassthroughHosts(),
 
 		criteriaMutex: &sync.Mutex{},
 		hooksMutex:    &sync.Mutex{},
 	}
+
+	m.SetOriginalDestination(SOOriginalDst)
+
+	limits := DefaultLimits
+	m.limits.Store(&limits)
+	m.client.Store(m.newClient(DefaultPoolConfig, limits))
+
+	return m
 }
 
 func (m *mitm) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	GetStatsService().Increase(StatActiveConnections)
-	defer GetStatsService().Decrease(StatActiveConnections)
+	m.stats.Increase(StatActiveConnections)
+	defer m.stats.Decrease(StatActiveConnections)
 
-	if r.Method == http.MethodConnect {
-		GetStatsService().Increase(StatActiveConnectRequests)
-		m.serveConnect(w, r)
-		GetStatsService().Decrease(StatActiveConnectRequests)
+	if r.Method != http.MethodConnect && m.readyEndpoint != "" && r.URL.Path == m.readyEndpoint {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 
+	if !m.authorize(w, r) {
 		return
 	}
 
-	if r.URL.Path == m.readyEndpoint {
-		w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodConnect {
+		m.stats.Increase(StatActiveConnectRequests)
+		m.serveConnect(w, r)
+		m.stats.Decrease(StatActiveConnectRequests)
+
 		return
 	}
 
@@ -63,8 +111,43 @@ func (m *mitm) SetReadyEndpoint(e string) {
 	m.readyEndpoint = e
 }
 
+// SetUpstreamProxies sets the proxies used to connect to the destination
+// of requests; nil connects directly to every host
+func (m *mitm) SetUpstreamProxies(u *UpstreamProxies) {
+	m.upstreamProxies.Store(u)
+}
+
+// upstreamProxyFor selects the upstream proxy of the requests sent by
+// m.client
+func (m *mitm) upstreamProxyFor(r *http.Request) (*url.URL, error) {
+	_, host, port := requestTarget(r)
+	return m.upstreamProxies.Load().ProxyFor(host, port), nil
+}
+
+func (m *mitm) SetErrorPage(page ErrorPage) {
+	m.errorPage.Store(page)
+}
+
+func (m *mitm) getErrorPage() ErrorPage {
+	page, _ := m.errorPage.Load().(ErrorPage)
+	return page
+}
+
+// reportError logs an error that prevented a request from completing,
+// counts it and runs the error hooks
+func (m *mitm) reportError(logger *eventLogger, msg string, err *ProxyError) {
+	logger.Error(msg, errAttr(err), slog.String("kind", string(err.Kind)))
+	m.stats.Increase(ErrorStat(err.Kind))
+
+	m.hooksMutex.Lock()
+	hooks := m.hooks
+	m.hooksMutex.Unlock()
+
+	hooks.RunErrorHooks(err)
+}
+
 func (m *mitm) serveConnect(w http.ResponseWriter, r *http.Request) {
-	if !m.shouldInterceptDomain(r) {
+	if m.passthroughHosts.contains(r.URL.Hostname()) || !m.shouldInterceptDomain(r) {
 		m.requestPassthrough(w, r)
 		return
 	}
@@ -77,61 +160,194 @@ func (m *mitm) requestInspect(w http.ResponseWriter, r *http.Request) {
 
 	srcConn, destConn, err := m.hijack(w, r)
 	if err != nil {
-		// TODO: make errors more specific and check error type to
-		// return correct status if needed
-		w.WriteHeader(http.StatusBadGateway)
-		log.Printf("ERROR: could not hijack connection: %v", err)
+		m.connectError(w, r, err)
 		return
 	}
+
+	m.inspectClientTLS(srcConn, destConn, connectURL, m.logger.with(hostAttr(connectURL.Host)))
+}
+
+// inspectTunnel reads the requests sent by the client through a tunnel,
+// runs the hooks and forwards them to the destination. If rewrite is not
+// nil, it is applied to every request before the criteria and hooks. A
+// pooled destination connection is returned to the pool when the tunnel
+// ends after a complete response
+func (m *mitm) inspectTunnel(srcConn, destConn net.Conn, connectURL *url.URL, rewrite func(*http.Request)) {
 	defer srcConn.Close()
-	defer destConn.Close()
 
-	srcBufReader := bufio.NewReader(srcConn)
+	limits := m.getLimits()
+	srcReader := &clientReader{r: srcConn}
+	srcBufReader := bufio.NewReader(srcReader)
 	destBufReader := bufio.NewReader(destConn)
 
+	pooled, _ := destConn.(*pooledConn)
+	if pooled != nil {
+		destBufReader = pooled.reader
+	}
+
+	// through upstream proxies the IP of the server is only known if it is
+	// overridden by the resolver
+	var resolvedIP net.IP
+	if m.upstreamProxies.Load().ProxyFor(connectURL.Hostname(), portOrDefault(connectURL.Port(), tunnelScheme(connectURL))) == nil {
+		if addr, ok := destConn.RemoteAddr().(*net.TCPAddr); ok {
+			resolvedIP = addr.IP
+		}
+	} else if ip, ok := m.resolver.Load().override(connectURL.Hostname()); ok {
+		resolvedIP = ip
+	}
+
+	reusable := false
+	defer func() {
+		if pooled != nil && reusable {
+			pooled.release()
+			return
+		}
+		destConn.Close()
+	}()
+
+	connID := uuid.New()
 	for {
+		// wait for the next request
+		srcConn.SetReadDeadline(deadline(limits.IdleTimeout))
+		if _, err := srcBufReader.Peek(1); err != nil {
+			if isTimeout(err) {
+				m.stats.Increase(StatClientTimeouts)
+				m.logger.Debug("closing idle client connection", hostAttr(connectURL.Host), connectionIDAttr(connID))
+			} else if err != io.EOF {
+				m.logger.Warn("could not read request", errAttr(err), hostAttr(connectURL.Host), connectionIDAttr(connID))
+			}
+			return
+		}
+
+		requestStart := time.Now()
+		srcConn.SetReadDeadline(deadline(limits.HeaderTimeout))
+		srcReader.limitHeader(limits.MaxHeaderBytes)
 		req, err := http.ReadRequest(srcBufReader)
+		srcReader.unlimit()
 		if err != nil {
-			if err != io.EOF {
-				log.Printf("could not read request: %v", err)
+			switch {
+			case isTimeout(err):
+				m.stats.Increase(StatClientTimeouts)
+				writeStatusResponse(srcConn, http.StatusRequestTimeout)
+			case errors.Is(err, errHeaderTooLarge):
+				m.stats.Increase(StatOversizedHeaders)
+				writeStatusResponse(srcConn, http.StatusRequestHeaderFieldsTooLarge)
 			}
+			m.logger.Warn("could not read request", errAttr(err), hostAttr(connectURL.Host), connectionIDAttr(connID))
 			return
 		}
 
-		shouldIntercept := m.shouldInterceptRequest(req)
+		srcConn.SetReadDeadline(time.Time{})
+		if limits.ClientReadTimeout > 0 {
+			srcConn.SetReadDeadline(requestStart.Add(limits.ClientReadTimeout))
+		}
+		req = req.WithContext(withConnectionID(req.Context(), connID))
+		if resolvedIP != nil {
+			req = req.WithContext(withResolvedIP(req.Context(), resolvedIP))
+		}
+		if rewrite != nil {
+			rewrite(req)
+		}
+		logger := m.logger.with(hostAttr(connectURL.Host), connectionIDAttr(connID))
+		start := time.Now()
+		m.stats.Increase(m.hostStats.stat(connectURL.Hostname()))
+
+		fault := m.faults.Load().find(withConnectTarget(req, connectURL))
+		if fault != nil {
+			m.stats.Increase(StatInjectedFaults)
+			logger.Info("injecting fault", "rule", fault.rule.Name)
+
+			if fault.rule.Reset {
+				if !resetConn(srcConn) {
+					logger.Debug("client connection is not TCP, closing it instead of resetting it")
+				}
+				return
+			}
+		}
+
+		shouldIntercept := m.shouldInterceptRequest(withConnectTarget(req, connectURL))
 		var reqID *uuid.UUID
 		var reqBytes []byte
 		var respBytes []byte
 
 		if shouldIntercept {
-			GetStatsService().Increase(StatInterceptedRequests)
+			m.stats.Increase(StatInterceptedRequests)
 
-			req, reqID, err = m.interceptRequestConnect(req, connectURL)
+			hooksStart := time.Now()
+			interceptedReq, id, err := m.interceptRequestConnect(req, connectURL)
+			m.stats.Observe(HistogramHooksLatency, time.Since(hooksStart))
 			if err != nil {
-				log.Printf("intercept request failed: %v", err)
+				proxyErr := newProxyError(connectURL.Hostname(), err)
+				if id != nil {
+					proxyErr.RequestID = *id
+					logger = logger.with(requestIDAttr(*id))
+				}
+				m.reportError(logger, "intercept request failed", proxyErr)
+
+				var hookErr *HookError
+				if !errors.As(err, &hookErr) {
+					return
+				}
+
+				// the body has to be consumed before reading the next request
+				io.Copy(io.Discard, req.Body)
+				if writeErrorResponse(srcConn, m.getErrorPage(), req, proxyErr) == nil {
+					continue
+				}
 				return
 			}
+			req, reqID = interceptedReq, id
+			logger = logger.with(requestIDAttr(*reqID))
 		}
 
-		reqBytes, err = httputil.DumpRequest(req, true)
-		if err != nil {
-			log.Printf("could not dump request: %v", err)
-			return
+		upstreamStart := time.Now()
+		var resp *http.Response
+		if fault != nil && fault.rule.StatusCode != 0 {
+			// the body has to be consumed before reading the next request
+			io.Copy(io.Discard, req.Body)
+			resp = fault.response(req)
+		} else {
+			resp, err = m.mapRequest(withConnectTarget(req, connectURL), logger)
+			if err != nil {
+				m.upstreamError(srcConn, logger, "could not serve mapped request", req, reqID, err)
+				return
+			}
 		}
 
-		_, err = io.Copy(destConn, bytes.NewReader(reqBytes))
-		if err != nil {
-			log.Printf("could not send request bytes to destination: %v", err)
-			return
-		}
+		if resp == nil {
+			reqBytes, err = httputil.DumpRequest(req, true)
+			if err != nil {
+				if isTimeout(err) {
+					m.stats.Increase(StatClientTimeouts)
+					writeStatusResponse(srcConn, http.StatusRequestTimeout)
+				}
+				logger.Error("could not dump request", errAttr(err))
+				return
+			}
+			srcConn.SetReadDeadline(time.Time{})
 
-		resp, err := http.ReadResponse(destBufReader, req)
-		if err != nil {
-			if err != io.EOF {
-				log.Printf("could not read response: %s: %v", req.URL.String(), err)
+			reusable = false
+			n, err := io.Copy(destConn, bytes.NewReader(reqBytes))
+			m.stats.Add(StatBytesToUpstream, int(n))
+			if err != nil {
+				m.upstreamError(srcConn, logger, "could not send request to destination", req, reqID, err)
+				return
 			}
-			return
+
+			destConn.SetReadDeadline(deadline(limits.ResponseHeaderTimeout))
+			resp, err = http.ReadResponse(destBufReader, req)
+			destConn.SetReadDeadline(time.Time{})
+			if err != nil {
+				if err != io.EOF {
+					m.upstreamError(srcConn, logger, "could not read response", req, reqID, err)
+				}
+				return
+			}
+		} else {
+			srcConn.SetReadDeadline(time.Time{})
 		}
+		m.stats.Observe(HistogramUpstreamLatency, time.Since(upstreamStart))
+		m.stats.Increase(StatusStat(resp.StatusCode))
 
 		// TODO: also implement response filters
 		if shouldIntercept {
@@ -139,31 +355,66 @@ func (m *mitm) requestInspect(w http.ResponseWriter, r *http.Request) {
 			//	Comment: apparently this works fine, because it looks like http.ReadResponse
 			//	body reads the bytes until it finds a \r\n\r\n, then the passthrough
 			//	implemented below handles the rest of the data correctly
-			GetStatsService().Increase(StatInterceptedResponses)
+			m.stats.Increase(StatInterceptedResponses)
 
-			resp, err = m.interceptResponse(resp, *reqID)
+			hooksStart := time.Now()
+			interceptedResp, err := m.interceptResponse(resp, *reqID)
+			m.stats.Observe(HistogramHooksLatency, time.Since(hooksStart))
 			if err != nil {
-				log.Printf("intercept response failed: %v", err)
+				proxyErr := newProxyError(connectURL.Hostname(), err)
+				proxyErr.RequestID = *reqID
+				m.reportError(logger, "intercept response failed", proxyErr)
+
+				var hookErr *HookError
+				if !errors.As(err, &hookErr) {
+					return
+				}
+
+				// the body has to be consumed before reading the next response
+				// from the destination
+				_, err = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				if err != nil {
+					return
+				}
+
+				if writeErrorResponse(srcConn, m.getErrorPage(), req, proxyErr) == nil {
+					continue
+				}
 				return
 			}
+			resp = interceptedResp
 		}
 
 		respBytes, err = httputil.DumpResponse(resp, true)
 		if err != nil {
-			log.Printf("could not dump response: %v", err)
+			logger.Error("could not dump response", errAttr(err))
 			return
 		}
 
-		_, err = io.Copy(srcConn, bytes.NewReader(respBytes))
+		if fault != nil && fault.rule.Latency > 0 {
+			time.Sleep(fault.rule.Latency)
+		}
+
+		n, err := fault.write(srcConn, respBytes)
+		m.stats.Add(StatBytesToClient, int(n))
 		if err != nil {
-			log.Printf("could not send response bytes to client: %v", err)
+			logger.Warn("could not send response to client", errAttr(err))
+			m.stats.Increase(ErrorStat(ErrorKindClient))
+			return
+		}
+		m.stats.Observe(HistogramTotalLatency, time.Since(start))
+
+		if fault != nil && fault.rule.TruncateBody {
 			return
 		}
 
+		reusable = !req.Close && !resp.Close && resp.StatusCode != http.StatusSwitchingProtocols
+
 		if resp.StatusCode == 101 {
-			GetStatsService().Increase(StatUpgradedRequests)
-			GetStatsService().Increase(StatActiveUpgradedRequests)
-			defer GetStatsService().Decrease(StatActiveUpgradedRequests)
+			m.stats.Increase(StatUpgradedRequests)
+			m.stats.Increase(StatActiveUpgradedRequests)
+			defer m.stats.Decrease(StatActiveUpgradedRequests)
 
 			var wg sync.WaitGroup
 			wg.Add(2)
@@ -172,9 +423,11 @@ func (m *mitm) requestInspect(w http.ResponseWriter, r *http.Request) {
 				defer srcConn.Close()
 				defer wg.Done()
 
-				if _, err := io.Copy(destConn, srcBufReader); err != nil {
+				n, err := io.Copy(destConn, srcBufReader)
+				m.stats.Add(StatBytesToUpstream, int(n))
+				if err != nil {
 					if err != io.EOF && !errors.Is(err, net.ErrClosed) {
-						log.Printf("error in upgraded connection sending data from source to destintaion: %v", err)
+						logger.Debug("error in upgraded connection sending data from source to destination", errAttr(err))
 					}
 					return
 				}
@@ -185,9 +438,11 @@ func (m *mitm) requestInspect(w http.ResponseWriter, r *http.Request) {
 				defer srcConn.Close()
 				defer wg.Done()
 
-				if _, err := io.Copy(srcConn, destBufReader); err != nil {
+				n, err := io.Copy(srcConn, destBufReader)
+				m.stats.Add(StatBytesToClient, int(n))
+				if err != nil {
 					if err != io.EOF && !errors.Is(err, net.ErrClosed) {
-						log.Printf("error in upgraded connection sending data from destination to source: %v", err)
+						logger.Debug("error in upgraded connection sending data from destination to source", errAttr(err))
 					}
 					return
 				}
@@ -199,15 +454,19 @@ func (m *mitm) requestInspect(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (m *mitm) requestPassthrough(w http.ResponseWriter, r *http.Request) {
-	srcConn, destConn, err := m.hijack(w, r)
-	if err != nil {
-		// TODO: make errors more specific and check error type to
-		// return correct status if needed
-		w.WriteHeader(http.StatusBadGateway)
-		log.Printf("ERROR: could not hijack connection: %v", err)
-		return
-	}
+// passthroughTunnel copies the data of a tunnel in both directions without
+// inspecting it
+func (m *mitm) passthroughTunnel(srcConn, destConn net.Conn, host string) {
+	m.spliceTunnel(uuid.New(), srcConn, destConn, host)
+}
+
+// spliceTunnel copies the data of a tunnel in both directions and, once
+// both directions are closed, runs the connection hooks
+func (m *mitm) spliceTunnel(connID uuid.UUID, srcConn, destConn net.Conn, host string) {
+	m.stats.Increase(StatPassthroughConnections)
+
+	start := time.Now()
+	var bytesToUpstream, bytesToClient int64
 
 	var wg sync.WaitGroup
 	wg.Add(2)
@@ -216,9 +475,12 @@ func (m *mitm) requestPassthrough(w http.ResponseWriter, r *http.Request) {
 		defer srcConn.Close()
 		defer wg.Done()
 
-		if _, err := io.Copy(destConn, srcConn); err != nil {
+		n, err := io.Copy(destConn, srcConn)
+		bytesToUpstream = n
+		m.stats.Add(StatBytesToUpstream, int(n))
+		if err != nil {
 			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
-				log.Printf("error sending data from source to destintaion: %v", err)
+				m.logger.Debug("error sending data from source to destination", errAttr(err), hostAttr(host))
 			}
 			return
 		}
@@ -229,66 +491,207 @@ func (m *mitm) requestPassthrough(w http.ResponseWriter, r *http.Request) {
 		defer srcConn.Close()
 		defer wg.Done()
 
-		if _, err := io.Copy(srcConn, destConn); err != nil {
+		n, err := io.Copy(srcConn, destConn)
+		bytesToClient = n
+		m.stats.Add(StatBytesToClient, int(n))
+		if err != nil {
 			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
-				log.Printf("error sending data from destination to source: %v", err)
+				m.logger.Debug("error sending data from destination to source", errAttr(err), hostAttr(host))
 			}
 			return
 		}
 	}()
 
 	wg.Wait()
+
+	m.hooksMutex.Lock()
+	hooks := m.hooks
+	m.hooksMutex.Unlock()
+
+	hooks.RunConnectionHooks(&PassthroughConnection{
+		ConnectionID:    connID,
+		Host:            host,
+		BytesToUpstream: bytesToUpstream,
+		BytesToClient:   bytesToClient,
+		Start:           start,
+		Duration:        time.Since(start),
+	})
 }
 
-func (m *mitm) hijack(w http.ResponseWriter, r *http.Request) (*tls.Conn, *tls.Conn, error) {
+// hijack connects to the destination of a CONNECT request and then takes
+// over the client connection, establishing TLS with both sides. The
+// client only gets the 200 response if the destination is reachable; on
+// errors nothing has been written to w yet, unless the connection was
+// already hijacked
+func (m *mitm) hijack(w http.ResponseWriter, r *http.Request) (*clientTLSConn, *pooledConn, error) {
+	domain := r.URL.Hostname()
+
+	destConn, err := m.dialPooled(r.Context(), r.URL.Host, domain)
+	if err != nil {
+		return nil, nil, newProxyError(domain, fmt.Errorf("could not connect to destination: %w", err))
+	}
+
+	cert, err := m.ca.GetCertificateFor(domain)
+	if err != nil {
+		destConn.Close()
+		m.stats.Increase(ErrorStat(ErrorKindCertificate))
+		return nil, nil, fmt.Errorf("could not create certificate: %w", err)
+	}
+
+	conn, err := m.hijackClient(w)
+	if err != nil {
+		destConn.Close()
+		return nil, nil, err
+	}
+
+	return newClientTLSConn(conn, cert), destConn, nil
+}
+
+// hijackClient takes over the client connection of a CONNECT request and
+// answers it with a 200 response
+func (m *mitm) hijackClient(w http.ResponseWriter) (net.Conn, error) {
 	hj, ok := w.(http.Hijacker)
 	if !ok {
-		return nil, nil, fmt.Errorf("Server does not support connection hijacking")
+		return nil, fmt.Errorf("Server does not support connection hijacking")
 	}
 
 	conn, buf, err := hj.Hijack()
-	_ = buf
 	if err != nil {
-		return nil, nil, fmt.Errorf("Connection hijacking failed: %v", err)
+		return nil, fmt.Errorf("Connection hijacking failed: %w", err)
 	}
 
-	if _, err := conn.Write([]byte("HTTP/1.1 200 OK\r\n\r\n")); err != nil {
-		return nil, nil, fmt.Errorf("error writing status to client: %v", err)
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		conn.Close()
+		m.stats.Increase(ErrorStat(ErrorKindClient))
+		return nil, &hijackedError{fmt.Errorf("error writing status to client: %w", err)}
 	}
 
-	domain := r.URL.Hostname()
-	cert, err := m.ca.GetCertificateFor(domain)
+	// the client may have sent data, such as the TLS ClientHello, without
+	// waiting for the response; it is already in the buffer of Hijack
+	return newBufferedConn(conn, buf.Reader), nil
+}
+
+// requestPassthrough connects the client of a CONNECT request with its
+// destination without terminating TLS, so certificate pinning and client
+// certificates keep working
+func (m *mitm) requestPassthrough(w http.ResponseWriter, r *http.Request) {
+	destConn, err := m.dial(r.Context(), r.URL.Host)
 	if err != nil {
-		return nil, nil, fmt.Errorf("could not create certificate: %v", err)
+		m.connectError(w, r, newProxyError(r.URL.Hostname(), fmt.Errorf("could not connect to destination: %w", err)))
+		return
 	}
-	srcConn := tls.Server(conn, &tls.Config{
-		Certificates: []tls.Certificate{cert},
-	})
 
-	// force http/1.1 requests
-	destConn, err := tls.Dial("tcp", r.URL.Host, &tls.Config{
-		NextProtos:         []string{"http/1.1"},
-		ServerName:         domain,
-		InsecureSkipVerify: true,
-	})
+	srcConn, err := m.hijackClient(w)
 	if err != nil {
-		return nil, nil, fmt.Errorf("could not connect to destination: %v", err)
+		destConn.Close()
+		m.connectError(w, r, err)
+		return
+	}
+
+	m.passthroughTunnel(srcConn, destConn, r.URL.Host)
+}
+
+// hijackedError is returned by hijack when the client connection was
+// already taken over, so no response can be written to it
+type hijackedError struct {
+	err error
+}
+
+func (e *hijackedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *hijackedError) Unwrap() error {
+	return e.err
+}
+
+// connectError reports an error of hijack and answers the CONNECT request
+// if it is still possible
+func (m *mitm) connectError(w http.ResponseWriter, r *http.Request, err error) {
+	logger := m.logger.with(hostAttr(r.URL.Host))
+
+	var proxyErr *ProxyError
+	if errors.As(err, &proxyErr) {
+		m.reportError(logger, "could not connect to destination", proxyErr)
+		serveErrorResponse(w, m.getErrorPage(), r, proxyErr)
+		return
 	}
 
-	return srcConn, destConn, nil
+	logger.Error("could not hijack connection", errAttr(err))
+
+	var hjErr *hijackedError
+	if !errors.As(err, &hjErr) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// bufferedConn is a net.Conn that first returns the bytes already read
+// into a buffer
+type bufferedConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func newBufferedConn(conn net.Conn, r *bufio.Reader) net.Conn {
+	if r == nil || r.Buffered() == 0 {
+		return conn
+	}
+
+	return &bufferedConn{
+		Conn:   conn,
+		reader: r,
+	}
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *bufferedConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// upstreamError reports an error communicating with the destination of a
+// tunnel and writes the error page to the client
+func (m *mitm) upstreamError(srcConn io.Writer, logger *eventLogger, msg string, req *http.Request, reqID *uuid.UUID, err error) {
+	proxyErr := newProxyError(req.URL.Hostname(), err)
+	if proxyErr.Host == "" {
+		proxyErr.Host = req.Host
+	}
+	if reqID != nil {
+		proxyErr.RequestID = *reqID
+	}
+
+	m.reportError(logger, msg, proxyErr)
+	writeErrorResponse(srcConn, m.getErrorPage(), req, proxyErr)
 }
 
 func (m *mitm) servePlainRequest(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	m.stats.Increase(m.hostStats.stat(r.URL.Hostname()))
+
 	request := r.Clone(r.Context())
 	request.RequestURI = ""
+	request.Header.Del("Proxy-Authorization")
+
+	// the IP of the server is only known for direct connections
+	if proxyURL, _ := m.upstreamProxyFor(request); proxyURL == nil {
+		request = withResolvedIPTrace(request)
+	}
 
-	response, err := m.client.Do(request)
+	response, err := m.mapRequest(request, m.logger.with(hostAttr(r.URL.Host)))
+	if err == nil && response == nil {
+		response, err = m.client.Load().Do(request)
+	}
 
 	if err != nil {
-		log.Printf("error sending request upstream: %v", err)
-		w.WriteHeader(http.StatusBadGateway)
+		proxyErr := newProxyError(r.URL.Hostname(), err)
+		m.reportError(m.logger.with(hostAttr(r.URL.Host)), "could not send request upstream", proxyErr)
+		serveErrorResponse(w, m.getErrorPage(), r, proxyErr)
 		return
 	}
+	m.stats.Observe(HistogramUpstreamLatency, time.Since(start))
+	m.stats.Increase(StatusStat(response.StatusCode))
 
 	// copy headers
 	wHeader := w.Header()
@@ -301,9 +704,12 @@ func (m *mitm) servePlainRequest(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(response.StatusCode)
 
 	defer response.Body.Close()
-	if _, err := io.Copy(w, response.Body); err != nil {
-		log.Printf("error while sending response body downstream: %v", err)
+	n, err := io.Copy(w, response.Body)
+	m.stats.Add(StatBytesToClient, int(n))
+	if err != nil {
+		m.logger.Warn("could not send response body to client", errAttr(err), hostAttr(r.URL.Host))
 	}
+	m.stats.Observe(HistogramTotalLatency, time.Since(start))
 }
 
 func (m *mitm) interceptRequestConnect(r *http.Request, connectURL *url.URL) (*http.Request, *uuid.UUID, error) {
@@ -323,13 +729,13 @@ func (m *mitm) interceptRequestConnect(r *http.Request, connectURL *url.URL) (*h
 	}
 	origHost := req.Host
 
+	scheme := tunnelScheme(connectURL)
 	connectPort := connectURL.Port()
 	connectDomain := connectURL.Hostname()
-	// TODO: do not assume https
-	req.URL.Scheme = "https"
+	req.URL.Scheme = scheme
 	req.URL.Host = connectDomain
 	req.Host = connectDomain
-	if connectPort != "443" && connectPort != "" {
+	if connectPort != "" && connectPort != strconv.Itoa(portOrDefault("", scheme)) {
 		req.URL.Host += ":" + connectPort
 	}
 
@@ -337,7 +743,7 @@ func (m *mitm) interceptRequestConnect(r *http.Request, connectURL *url.URL) (*h
 	modHost := req.Host
 
 	if err := hooks.RunRequestHooks(req, id); err != nil {
-		return nil, nil, err
+		return nil, &id, err
 	}
 
 	// if hooks did not make any modification to the url
@@ -364,13 +770,16 @@ func (m *mitm) interceptResponse(r *http.Response, id uuid.UUID) (*http.Response
 }
 
 func (m *mitm) shouldInterceptDomain(r *http.Request) bool {
-	domain := r.URL.Hostname()
+	// TODO: do not assume https
+	return m.shouldInterceptHost("https", r.URL.Hostname(), portOrDefault(r.URL.Port(), "https"))
+}
 
+func (m *mitm) shouldInterceptHost(scheme, domain string, port int) bool {
 	m.criteriaMutex.Lock()
 	crit := m.criteria
 	m.criteriaMutex.Unlock()
 
-	return crit.shouldInterceptDomain(domain)
+	return crit.shouldInterceptHost(scheme, domain, port)
 }
 
 func (m *mitm) shouldInterceptRequest(r *http.Request) bool {
@@ -381,6 +790,35 @@ func (m *mitm) shouldInterceptRequest(r *http.Request) bool {
 	return crit.shouldInterceptRequest(r)
 }
 
+// withConnectTarget returns a shallow copy of a request read from a
+// CONNECT tunnel with its URL made absolute, so that criteria can match
+// on the scheme and host of the tunnel
+func withConnectTarget(r *http.Request, connectURL *url.URL) *http.Request {
+	if r.URL.Host != "" {
+		return r
+	}
+
+	u := *r.URL
+	u.Scheme = tunnelScheme(connectURL)
+	u.Host = connectURL.Host
+
+	req := r.WithContext(r.Context())
+	req.URL = &u
+
+	return req
+}
+
+// tunnelScheme returns the scheme of the requests sent through a tunnel.
+// Tunnels of transparent plain HTTP connections have the http scheme
+func tunnelScheme(connectURL *url.URL) string {
+	if connectURL.Scheme != "" {
+		return connectURL.Scheme
+	}
+
+	// TODO: do not assume https
+	return "https"
+}
+
 func (m *mitm) SetCriteria(c *criteria) {
 	m.criteriaMutex.Lock()
 	m.criteria = c
@@ -394,6 +832,15 @@ func (m *mitm) GetCriteria() *criteria {
 	return m.criteria.clone()
 }
 
+// UpdateCriteria replaces the criteria with the result of f while holding
+// the lock, so that concurrent updates are not lost. f must not modify
+// the criteria it receives
+func (m *mitm) UpdateCriteria(f func(*criteria) *criteria) {
+	m.criteriaMutex.Lock()
+	m.criteria = f(m.criteria)
+	m.criteriaMutex.Unlock()
+}
+
 func (m *mitm) SetHooks(h *hooks) {
 	m.hooksMutex.Lock()
 	m.hooks = h
@@ -406,3 +853,12 @@ func (m *mitm) GetHooks() *hooks {
 
 	return m.hooks.clone()
 }
+
+// UpdateHooks replaces the hooks with the result of f while holding the
+// lock, so that concurrent updates are not lost. f must not modify the
+// hooks it receives
+func (m *mitm) UpdateHooks(f func(*hooks) *hooks) {
+	m.hooksMutex.Lock()
+	m.hooks = f(m.hooks)
+	m.hooksMutex.Unlock()
+}
@@ -0,0 +1,65 @@
+//go:build linux
+
+package efincore
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"syscall"
+)
+
+// soOriginalDst is SO_ORIGINAL_DST, and IP6T_SO_ORIGINAL_DST for IPv6,
+// from linux/netfilter_ipv4.h
+const soOriginalDst = 80
+
+func originalDst(conn net.Conn) (string, error) {
+	tcpConn, ok := tcpConnOf(conn)
+	if !ok {
+		return "", errors.New("SO_ORIGINAL_DST requires a TCP connection")
+	}
+
+	rawConn, err := tcpConn.SyscallConn()
+	if err != nil {
+		return "", err
+	}
+
+	isIPv4 := true
+	if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok && addr.IP.To4() == nil {
+		isIPv4 = false
+	}
+
+	var ip net.IP
+	var port int
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		if isIPv4 {
+			// the sockaddr_in fits in the 16 bytes of an ip_mreq
+			var mreq *syscall.IPv6Mreq
+			mreq, sockErr = syscall.GetsockoptIPv6Mreq(int(fd), syscall.IPPROTO_IP, soOriginalDst)
+			if sockErr == nil {
+				port = int(binary.BigEndian.Uint16(mreq.Multiaddr[2:4]))
+				ip = net.IP(mreq.Multiaddr[4:8])
+			}
+			return
+		}
+
+		// the sockaddr_in6 is the first field of ip6_mtuinfo
+		var info *syscall.IPv6MTUInfo
+		info, sockErr = syscall.GetsockoptIPv6MTUInfo(int(fd), syscall.IPPROTO_IPV6, soOriginalDst)
+		if sockErr == nil {
+			// the port is stored in network byte order
+			port = int(binary.BigEndian.Uint16(binary.NativeEndian.AppendUint16(nil, info.Addr.Port)))
+			ip = net.IP(info.Addr.Addr[:])
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	if sockErr != nil {
+		return "", sockErr
+	}
+
+	return net.JoinHostPort(ip.String(), strconv.Itoa(port)), nil
+}
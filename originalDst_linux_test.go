@@ -0,0 +1,44 @@
+//go:build linux
+
+package efincore
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestTransparent_SOOriginalDst_ReadsSocketOptionOfLimitedConn(t *testing.T) {
+	server := newTestServer(func(w http.ResponseWriter, r *http.Request) {})
+	defer server.Close()
+
+	errs := make(chan error, 1)
+	origDst := OriginalDestinationFunc(func(conn net.Conn) (string, error) {
+		dst, err := SOOriginalDst.OriginalDestination(conn)
+		errs <- err
+		return dst, err
+	})
+
+	_, addr := runTestTransparentProxy(t, origDst)
+
+	resp, err := newTestTransparentClient(addr).Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	select {
+	case err := <-errs:
+		// connections that were not redirected have no original destination
+		// unless they are tracked by netfilter, but the option must be read
+		var errno syscall.Errno
+		if err != nil && !errors.As(err, &errno) {
+			t.Errorf("original destination error: got '%v', expected a socket option error", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("original destination not requested")
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package efincore
+
+import (
+	"errors"
+	"net"
+)
+
+func originalDst(conn net.Conn) (string, error) {
+	return "", errors.New("SO_ORIGINAL_DST is only supported on linux")
+}
@@ -0,0 +1,214 @@
+package efincore
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultPassthroughThreshold is the number of TLS handshakes in which
+// clients reject the certificate of the proxy after which a host is passed
+// through
+const DefaultPassthroughThreshold = 3
+
+// PassthroughHost describes a host whose clients rejected the certificate
+// of the proxy, usually because they pin the certificate of the server
+type PassthroughHost struct {
+	Host          string    `json:"host"`
+	Failures      int       `json:"failures"`
+	LastFailure   time.Time `json:"last_failure"`
+	LastError     string    `json:"last_error"`
+	PassedThrough bool      `json:"passed_through"`
+}
+
+// passthroughHosts holds the hosts whose connections are passed through
+// without terminating TLS, because their clients rejected the certificate
+// too many times or because they match a pinned host pattern
+type passthroughHosts struct {
+	hosts      map[string]*PassthroughHost
+	hostsMutex *sync.Mutex
+
+	threshold *atomic.Int64
+	pinned    *atomic.Pointer[[]compiledScopeRule]
+}
+
+func newPassthroughHosts() *passthroughHosts {
+	p := &passthroughHosts{
+		hosts:      map[string]*PassthroughHost{},
+		hostsMutex: &sync.Mutex{},
+
+		threshold: &atomic.Int64{},
+		pinned:    &atomic.Pointer[[]compiledScopeRule]{},
+	}
+	p.threshold.Store(DefaultPassthroughThreshold)
+
+	return p
+}
+
+// recordFailure counts a certificate rejection for host and reports
+// whether it made the host reach the threshold
+func (p *passthroughHosts) recordFailure(host string, err error) bool {
+	host = strings.ToLower(host)
+	threshold := p.threshold.Load()
+
+	p.hostsMutex.Lock()
+	defer p.hostsMutex.Unlock()
+
+	h, ok := p.hosts[host]
+	if !ok {
+		h = &PassthroughHost{Host: host}
+		p.hosts[host] = h
+	}
+
+	h.Failures++
+	h.LastFailure = time.Now()
+	h.LastError = err.Error()
+
+	if h.PassedThrough || threshold <= 0 || int64(h.Failures) < threshold {
+		return false
+	}
+
+	h.PassedThrough = true
+	return true
+}
+
+func (p *passthroughHosts) contains(host string) bool {
+	host = strings.ToLower(host)
+
+	if pinned := p.pinned.Load(); pinned != nil {
+		for _, rule := range *pinned {
+			if rule.matchesHost("", host, 0) {
+				return true
+			}
+		}
+	}
+
+	p.hostsMutex.Lock()
+	defer p.hostsMutex.Unlock()
+
+	h, ok := p.hosts[host]
+	return ok && h.PassedThrough
+}
+
+// list returns the hosts with failed handshakes sorted by name
+func (p *passthroughHosts) list() []PassthroughHost {
+	p.hostsMutex.Lock()
+	result := make([]PassthroughHost, 0, len(p.hosts))
+	for _, h := range p.hosts {
+		result = append(result, *h)
+	}
+	p.hostsMutex.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Host < result[j].Host
+	})
+
+	return result
+}
+
+// reset forgets the failed handshakes of host, so it is inspected again,
+// and reports whether it was passed through
+func (p *passthroughHosts) reset(host string) bool {
+	host = strings.ToLower(host)
+
+	p.hostsMutex.Lock()
+	defer p.hostsMutex.Unlock()
+
+	h, ok := p.hosts[host]
+	if !ok {
+		return false
+	}
+
+	delete(p.hosts, host)
+	return h.PassedThrough
+}
+
+func (p *passthroughHosts) setThreshold(n int) {
+	p.threshold.Store(int64(n))
+}
+
+func (p *passthroughHosts) setPinned(patterns []string) error {
+	rules := make([]compiledScopeRule, 0, len(patterns))
+	for _, pattern := range patterns {
+		if pattern == "" {
+			return fmt.Errorf("empty pinned host pattern")
+		}
+
+		rule, err := compileScopeRule(ScopeRule{Host: pattern})
+		if err != nil {
+			return fmt.Errorf("invalid pinned host pattern '%s': %w", pattern, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	p.pinned.Store(&rules)
+	return nil
+}
+
+func (p *passthroughHosts) pinnedPatterns() []string {
+	pinned := p.pinned.Load()
+	if pinned == nil {
+		return []string{}
+	}
+
+	patterns := make([]string, 0, len(*pinned))
+	for _, rule := range *pinned {
+		patterns = append(patterns, rule.rule.Host)
+	}
+
+	return patterns
+}
+
+// SetPassthroughThreshold sets the number of certificate rejections by
+// clients after which a host is passed through. A threshold of zero or
+// less disables the automatic passthrough
+func (m *mitm) SetPassthroughThreshold(n int) {
+	m.passthroughHosts.setThreshold(n)
+}
+
+// PassthroughHosts returns the hosts whose clients rejected the
+// certificate, including the ones that did not reach the threshold yet
+func (m *mitm) PassthroughHosts() []PassthroughHost {
+	return m.passthroughHosts.list()
+}
+
+// ResetPassthroughHost forgets the failed handshakes of host, so its
+// connections are inspected again
+func (m *mitm) ResetPassthroughHost(host string) {
+	if m.passthroughHosts.reset(host) {
+		m.stats.Decrease(StatPassthroughHosts)
+	}
+}
+
+// SetPinnedHosts sets the patterns of the hosts that are always passed
+// through, like the ones of apps known to pin their certificates. Patterns
+// are host globs or CIDRs, as in ScopeRule.Host
+func (m *mitm) SetPinnedHosts(patterns []string) error {
+	return m.passthroughHosts.setPinned(patterns)
+}
+
+func (m *mitm) PinnedHosts() []string {
+	return m.passthroughHosts.pinnedPatterns()
+}
+
+// certificateRejectionAlerts are the TLS alerts sent by clients that do not
+// trust a certificate (RFC 8446, section 6)
+var certificateRejectionAlerts = []uint8{
+	42, // bad_certificate
+	43, // unsupported_certificate
+	44, // certificate_revoked
+	45, // certificate_expired
+	46, // certificate_unknown
+	48, // unknown_ca
+}
+
+// isCertificateRejection reports whether a failed TLS handshake with a
+// client was caused by the client rejecting the certificate
+func isCertificateRejection(err error) bool {
+	alert, ok := remoteTLSAlert(err)
+	return ok && slices.Contains(certificateRejectionAlerts, alert)
+}
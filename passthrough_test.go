@@ -0,0 +1,168 @@
+package efincore
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/artilugio0/efincore/proto"
+)
+
+func TestIsCertificateRejection(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{&net.OpError{Op: "remote error", Err: tls.AlertError(48)}, true},
+		{fmt.Errorf("handshake: %w", &net.OpError{Op: "remote error", Err: tls.AlertError(42)}), true},
+		{&net.OpError{Op: "remote error", Err: tls.AlertError(40)}, false},
+		{errors.New("remote error: tls: bad certificate"), false},
+		{testRemoteTLSAlert(t), false},
+		{io.EOF, false},
+	}
+
+	for _, tt := range tests {
+		if got := isCertificateRejection(tt.err); got != tt.expected {
+			t.Errorf("certificate rejection of '%v': got '%t', expected '%t'", tt.err, got, tt.expected)
+		}
+	}
+}
+
+func TestPassthroughHosts_PinnedPatterns(t *testing.T) {
+	p := newPassthroughHosts()
+	if err := p.setPinned([]string{"*.bank.example", "api.example.com"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		host     string
+		expected bool
+	}{
+		{"app.bank.example", true},
+		{"API.example.com", true},
+		{"bank.example", false},
+		{"www.example.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := p.contains(tt.host); got != tt.expected {
+			t.Errorf("contains '%s': got '%t', expected '%t'", tt.host, got, tt.expected)
+		}
+	}
+
+	if err := p.setPinned([]string{""}); err == nil {
+		t.Errorf("empty pattern accepted")
+	}
+}
+
+func TestPassthroughHosts_ThresholdDisabled(t *testing.T) {
+	p := newPassthroughHosts()
+	p.setThreshold(0)
+
+	for i := 0; i < 10; i++ {
+		if p.recordFailure("example.com", io.EOF) {
+			t.Fatalf("host passed through with threshold disabled")
+		}
+	}
+
+	if p.contains("example.com") {
+		t.Errorf("host passed through with threshold disabled")
+	}
+
+	if got := p.list(); len(got) != 1 || got[0].Failures != 10 {
+		t.Errorf("passthrough hosts: got '%+v', expected '%d' failures", got, 10)
+	}
+}
+
+func TestGRPCServerPassthroughHosts(t *testing.T) {
+	proxy := NewProxy("127.0.0.1:0")
+	proxy.SetPassthroughThreshold(1)
+	proxy.mitm.passthroughHosts.recordFailure("example.com", io.EOF)
+
+	server := NewGRPCServer("127.0.0.1:0")
+	server.SetPassthroughService(proxy)
+
+	if _, err := server.SetPinnedHosts(context.Background(), &proto.SetPinnedHostsInput{Patterns: []string{"*.pinned.example"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out, err := server.GetPassthroughHosts(context.Background(), &proto.GetPassthroughHostsInput{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(out.Hosts) != 1 || out.Hosts[0].Host != "example.com" || !out.Hosts[0].PassedThrough {
+		t.Errorf("passthrough hosts: got '%v', expected '%s' passed through", out.Hosts, "example.com")
+	}
+
+	if len(out.PinnedHosts) != 1 || out.PinnedHosts[0] != "*.pinned.example" {
+		t.Errorf("pinned hosts: got '%v', expected '%v'", out.PinnedHosts, []string{"*.pinned.example"})
+	}
+
+	if _, err := server.ResetPassthroughHost(context.Background(), &proto.ResetPassthroughHostInput{Host: "example.com"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := proxy.PassthroughHosts(); len(got) != 0 {
+		t.Errorf("passthrough hosts after reset: got '%+v', expected none", got)
+	}
+
+	if _, err := server.SetPinnedHosts(context.Background(), &proto.SetPinnedHostsInput{Patterns: []string{""}}); err == nil {
+		t.Errorf("invalid pattern accepted")
+	}
+}
+
+func TestExcludedDomain_IsPassedThroughWithoutTerminatingTLS(t *testing.T) {
+	proxy := runTestProxy(t)
+	proxy.SetDomainRegex(regexp.MustCompile(`^intercepted\.example$`))
+
+	conns := make(chan *PassthroughConnection, 1)
+	proxy.AddConnectionHook(HookConnectionReadFunc(func(c *PassthroughConnection) error {
+		conns <- c
+		return nil
+	}))
+
+	target := runTestTLSEchoServer(t)
+	tlsConn, err := testTLSTunnel(t, proxy, target, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("TLS handshake failed: %v", err)
+	}
+
+	// the certificate is the one of the server, not one forged by the proxy
+	if names := tlsConn.ConnectionState().PeerCertificates[0].DNSNames; len(names) == 0 || names[0] != "example.com" {
+		t.Errorf("certificate names: got '%v', expected '%v'", names, []string{"example.com"})
+	}
+
+	if _, err := tlsConn.Write([]byte("ping")); err != nil {
+		t.Fatalf("could not write to tunnel: %v", err)
+	}
+
+	got := make([]byte, 4)
+	if _, err := io.ReadFull(tlsConn, got); err != nil {
+		t.Fatalf("could not read from tunnel: %v", err)
+	}
+	tlsConn.Close()
+
+	select {
+	case c := <-conns:
+		if c.Host != target {
+			t.Errorf("connection host: got '%s', expected '%s'", c.Host, target)
+		}
+
+		if c.BytesToUpstream == 0 || c.BytesToClient == 0 {
+			t.Errorf("connection bytes: got '%d' to upstream and '%d' to client, expected more than 0", c.BytesToUpstream, c.BytesToClient)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("connection hook not called")
+	}
+
+	if got := proxy.GetStats()[StatPassthroughConnections]; got != 1 {
+		t.Errorf("passthrough connections: got '%d', expected '%d'", got, 1)
+	}
+}
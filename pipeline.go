@@ -0,0 +1,111 @@
+package efincore
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type PipelineFilter struct {
+	FilterInfo
+	Accepts bool
+}
+
+// Pipeline describes how the proxy would process a given request
+type Pipeline struct {
+	Method string
+	URL    string
+
+	InterceptHost    bool
+	InScope          bool
+	Filters          []PipelineFilter
+	InterceptRequest bool
+
+	// Stages contains the hooks of each stage in execution order. Hooks
+	// of read stages run concurrently
+	Stages map[HookKind][]HookInfo
+}
+
+func (p *Proxy) Pipeline(r *http.Request) Pipeline {
+	crit := p.mitm.GetCriteria()
+	hooks := p.mitm.GetHooks()
+
+	scheme, host, port := requestTarget(r)
+
+	result := Pipeline{
+		Method:        r.Method,
+		URL:           r.URL.String(),
+		InterceptHost: crit.shouldInterceptHost(scheme, host, port),
+		InScope:       crit.scope.InScope(r),
+		Stages:        map[HookKind][]HookInfo{},
+	}
+
+	result.InterceptRequest = result.InterceptHost && result.InScope
+	for _, f := range crit.requestFilters {
+		accepts := f.filter.ShouldInterceptRequest(r)
+		result.Filters = append(result.Filters, PipelineFilter{
+			FilterInfo: FilterInfo{
+				ID:          f.id,
+				Name:        f.name,
+				Description: f.description,
+				Enabled:     f.enabled,
+			},
+			Accepts: accepts,
+		})
+
+		if f.enabled && !accepts {
+			result.InterceptRequest = false
+		}
+	}
+
+	for _, h := range hooks.List() {
+		result.Stages[h.Kind] = append(result.Stages[h.Kind], h)
+	}
+
+	return result
+}
+
+func (pl Pipeline) String() string {
+	b := &strings.Builder{}
+
+	fmt.Fprintf(b, "%s %s\n", pl.Method, pl.URL)
+	fmt.Fprintf(b, "intercept host: %t\n", pl.InterceptHost)
+	fmt.Fprintf(b, "in scope: %t\n", pl.InScope)
+	for _, f := range pl.Filters {
+		fmt.Fprintf(b, "filter '%s' (id %d%s): accepts %t\n", f.Name, f.ID, disabledSuffix(f.Enabled), f.Accepts)
+	}
+	fmt.Fprintf(b, "intercept request: %t\n", pl.InterceptRequest)
+
+	if !pl.InterceptRequest {
+		return b.String()
+	}
+
+	stages := []HookKind{
+		HookKindRequestIn,
+		HookKindRequestMod,
+		HookKindRequestOut,
+		HookKindResponseIn,
+		HookKindResponseMod,
+		HookKindResponseOut,
+		HookKindError,
+		HookKindStream,
+		HookKindConnection,
+	}
+
+	for _, kind := range stages {
+		fmt.Fprintf(b, "%s:\n", kind)
+		for i, h := range pl.Stages[kind] {
+			fmt.Fprintf(b, "  %d. '%s' (id %d, priority %d%s)\n", i+1, h.Name, h.ID, h.Priority, disabledSuffix(h.Enabled))
+		}
+	}
+
+	return b.String()
+}
+
+func disabledSuffix(enabled bool) string {
+	if enabled {
+		return ""
+	}
+
+	return ", disabled"
+}
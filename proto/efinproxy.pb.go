@@ -521,6 +521,1249 @@ func (x *GetStatsOutput) GetStats() []*Stat {
 	return nil
 }
 
+type WatchStatsInput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	IntervalMs uint32 `protobuf:"varint,1,opt,name=interval_ms,json=intervalMs,proto3" json:"interval_ms,omitempty"`
+	OnChange   bool   `protobuf:"varint,2,opt,name=on_change,json=onChange,proto3" json:"on_change,omitempty"`
+}
+
+func (x *WatchStatsInput) Reset() {
+	*x = WatchStatsInput{}
+	mi := &file_efinproxy_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchStatsInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchStatsInput) ProtoMessage() {}
+
+func (x *WatchStatsInput) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchStatsInput.ProtoReflect.Descriptor instead.
+func (*WatchStatsInput) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *WatchStatsInput) GetIntervalMs() uint32 {
+	if x != nil {
+		return x.IntervalMs
+	}
+	return 0
+}
+
+func (x *WatchStatsInput) GetOnChange() bool {
+	if x != nil {
+		return x.OnChange
+	}
+	return false
+}
+
+type StatRate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name      string  `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Delta     int64   `protobuf:"varint,2,opt,name=delta,proto3" json:"delta,omitempty"`
+	PerSecond float64 `protobuf:"fixed64,3,opt,name=per_second,json=perSecond,proto3" json:"per_second,omitempty"`
+}
+
+func (x *StatRate) Reset() {
+	*x = StatRate{}
+	mi := &file_efinproxy_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatRate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatRate) ProtoMessage() {}
+
+func (x *StatRate) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatRate.ProtoReflect.Descriptor instead.
+func (*StatRate) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *StatRate) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *StatRate) GetDelta() int64 {
+	if x != nil {
+		return x.Delta
+	}
+	return 0
+}
+
+func (x *StatRate) GetPerSecond() float64 {
+	if x != nil {
+		return x.PerSecond
+	}
+	return 0
+}
+
+type StatsUpdate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TimestampMs int64       `protobuf:"varint,1,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
+	Stats       []*Stat     `protobuf:"bytes,2,rep,name=stats,proto3" json:"stats,omitempty"`
+	Rates       []*StatRate `protobuf:"bytes,3,rep,name=rates,proto3" json:"rates,omitempty"`
+}
+
+func (x *StatsUpdate) Reset() {
+	*x = StatsUpdate{}
+	mi := &file_efinproxy_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsUpdate) ProtoMessage() {}
+
+func (x *StatsUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsUpdate.ProtoReflect.Descriptor instead.
+func (*StatsUpdate) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *StatsUpdate) GetTimestampMs() int64 {
+	if x != nil {
+		return x.TimestampMs
+	}
+	return 0
+}
+
+func (x *StatsUpdate) GetStats() []*Stat {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+func (x *StatsUpdate) GetRates() []*StatRate {
+	if x != nil {
+		return x.Rates
+	}
+	return nil
+}
+
+type GetErrorsInput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *GetErrorsInput) Reset() {
+	*x = GetErrorsInput{}
+	mi := &file_efinproxy_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetErrorsInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetErrorsInput) ProtoMessage() {}
+
+func (x *GetErrorsInput) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetErrorsInput.ProtoReflect.Descriptor instead.
+func (*GetErrorsInput) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{13}
+}
+
+type ProxyError struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	RequestId  string `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	Kind       string `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	Host       string `protobuf:"bytes,3,opt,name=host,proto3" json:"host,omitempty"`
+	Message    string `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	StatusCode uint32 `protobuf:"varint,5,opt,name=status_code,json=statusCode,proto3" json:"status_code,omitempty"`
+}
+
+func (x *ProxyError) Reset() {
+	*x = ProxyError{}
+	mi := &file_efinproxy_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProxyError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProxyError) ProtoMessage() {}
+
+func (x *ProxyError) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProxyError.ProtoReflect.Descriptor instead.
+func (*ProxyError) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ProxyError) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *ProxyError) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *ProxyError) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *ProxyError) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ProxyError) GetStatusCode() uint32 {
+	if x != nil {
+		return x.StatusCode
+	}
+	return 0
+}
+
+type PassthroughHost struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Host          string `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	Failures      uint32 `protobuf:"varint,2,opt,name=failures,proto3" json:"failures,omitempty"`
+	LastFailureMs int64  `protobuf:"varint,3,opt,name=last_failure_ms,json=lastFailureMs,proto3" json:"last_failure_ms,omitempty"`
+	LastError     string `protobuf:"bytes,4,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	PassedThrough bool   `protobuf:"varint,5,opt,name=passed_through,json=passedThrough,proto3" json:"passed_through,omitempty"`
+}
+
+func (x *PassthroughHost) Reset() {
+	*x = PassthroughHost{}
+	mi := &file_efinproxy_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PassthroughHost) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PassthroughHost) ProtoMessage() {}
+
+func (x *PassthroughHost) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PassthroughHost.ProtoReflect.Descriptor instead.
+func (*PassthroughHost) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *PassthroughHost) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *PassthroughHost) GetFailures() uint32 {
+	if x != nil {
+		return x.Failures
+	}
+	return 0
+}
+
+func (x *PassthroughHost) GetLastFailureMs() int64 {
+	if x != nil {
+		return x.LastFailureMs
+	}
+	return 0
+}
+
+func (x *PassthroughHost) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+func (x *PassthroughHost) GetPassedThrough() bool {
+	if x != nil {
+		return x.PassedThrough
+	}
+	return false
+}
+
+type GetPassthroughHostsInput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *GetPassthroughHostsInput) Reset() {
+	*x = GetPassthroughHostsInput{}
+	mi := &file_efinproxy_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPassthroughHostsInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPassthroughHostsInput) ProtoMessage() {}
+
+func (x *GetPassthroughHostsInput) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPassthroughHostsInput.ProtoReflect.Descriptor instead.
+func (*GetPassthroughHostsInput) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{16}
+}
+
+type GetPassthroughHostsOutput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Hosts       []*PassthroughHost `protobuf:"bytes,1,rep,name=hosts,proto3" json:"hosts,omitempty"`
+	PinnedHosts []string           `protobuf:"bytes,2,rep,name=pinned_hosts,json=pinnedHosts,proto3" json:"pinned_hosts,omitempty"`
+}
+
+func (x *GetPassthroughHostsOutput) Reset() {
+	*x = GetPassthroughHostsOutput{}
+	mi := &file_efinproxy_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPassthroughHostsOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPassthroughHostsOutput) ProtoMessage() {}
+
+func (x *GetPassthroughHostsOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPassthroughHostsOutput.ProtoReflect.Descriptor instead.
+func (*GetPassthroughHostsOutput) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *GetPassthroughHostsOutput) GetHosts() []*PassthroughHost {
+	if x != nil {
+		return x.Hosts
+	}
+	return nil
+}
+
+func (x *GetPassthroughHostsOutput) GetPinnedHosts() []string {
+	if x != nil {
+		return x.PinnedHosts
+	}
+	return nil
+}
+
+type ResetPassthroughHostInput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Host string `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+}
+
+func (x *ResetPassthroughHostInput) Reset() {
+	*x = ResetPassthroughHostInput{}
+	mi := &file_efinproxy_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResetPassthroughHostInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResetPassthroughHostInput) ProtoMessage() {}
+
+func (x *ResetPassthroughHostInput) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResetPassthroughHostInput.ProtoReflect.Descriptor instead.
+func (*ResetPassthroughHostInput) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *ResetPassthroughHostInput) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+type ResetPassthroughHostOutput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ResetPassthroughHostOutput) Reset() {
+	*x = ResetPassthroughHostOutput{}
+	mi := &file_efinproxy_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResetPassthroughHostOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResetPassthroughHostOutput) ProtoMessage() {}
+
+func (x *ResetPassthroughHostOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResetPassthroughHostOutput.ProtoReflect.Descriptor instead.
+func (*ResetPassthroughHostOutput) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{19}
+}
+
+type SetPinnedHostsInput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Patterns []string `protobuf:"bytes,1,rep,name=patterns,proto3" json:"patterns,omitempty"`
+}
+
+func (x *SetPinnedHostsInput) Reset() {
+	*x = SetPinnedHostsInput{}
+	mi := &file_efinproxy_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetPinnedHostsInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetPinnedHostsInput) ProtoMessage() {}
+
+func (x *SetPinnedHostsInput) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetPinnedHostsInput.ProtoReflect.Descriptor instead.
+func (*SetPinnedHostsInput) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *SetPinnedHostsInput) GetPatterns() []string {
+	if x != nil {
+		return x.Patterns
+	}
+	return nil
+}
+
+type SetPinnedHostsOutput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *SetPinnedHostsOutput) Reset() {
+	*x = SetPinnedHostsOutput{}
+	mi := &file_efinproxy_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetPinnedHostsOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetPinnedHostsOutput) ProtoMessage() {}
+
+func (x *SetPinnedHostsOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetPinnedHostsOutput.ProtoReflect.Descriptor instead.
+func (*SetPinnedHostsOutput) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{21}
+}
+
+type ScopeRule struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Action string `protobuf:"bytes,1,opt,name=action,proto3" json:"action,omitempty"`
+	Scheme string `protobuf:"bytes,2,opt,name=scheme,proto3" json:"scheme,omitempty"`
+	Host   string `protobuf:"bytes,3,opt,name=host,proto3" json:"host,omitempty"`
+	Port   uint32 `protobuf:"varint,4,opt,name=port,proto3" json:"port,omitempty"`
+	Path   string `protobuf:"bytes,5,opt,name=path,proto3" json:"path,omitempty"`
+	Method string `protobuf:"bytes,6,opt,name=method,proto3" json:"method,omitempty"`
+}
+
+func (x *ScopeRule) Reset() {
+	*x = ScopeRule{}
+	mi := &file_efinproxy_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScopeRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScopeRule) ProtoMessage() {}
+
+func (x *ScopeRule) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScopeRule.ProtoReflect.Descriptor instead.
+func (*ScopeRule) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *ScopeRule) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *ScopeRule) GetScheme() string {
+	if x != nil {
+		return x.Scheme
+	}
+	return ""
+}
+
+func (x *ScopeRule) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *ScopeRule) GetPort() uint32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+func (x *ScopeRule) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *ScopeRule) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+type MatchReplaceRule struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name     string       `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Disabled bool         `protobuf:"varint,2,opt,name=disabled,proto3" json:"disabled,omitempty"`
+	Target   string       `protobuf:"bytes,3,opt,name=target,proto3" json:"target,omitempty"`
+	Action   string       `protobuf:"bytes,4,opt,name=action,proto3" json:"action,omitempty"`
+	Header   string       `protobuf:"bytes,5,opt,name=header,proto3" json:"header,omitempty"`
+	Match    string       `protobuf:"bytes,6,opt,name=match,proto3" json:"match,omitempty"`
+	Replace  string       `protobuf:"bytes,7,opt,name=replace,proto3" json:"replace,omitempty"`
+	Scope    []*ScopeRule `protobuf:"bytes,8,rep,name=scope,proto3" json:"scope,omitempty"`
+}
+
+func (x *MatchReplaceRule) Reset() {
+	*x = MatchReplaceRule{}
+	mi := &file_efinproxy_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MatchReplaceRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MatchReplaceRule) ProtoMessage() {}
+
+func (x *MatchReplaceRule) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MatchReplaceRule.ProtoReflect.Descriptor instead.
+func (*MatchReplaceRule) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *MatchReplaceRule) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *MatchReplaceRule) GetDisabled() bool {
+	if x != nil {
+		return x.Disabled
+	}
+	return false
+}
+
+func (x *MatchReplaceRule) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *MatchReplaceRule) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *MatchReplaceRule) GetHeader() string {
+	if x != nil {
+		return x.Header
+	}
+	return ""
+}
+
+func (x *MatchReplaceRule) GetMatch() string {
+	if x != nil {
+		return x.Match
+	}
+	return ""
+}
+
+func (x *MatchReplaceRule) GetReplace() string {
+	if x != nil {
+		return x.Replace
+	}
+	return ""
+}
+
+func (x *MatchReplaceRule) GetScope() []*ScopeRule {
+	if x != nil {
+		return x.Scope
+	}
+	return nil
+}
+
+type GetMatchReplaceRulesInput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *GetMatchReplaceRulesInput) Reset() {
+	*x = GetMatchReplaceRulesInput{}
+	mi := &file_efinproxy_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMatchReplaceRulesInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMatchReplaceRulesInput) ProtoMessage() {}
+
+func (x *GetMatchReplaceRulesInput) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMatchReplaceRulesInput.ProtoReflect.Descriptor instead.
+func (*GetMatchReplaceRulesInput) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{24}
+}
+
+type GetMatchReplaceRulesOutput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Rules []*MatchReplaceRule `protobuf:"bytes,1,rep,name=rules,proto3" json:"rules,omitempty"`
+}
+
+func (x *GetMatchReplaceRulesOutput) Reset() {
+	*x = GetMatchReplaceRulesOutput{}
+	mi := &file_efinproxy_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMatchReplaceRulesOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMatchReplaceRulesOutput) ProtoMessage() {}
+
+func (x *GetMatchReplaceRulesOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMatchReplaceRulesOutput.ProtoReflect.Descriptor instead.
+func (*GetMatchReplaceRulesOutput) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *GetMatchReplaceRulesOutput) GetRules() []*MatchReplaceRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+type SetMatchReplaceRulesInput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Rules []*MatchReplaceRule `protobuf:"bytes,1,rep,name=rules,proto3" json:"rules,omitempty"`
+}
+
+func (x *SetMatchReplaceRulesInput) Reset() {
+	*x = SetMatchReplaceRulesInput{}
+	mi := &file_efinproxy_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetMatchReplaceRulesInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetMatchReplaceRulesInput) ProtoMessage() {}
+
+func (x *SetMatchReplaceRulesInput) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetMatchReplaceRulesInput.ProtoReflect.Descriptor instead.
+func (*SetMatchReplaceRulesInput) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *SetMatchReplaceRulesInput) GetRules() []*MatchReplaceRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+type SetMatchReplaceRulesOutput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *SetMatchReplaceRulesOutput) Reset() {
+	*x = SetMatchReplaceRulesOutput{}
+	mi := &file_efinproxy_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetMatchReplaceRulesOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetMatchReplaceRulesOutput) ProtoMessage() {}
+
+func (x *SetMatchReplaceRulesOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetMatchReplaceRulesOutput.ProtoReflect.Descriptor instead.
+func (*SetMatchReplaceRulesOutput) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{27}
+}
+
+type FaultRule struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name           string       `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Disabled       bool         `protobuf:"varint,2,opt,name=disabled,proto3" json:"disabled,omitempty"`
+	Scope          []*ScopeRule `protobuf:"bytes,3,rep,name=scope,proto3" json:"scope,omitempty"`
+	Probability    float64      `protobuf:"fixed64,4,opt,name=probability,proto3" json:"probability,omitempty"`
+	LatencyMs      int64        `protobuf:"varint,5,opt,name=latency_ms,json=latencyMs,proto3" json:"latency_ms,omitempty"`
+	BytesPerSecond int64        `protobuf:"varint,6,opt,name=bytes_per_second,json=bytesPerSecond,proto3" json:"bytes_per_second,omitempty"`
+	Reset_         bool         `protobuf:"varint,7,opt,name=reset,proto3" json:"reset,omitempty"`
+	StatusCode     uint32       `protobuf:"varint,8,opt,name=status_code,json=statusCode,proto3" json:"status_code,omitempty"`
+	TruncateBody   bool         `protobuf:"varint,9,opt,name=truncate_body,json=truncateBody,proto3" json:"truncate_body,omitempty"`
+	TruncateAfter  int64        `protobuf:"varint,10,opt,name=truncate_after,json=truncateAfter,proto3" json:"truncate_after,omitempty"`
+}
+
+func (x *FaultRule) Reset() {
+	*x = FaultRule{}
+	mi := &file_efinproxy_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FaultRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FaultRule) ProtoMessage() {}
+
+func (x *FaultRule) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FaultRule.ProtoReflect.Descriptor instead.
+func (*FaultRule) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *FaultRule) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *FaultRule) GetDisabled() bool {
+	if x != nil {
+		return x.Disabled
+	}
+	return false
+}
+
+func (x *FaultRule) GetScope() []*ScopeRule {
+	if x != nil {
+		return x.Scope
+	}
+	return nil
+}
+
+func (x *FaultRule) GetProbability() float64 {
+	if x != nil {
+		return x.Probability
+	}
+	return 0
+}
+
+func (x *FaultRule) GetLatencyMs() int64 {
+	if x != nil {
+		return x.LatencyMs
+	}
+	return 0
+}
+
+func (x *FaultRule) GetBytesPerSecond() int64 {
+	if x != nil {
+		return x.BytesPerSecond
+	}
+	return 0
+}
+
+func (x *FaultRule) GetReset_() bool {
+	if x != nil {
+		return x.Reset_
+	}
+	return false
+}
+
+func (x *FaultRule) GetStatusCode() uint32 {
+	if x != nil {
+		return x.StatusCode
+	}
+	return 0
+}
+
+func (x *FaultRule) GetTruncateBody() bool {
+	if x != nil {
+		return x.TruncateBody
+	}
+	return false
+}
+
+func (x *FaultRule) GetTruncateAfter() int64 {
+	if x != nil {
+		return x.TruncateAfter
+	}
+	return 0
+}
+
+type GetFaultRulesInput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *GetFaultRulesInput) Reset() {
+	*x = GetFaultRulesInput{}
+	mi := &file_efinproxy_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetFaultRulesInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetFaultRulesInput) ProtoMessage() {}
+
+func (x *GetFaultRulesInput) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetFaultRulesInput.ProtoReflect.Descriptor instead.
+func (*GetFaultRulesInput) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{29}
+}
+
+type GetFaultRulesOutput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Rules []*FaultRule `protobuf:"bytes,1,rep,name=rules,proto3" json:"rules,omitempty"`
+}
+
+func (x *GetFaultRulesOutput) Reset() {
+	*x = GetFaultRulesOutput{}
+	mi := &file_efinproxy_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetFaultRulesOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetFaultRulesOutput) ProtoMessage() {}
+
+func (x *GetFaultRulesOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetFaultRulesOutput.ProtoReflect.Descriptor instead.
+func (*GetFaultRulesOutput) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *GetFaultRulesOutput) GetRules() []*FaultRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+type SetFaultRulesInput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Rules []*FaultRule `protobuf:"bytes,1,rep,name=rules,proto3" json:"rules,omitempty"`
+}
+
+func (x *SetFaultRulesInput) Reset() {
+	*x = SetFaultRulesInput{}
+	mi := &file_efinproxy_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetFaultRulesInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetFaultRulesInput) ProtoMessage() {}
+
+func (x *SetFaultRulesInput) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetFaultRulesInput.ProtoReflect.Descriptor instead.
+func (*SetFaultRulesInput) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *SetFaultRulesInput) GetRules() []*FaultRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+type SetFaultRulesOutput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *SetFaultRulesOutput) Reset() {
+	*x = SetFaultRulesOutput{}
+	mi := &file_efinproxy_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetFaultRulesOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetFaultRulesOutput) ProtoMessage() {}
+
+func (x *SetFaultRulesOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_efinproxy_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetFaultRulesOutput.ProtoReflect.Descriptor instead.
+func (*SetFaultRulesOutput) Descriptor() ([]byte, []int) {
+	return file_efinproxy_proto_rawDescGZIP(), []int{32}
+}
+
 var File_efinproxy_proto protoreflect.FileDescriptor
 
 var file_efinproxy_proto_rawDesc = []byte{
@@ -563,40 +1806,215 @@ var file_efinproxy_proto_rawDesc = []byte{
 	0x0a, 0x0e, 0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x73, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74,
 	0x12, 0x24, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32,
 	0x0e, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x52,
-	0x05, 0x73, 0x74, 0x61, 0x74, 0x73, 0x32, 0xd9, 0x03, 0x0a, 0x09, 0x45, 0x66, 0x69, 0x6e, 0x50,
+	0x05, 0x73, 0x74, 0x61, 0x74, 0x73, 0x22, 0x4f, 0x0a, 0x0f, 0x57, 0x61, 0x74, 0x63, 0x68, 0x53,
+	0x74, 0x61, 0x74, 0x73, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x69, 0x6e, 0x74,
+	0x65, 0x72, 0x76, 0x61, 0x6c, 0x5f, 0x6d, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a,
+	0x69, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x4d, 0x73, 0x12, 0x1b, 0x0a, 0x09, 0x6f, 0x6e,
+	0x5f, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x6f,
+	0x6e, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x22, 0x53, 0x0a, 0x08, 0x53, 0x74, 0x61, 0x74, 0x52,
+	0x61, 0x74, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x64, 0x65, 0x6c, 0x74, 0x61,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x12, 0x1d, 0x0a,
+	0x0a, 0x70, 0x65, 0x72, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x01, 0x52, 0x09, 0x70, 0x65, 0x72, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x22, 0x80, 0x01, 0x0a,
+	0x0b, 0x53, 0x74, 0x61, 0x74, 0x73, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x12, 0x21, 0x0a, 0x0c,
+	0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x5f, 0x6d, 0x73, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x0b, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x4d, 0x73, 0x12,
+	0x24, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0e,
+	0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x52, 0x05,
+	0x73, 0x74, 0x61, 0x74, 0x73, 0x12, 0x28, 0x0a, 0x05, 0x72, 0x61, 0x74, 0x65, 0x73, 0x18, 0x03,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e,
+	0x53, 0x74, 0x61, 0x74, 0x52, 0x61, 0x74, 0x65, 0x52, 0x05, 0x72, 0x61, 0x74, 0x65, 0x73, 0x22,
+	0x10, 0x0a, 0x0e, 0x47, 0x65, 0x74, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x49, 0x6e, 0x70, 0x75,
+	0x74, 0x22, 0x8e, 0x01, 0x0a, 0x0a, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x45, 0x72, 0x72, 0x6f, 0x72,
+	0x12, 0x1d, 0x0a, 0x0a, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x49, 0x64, 0x12,
+	0x12, 0x0a, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6b,
+	0x69, 0x6e, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61,
+	0x67, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67,
+	0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x5f, 0x63, 0x6f, 0x64, 0x65,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x43, 0x6f,
+	0x64, 0x65, 0x22, 0xaf, 0x01, 0x0a, 0x0f, 0x50, 0x61, 0x73, 0x73, 0x74, 0x68, 0x72, 0x6f, 0x75,
+	0x67, 0x68, 0x48, 0x6f, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x61,
+	0x69, 0x6c, 0x75, 0x72, 0x65, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x08, 0x66, 0x61,
+	0x69, 0x6c, 0x75, 0x72, 0x65, 0x73, 0x12, 0x26, 0x0a, 0x0f, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x66,
+	0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x5f, 0x6d, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x0d, 0x6c, 0x61, 0x73, 0x74, 0x46, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x4d, 0x73, 0x12, 0x1d,
+	0x0a, 0x0a, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x09, 0x6c, 0x61, 0x73, 0x74, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x25, 0x0a,
+	0x0e, 0x70, 0x61, 0x73, 0x73, 0x65, 0x64, 0x5f, 0x74, 0x68, 0x72, 0x6f, 0x75, 0x67, 0x68, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0d, 0x70, 0x61, 0x73, 0x73, 0x65, 0x64, 0x54, 0x68, 0x72,
+	0x6f, 0x75, 0x67, 0x68, 0x22, 0x1a, 0x0a, 0x18, 0x47, 0x65, 0x74, 0x50, 0x61, 0x73, 0x73, 0x74,
+	0x68, 0x72, 0x6f, 0x75, 0x67, 0x68, 0x48, 0x6f, 0x73, 0x74, 0x73, 0x49, 0x6e, 0x70, 0x75, 0x74,
+	0x22, 0x6f, 0x0a, 0x19, 0x47, 0x65, 0x74, 0x50, 0x61, 0x73, 0x73, 0x74, 0x68, 0x72, 0x6f, 0x75,
+	0x67, 0x68, 0x48, 0x6f, 0x73, 0x74, 0x73, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x12, 0x2f, 0x0a,
+	0x05, 0x68, 0x6f, 0x73, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x65,
+	0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x50, 0x61, 0x73, 0x73, 0x74, 0x68, 0x72, 0x6f,
+	0x75, 0x67, 0x68, 0x48, 0x6f, 0x73, 0x74, 0x52, 0x05, 0x68, 0x6f, 0x73, 0x74, 0x73, 0x12, 0x21,
+	0x0a, 0x0c, 0x70, 0x69, 0x6e, 0x6e, 0x65, 0x64, 0x5f, 0x68, 0x6f, 0x73, 0x74, 0x73, 0x18, 0x02,
+	0x20, 0x03, 0x28, 0x09, 0x52, 0x0b, 0x70, 0x69, 0x6e, 0x6e, 0x65, 0x64, 0x48, 0x6f, 0x73, 0x74,
+	0x73, 0x22, 0x2f, 0x0a, 0x19, 0x52, 0x65, 0x73, 0x65, 0x74, 0x50, 0x61, 0x73, 0x73, 0x74, 0x68,
+	0x72, 0x6f, 0x75, 0x67, 0x68, 0x48, 0x6f, 0x73, 0x74, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x12, 0x12,
+	0x0a, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x6f,
+	0x73, 0x74, 0x22, 0x1c, 0x0a, 0x1a, 0x52, 0x65, 0x73, 0x65, 0x74, 0x50, 0x61, 0x73, 0x73, 0x74,
+	0x68, 0x72, 0x6f, 0x75, 0x67, 0x68, 0x48, 0x6f, 0x73, 0x74, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74,
+	0x22, 0x31, 0x0a, 0x13, 0x53, 0x65, 0x74, 0x50, 0x69, 0x6e, 0x6e, 0x65, 0x64, 0x48, 0x6f, 0x73,
+	0x74, 0x73, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x61, 0x74, 0x74, 0x65,
+	0x72, 0x6e, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x70, 0x61, 0x74, 0x74, 0x65,
+	0x72, 0x6e, 0x73, 0x22, 0x16, 0x0a, 0x14, 0x53, 0x65, 0x74, 0x50, 0x69, 0x6e, 0x6e, 0x65, 0x64,
+	0x48, 0x6f, 0x73, 0x74, 0x73, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x22, 0x8f, 0x01, 0x0a, 0x09,
+	0x53, 0x63, 0x6f, 0x70, 0x65, 0x52, 0x75, 0x6c, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x61, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f, 0x73,
+	0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x6f, 0x73, 0x74, 0x12, 0x12, 0x0a,
+	0x04, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x70, 0x6f, 0x72,
+	0x74, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x18,
+	0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x22, 0xe5, 0x01,
+	0x0a, 0x10, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x52, 0x75,
+	0x6c, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x64, 0x69, 0x73, 0x61, 0x62, 0x6c,
+	0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x64, 0x69, 0x73, 0x61, 0x62, 0x6c,
+	0x65, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x61, 0x63, 0x74, 0x69,
+	0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x6d, 0x61,
+	0x74, 0x63, 0x68, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6d, 0x61, 0x74, 0x63, 0x68,
+	0x12, 0x18, 0x0a, 0x07, 0x72, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x72, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x12, 0x29, 0x0a, 0x05, 0x73, 0x63,
+	0x6f, 0x70, 0x65, 0x18, 0x08, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x65, 0x66, 0x69, 0x6e,
+	0x63, 0x6f, 0x72, 0x65, 0x2e, 0x53, 0x63, 0x6f, 0x70, 0x65, 0x52, 0x75, 0x6c, 0x65, 0x52, 0x05,
+	0x73, 0x63, 0x6f, 0x70, 0x65, 0x22, 0x1b, 0x0a, 0x19, 0x47, 0x65, 0x74, 0x4d, 0x61, 0x74, 0x63,
+	0x68, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x49, 0x6e, 0x70,
+	0x75, 0x74, 0x22, 0x4e, 0x0a, 0x1a, 0x47, 0x65, 0x74, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65,
+	0x70, 0x6c, 0x61, 0x63, 0x65, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74,
+	0x12, 0x30, 0x0a, 0x05, 0x72, 0x75, 0x6c, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x1a, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x4d, 0x61, 0x74, 0x63, 0x68,
+	0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x52, 0x75, 0x6c, 0x65, 0x52, 0x05, 0x72, 0x75, 0x6c,
+	0x65, 0x73, 0x22, 0x4d, 0x0a, 0x19, 0x53, 0x65, 0x74, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65,
+	0x70, 0x6c, 0x61, 0x63, 0x65, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x12,
+	0x30, 0x0a, 0x05, 0x72, 0x75, 0x6c, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a,
+	0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x52,
+	0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x52, 0x75, 0x6c, 0x65, 0x52, 0x05, 0x72, 0x75, 0x6c, 0x65,
+	0x73, 0x22, 0x1c, 0x0a, 0x1a, 0x53, 0x65, 0x74, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x70,
+	0x6c, 0x61, 0x63, 0x65, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x22,
+	0xd4, 0x02, 0x0a, 0x09, 0x46, 0x61, 0x75, 0x6c, 0x74, 0x52, 0x75, 0x6c, 0x65, 0x12, 0x12, 0x0a,
+	0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d,
+	0x65, 0x12, 0x1a, 0x0a, 0x08, 0x64, 0x69, 0x73, 0x61, 0x62, 0x6c, 0x65, 0x64, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x08, 0x64, 0x69, 0x73, 0x61, 0x62, 0x6c, 0x65, 0x64, 0x12, 0x29, 0x0a,
+	0x05, 0x73, 0x63, 0x6f, 0x70, 0x65, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x65,
+	0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x53, 0x63, 0x6f, 0x70, 0x65, 0x52, 0x75, 0x6c,
+	0x65, 0x52, 0x05, 0x73, 0x63, 0x6f, 0x70, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x70, 0x72, 0x6f, 0x62,
+	0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0b, 0x70,
+	0x72, 0x6f, 0x62, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x12, 0x1d, 0x0a, 0x0a, 0x6c, 0x61,
+	0x74, 0x65, 0x6e, 0x63, 0x79, 0x5f, 0x6d, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09,
+	0x6c, 0x61, 0x74, 0x65, 0x6e, 0x63, 0x79, 0x4d, 0x73, 0x12, 0x28, 0x0a, 0x10, 0x62, 0x79, 0x74,
+	0x65, 0x73, 0x5f, 0x70, 0x65, 0x72, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x18, 0x06, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x0e, 0x62, 0x79, 0x74, 0x65, 0x73, 0x50, 0x65, 0x72, 0x53, 0x65, 0x63,
+	0x6f, 0x6e, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x72, 0x65, 0x73, 0x65, 0x74, 0x18, 0x07, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x05, 0x72, 0x65, 0x73, 0x65, 0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x74, 0x61,
+	0x74, 0x75, 0x73, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a,
+	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x74, 0x72,
+	0x75, 0x6e, 0x63, 0x61, 0x74, 0x65, 0x5f, 0x62, 0x6f, 0x64, 0x79, 0x18, 0x09, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x0c, 0x74, 0x72, 0x75, 0x6e, 0x63, 0x61, 0x74, 0x65, 0x42, 0x6f, 0x64, 0x79, 0x12,
+	0x25, 0x0a, 0x0e, 0x74, 0x72, 0x75, 0x6e, 0x63, 0x61, 0x74, 0x65, 0x5f, 0x61, 0x66, 0x74, 0x65,
+	0x72, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x74, 0x72, 0x75, 0x6e, 0x63, 0x61, 0x74,
+	0x65, 0x41, 0x66, 0x74, 0x65, 0x72, 0x22, 0x14, 0x0a, 0x12, 0x47, 0x65, 0x74, 0x46, 0x61, 0x75,
+	0x6c, 0x74, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x22, 0x40, 0x0a, 0x13,
+	0x47, 0x65, 0x74, 0x46, 0x61, 0x75, 0x6c, 0x74, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x4f, 0x75, 0x74,
+	0x70, 0x75, 0x74, 0x12, 0x29, 0x0a, 0x05, 0x72, 0x75, 0x6c, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x13, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x46, 0x61,
+	0x75, 0x6c, 0x74, 0x52, 0x75, 0x6c, 0x65, 0x52, 0x05, 0x72, 0x75, 0x6c, 0x65, 0x73, 0x22, 0x3f,
+	0x0a, 0x12, 0x53, 0x65, 0x74, 0x46, 0x61, 0x75, 0x6c, 0x74, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x49,
+	0x6e, 0x70, 0x75, 0x74, 0x12, 0x29, 0x0a, 0x05, 0x72, 0x75, 0x6c, 0x65, 0x73, 0x18, 0x01, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x46,
+	0x61, 0x75, 0x6c, 0x74, 0x52, 0x75, 0x6c, 0x65, 0x52, 0x05, 0x72, 0x75, 0x6c, 0x65, 0x73, 0x22,
+	0x15, 0x0a, 0x13, 0x53, 0x65, 0x74, 0x46, 0x61, 0x75, 0x6c, 0x74, 0x52, 0x75, 0x6c, 0x65, 0x73,
+	0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x32, 0xd0, 0x09, 0x0a, 0x09, 0x45, 0x66, 0x69, 0x6e, 0x50,
 	0x72, 0x6f, 0x78, 0x79, 0x12, 0x3d, 0x0a, 0x08, 0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x73,
 	0x12, 0x17, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x47, 0x65, 0x74, 0x53,
 	0x74, 0x61, 0x74, 0x73, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x1a, 0x18, 0x2e, 0x65, 0x66, 0x69, 0x6e,
 	0x63, 0x6f, 0x72, 0x65, 0x2e, 0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x73, 0x4f, 0x75, 0x74,
-	0x70, 0x75, 0x74, 0x12, 0x42, 0x0a, 0x0d, 0x47, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x73, 0x49, 0x6e, 0x12, 0x1c, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e,
-	0x47, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x73, 0x49, 0x6e, 0x49, 0x6e, 0x70,
-	0x75, 0x74, 0x1a, 0x11, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x52, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x30, 0x01, 0x12, 0x37, 0x0a, 0x0b, 0x52, 0x65, 0x71, 0x75, 0x65,
-	0x73, 0x74, 0x73, 0x4d, 0x6f, 0x64, 0x12, 0x11, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72,
-	0x65, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x11, 0x2e, 0x65, 0x66, 0x69, 0x6e,
-	0x63, 0x6f, 0x72, 0x65, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x28, 0x01, 0x30, 0x01,
-	0x12, 0x44, 0x0a, 0x0e, 0x47, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x73, 0x4f,
-	0x75, 0x74, 0x12, 0x1d, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x47, 0x65,
-	0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x73, 0x4f, 0x75, 0x74, 0x49, 0x6e, 0x70, 0x75,
-	0x74, 0x1a, 0x11, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x52, 0x65, 0x71,
-	0x75, 0x65, 0x73, 0x74, 0x30, 0x01, 0x12, 0x45, 0x0a, 0x0e, 0x47, 0x65, 0x74, 0x52, 0x65, 0x73,
-	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x73, 0x49, 0x6e, 0x12, 0x1d, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63,
-	0x6f, 0x72, 0x65, 0x2e, 0x47, 0x65, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x73,
-	0x49, 0x6e, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x1a, 0x12, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f,
-	0x72, 0x65, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x12, 0x3a, 0x0a,
-	0x0c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x73, 0x4d, 0x6f, 0x64, 0x12, 0x12, 0x2e,
-	0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
-	0x65, 0x1a, 0x12, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x52, 0x65, 0x73,
-	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x28, 0x01, 0x30, 0x01, 0x12, 0x47, 0x0a, 0x0f, 0x47, 0x65, 0x74,
-	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x73, 0x4f, 0x75, 0x74, 0x12, 0x1e, 0x2e, 0x65,
-	0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x47, 0x65, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f,
-	0x6e, 0x73, 0x65, 0x73, 0x4f, 0x75, 0x74, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x1a, 0x12, 0x2e, 0x65,
-	0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
-	0x30, 0x01, 0x42, 0x26, 0x5a, 0x24, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d,
-	0x2f, 0x61, 0x72, 0x74, 0x69, 0x6c, 0x75, 0x67, 0x69, 0x6f, 0x30, 0x2f, 0x65, 0x66, 0x69, 0x6e,
-	0x63, 0x6f, 0x72, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x33,
+	0x70, 0x75, 0x74, 0x12, 0x40, 0x0a, 0x0a, 0x57, 0x61, 0x74, 0x63, 0x68, 0x53, 0x74, 0x61, 0x74,
+	0x73, 0x12, 0x19, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x57, 0x61, 0x74,
+	0x63, 0x68, 0x53, 0x74, 0x61, 0x74, 0x73, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x1a, 0x15, 0x2e, 0x65,
+	0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x73, 0x55, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x30, 0x01, 0x12, 0x42, 0x0a, 0x0d, 0x47, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x73, 0x49, 0x6e, 0x12, 0x1c, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72,
+	0x65, 0x2e, 0x47, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x73, 0x49, 0x6e, 0x49,
+	0x6e, 0x70, 0x75, 0x74, 0x1a, 0x11, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x30, 0x01, 0x12, 0x37, 0x0a, 0x0b, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x73, 0x4d, 0x6f, 0x64, 0x12, 0x11, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63,
+	0x6f, 0x72, 0x65, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x11, 0x2e, 0x65, 0x66,
+	0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x28, 0x01,
+	0x30, 0x01, 0x12, 0x44, 0x0a, 0x0e, 0x47, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x73, 0x4f, 0x75, 0x74, 0x12, 0x1d, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e,
+	0x47, 0x65, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x73, 0x4f, 0x75, 0x74, 0x49, 0x6e,
+	0x70, 0x75, 0x74, 0x1a, 0x11, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x30, 0x01, 0x12, 0x45, 0x0a, 0x0e, 0x47, 0x65, 0x74, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x73, 0x49, 0x6e, 0x12, 0x1d, 0x2e, 0x65, 0x66, 0x69,
+	0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x47, 0x65, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x73, 0x49, 0x6e, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x1a, 0x12, 0x2e, 0x65, 0x66, 0x69, 0x6e,
+	0x63, 0x6f, 0x72, 0x65, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x12,
+	0x3a, 0x0a, 0x0c, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x73, 0x4d, 0x6f, 0x64, 0x12,
+	0x12, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x1a, 0x12, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x28, 0x01, 0x30, 0x01, 0x12, 0x47, 0x0a, 0x0f, 0x47,
+	0x65, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x73, 0x4f, 0x75, 0x74, 0x12, 0x1e,
+	0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x47, 0x65, 0x74, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x73, 0x4f, 0x75, 0x74, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x1a, 0x12,
+	0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x30, 0x01, 0x12, 0x3d, 0x0a, 0x09, 0x47, 0x65, 0x74, 0x45, 0x72, 0x72, 0x6f, 0x72,
+	0x73, 0x12, 0x18, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x47, 0x65, 0x74,
+	0x45, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x1a, 0x14, 0x2e, 0x65, 0x66,
+	0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x45, 0x72, 0x72, 0x6f,
+	0x72, 0x30, 0x01, 0x12, 0x5e, 0x0a, 0x13, 0x47, 0x65, 0x74, 0x50, 0x61, 0x73, 0x73, 0x74, 0x68,
+	0x72, 0x6f, 0x75, 0x67, 0x68, 0x48, 0x6f, 0x73, 0x74, 0x73, 0x12, 0x22, 0x2e, 0x65, 0x66, 0x69,
+	0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x47, 0x65, 0x74, 0x50, 0x61, 0x73, 0x73, 0x74, 0x68, 0x72,
+	0x6f, 0x75, 0x67, 0x68, 0x48, 0x6f, 0x73, 0x74, 0x73, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x1a, 0x23,
+	0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x47, 0x65, 0x74, 0x50, 0x61, 0x73,
+	0x73, 0x74, 0x68, 0x72, 0x6f, 0x75, 0x67, 0x68, 0x48, 0x6f, 0x73, 0x74, 0x73, 0x4f, 0x75, 0x74,
+	0x70, 0x75, 0x74, 0x12, 0x61, 0x0a, 0x14, 0x52, 0x65, 0x73, 0x65, 0x74, 0x50, 0x61, 0x73, 0x73,
+	0x74, 0x68, 0x72, 0x6f, 0x75, 0x67, 0x68, 0x48, 0x6f, 0x73, 0x74, 0x12, 0x23, 0x2e, 0x65, 0x66,
+	0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x52, 0x65, 0x73, 0x65, 0x74, 0x50, 0x61, 0x73, 0x73,
+	0x74, 0x68, 0x72, 0x6f, 0x75, 0x67, 0x68, 0x48, 0x6f, 0x73, 0x74, 0x49, 0x6e, 0x70, 0x75, 0x74,
+	0x1a, 0x24, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x52, 0x65, 0x73, 0x65,
+	0x74, 0x50, 0x61, 0x73, 0x73, 0x74, 0x68, 0x72, 0x6f, 0x75, 0x67, 0x68, 0x48, 0x6f, 0x73, 0x74,
+	0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x12, 0x4f, 0x0a, 0x0e, 0x53, 0x65, 0x74, 0x50, 0x69, 0x6e,
+	0x6e, 0x65, 0x64, 0x48, 0x6f, 0x73, 0x74, 0x73, 0x12, 0x1d, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63,
+	0x6f, 0x72, 0x65, 0x2e, 0x53, 0x65, 0x74, 0x50, 0x69, 0x6e, 0x6e, 0x65, 0x64, 0x48, 0x6f, 0x73,
+	0x74, 0x73, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x1a, 0x1e, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f,
+	0x72, 0x65, 0x2e, 0x53, 0x65, 0x74, 0x50, 0x69, 0x6e, 0x6e, 0x65, 0x64, 0x48, 0x6f, 0x73, 0x74,
+	0x73, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x12, 0x61, 0x0a, 0x14, 0x47, 0x65, 0x74, 0x4d, 0x61,
+	0x74, 0x63, 0x68, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x12,
+	0x23, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x47, 0x65, 0x74, 0x4d, 0x61,
+	0x74, 0x63, 0x68, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x49,
+	0x6e, 0x70, 0x75, 0x74, 0x1a, 0x24, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e,
+	0x47, 0x65, 0x74, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x52,
+	0x75, 0x6c, 0x65, 0x73, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x12, 0x61, 0x0a, 0x14, 0x53, 0x65,
+	0x74, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x52, 0x75, 0x6c,
+	0x65, 0x73, 0x12, 0x23, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x53, 0x65,
+	0x74, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x52, 0x75, 0x6c,
+	0x65, 0x73, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x1a, 0x24, 0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f,
+	0x72, 0x65, 0x2e, 0x53, 0x65, 0x74, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x70, 0x6c, 0x61,
+	0x63, 0x65, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x12, 0x4c, 0x0a,
+	0x0d, 0x47, 0x65, 0x74, 0x46, 0x61, 0x75, 0x6c, 0x74, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x12, 0x1c,
+	0x2e, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x47, 0x65, 0x74, 0x46, 0x61, 0x75,
+	0x6c, 0x74, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x1a, 0x1d, 0x2e, 0x65,
+	0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x47, 0x65, 0x74, 0x46, 0x61, 0x75, 0x6c, 0x74,
+	0x52, 0x75, 0x6c, 0x65, 0x73, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x12, 0x4c, 0x0a, 0x0d, 0x53,
+	0x65, 0x74, 0x46, 0x61, 0x75, 0x6c, 0x74, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x12, 0x1c, 0x2e, 0x65,
+	0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x53, 0x65, 0x74, 0x46, 0x61, 0x75, 0x6c, 0x74,
+	0x52, 0x75, 0x6c, 0x65, 0x73, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x1a, 0x1d, 0x2e, 0x65, 0x66, 0x69,
+	0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2e, 0x53, 0x65, 0x74, 0x46, 0x61, 0x75, 0x6c, 0x74, 0x52, 0x75,
+	0x6c, 0x65, 0x73, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x42, 0x26, 0x5a, 0x24, 0x67, 0x69, 0x74,
+	0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x61, 0x72, 0x74, 0x69, 0x6c, 0x75, 0x67, 0x69,
+	0x6f, 0x30, 0x2f, 0x65, 0x66, 0x69, 0x6e, 0x63, 0x6f, 0x72, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -611,42 +2029,92 @@ func file_efinproxy_proto_rawDescGZIP() []byte {
 	return file_efinproxy_proto_rawDescData
 }
 
-var file_efinproxy_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_efinproxy_proto_msgTypes = make([]protoimpl.MessageInfo, 33)
 var file_efinproxy_proto_goTypes = []any{
-	(*GetRequestsInInput)(nil),   // 0: efincore.GetRequestsInInput
-	(*GetRequestsOutInput)(nil),  // 1: efincore.GetRequestsOutInput
-	(*GetResponsesInInput)(nil),  // 2: efincore.GetResponsesInInput
-	(*GetResponsesOutInput)(nil), // 3: efincore.GetResponsesOutInput
-	(*Request)(nil),              // 4: efincore.Request
-	(*Header)(nil),               // 5: efincore.Header
-	(*Response)(nil),             // 6: efincore.Response
-	(*Stat)(nil),                 // 7: efincore.Stat
-	(*GetStatsInput)(nil),        // 8: efincore.GetStatsInput
-	(*GetStatsOutput)(nil),       // 9: efincore.GetStatsOutput
+	(*GetRequestsInInput)(nil),         // 0: efincore.GetRequestsInInput
+	(*GetRequestsOutInput)(nil),        // 1: efincore.GetRequestsOutInput
+	(*GetResponsesInInput)(nil),        // 2: efincore.GetResponsesInInput
+	(*GetResponsesOutInput)(nil),       // 3: efincore.GetResponsesOutInput
+	(*Request)(nil),                    // 4: efincore.Request
+	(*Header)(nil),                     // 5: efincore.Header
+	(*Response)(nil),                   // 6: efincore.Response
+	(*Stat)(nil),                       // 7: efincore.Stat
+	(*GetStatsInput)(nil),              // 8: efincore.GetStatsInput
+	(*GetStatsOutput)(nil),             // 9: efincore.GetStatsOutput
+	(*WatchStatsInput)(nil),            // 10: efincore.WatchStatsInput
+	(*StatRate)(nil),                   // 11: efincore.StatRate
+	(*StatsUpdate)(nil),                // 12: efincore.StatsUpdate
+	(*GetErrorsInput)(nil),             // 13: efincore.GetErrorsInput
+	(*ProxyError)(nil),                 // 14: efincore.ProxyError
+	(*PassthroughHost)(nil),            // 15: efincore.PassthroughHost
+	(*GetPassthroughHostsInput)(nil),   // 16: efincore.GetPassthroughHostsInput
+	(*GetPassthroughHostsOutput)(nil),  // 17: efincore.GetPassthroughHostsOutput
+	(*ResetPassthroughHostInput)(nil),  // 18: efincore.ResetPassthroughHostInput
+	(*ResetPassthroughHostOutput)(nil), // 19: efincore.ResetPassthroughHostOutput
+	(*SetPinnedHostsInput)(nil),        // 20: efincore.SetPinnedHostsInput
+	(*SetPinnedHostsOutput)(nil),       // 21: efincore.SetPinnedHostsOutput
+	(*ScopeRule)(nil),                  // 22: efincore.ScopeRule
+	(*MatchReplaceRule)(nil),           // 23: efincore.MatchReplaceRule
+	(*GetMatchReplaceRulesInput)(nil),  // 24: efincore.GetMatchReplaceRulesInput
+	(*GetMatchReplaceRulesOutput)(nil), // 25: efincore.GetMatchReplaceRulesOutput
+	(*SetMatchReplaceRulesInput)(nil),  // 26: efincore.SetMatchReplaceRulesInput
+	(*SetMatchReplaceRulesOutput)(nil), // 27: efincore.SetMatchReplaceRulesOutput
+	(*FaultRule)(nil),                  // 28: efincore.FaultRule
+	(*GetFaultRulesInput)(nil),         // 29: efincore.GetFaultRulesInput
+	(*GetFaultRulesOutput)(nil),        // 30: efincore.GetFaultRulesOutput
+	(*SetFaultRulesInput)(nil),         // 31: efincore.SetFaultRulesInput
+	(*SetFaultRulesOutput)(nil),        // 32: efincore.SetFaultRulesOutput
 }
 var file_efinproxy_proto_depIdxs = []int32{
 	5,  // 0: efincore.Request.headers:type_name -> efincore.Header
 	5,  // 1: efincore.Response.headers:type_name -> efincore.Header
 	7,  // 2: efincore.GetStatsOutput.stats:type_name -> efincore.Stat
-	8,  // 3: efincore.EfinProxy.GetStats:input_type -> efincore.GetStatsInput
-	0,  // 4: efincore.EfinProxy.GetRequestsIn:input_type -> efincore.GetRequestsInInput
-	4,  // 5: efincore.EfinProxy.RequestsMod:input_type -> efincore.Request
-	1,  // 6: efincore.EfinProxy.GetRequestsOut:input_type -> efincore.GetRequestsOutInput
-	2,  // 7: efincore.EfinProxy.GetResponsesIn:input_type -> efincore.GetResponsesInInput
-	6,  // 8: efincore.EfinProxy.ResponsesMod:input_type -> efincore.Response
-	3,  // 9: efincore.EfinProxy.GetResponsesOut:input_type -> efincore.GetResponsesOutInput
-	9,  // 10: efincore.EfinProxy.GetStats:output_type -> efincore.GetStatsOutput
-	4,  // 11: efincore.EfinProxy.GetRequestsIn:output_type -> efincore.Request
-	4,  // 12: efincore.EfinProxy.RequestsMod:output_type -> efincore.Request
-	4,  // 13: efincore.EfinProxy.GetRequestsOut:output_type -> efincore.Request
-	6,  // 14: efincore.EfinProxy.GetResponsesIn:output_type -> efincore.Response
-	6,  // 15: efincore.EfinProxy.ResponsesMod:output_type -> efincore.Response
-	6,  // 16: efincore.EfinProxy.GetResponsesOut:output_type -> efincore.Response
-	10, // [10:17] is the sub-list for method output_type
-	3,  // [3:10] is the sub-list for method input_type
-	3,  // [3:3] is the sub-list for extension type_name
-	3,  // [3:3] is the sub-list for extension extendee
-	0,  // [0:3] is the sub-list for field type_name
+	7,  // 3: efincore.StatsUpdate.stats:type_name -> efincore.Stat
+	11, // 4: efincore.StatsUpdate.rates:type_name -> efincore.StatRate
+	15, // 5: efincore.GetPassthroughHostsOutput.hosts:type_name -> efincore.PassthroughHost
+	22, // 6: efincore.MatchReplaceRule.scope:type_name -> efincore.ScopeRule
+	23, // 7: efincore.GetMatchReplaceRulesOutput.rules:type_name -> efincore.MatchReplaceRule
+	23, // 8: efincore.SetMatchReplaceRulesInput.rules:type_name -> efincore.MatchReplaceRule
+	22, // 9: efincore.FaultRule.scope:type_name -> efincore.ScopeRule
+	28, // 10: efincore.GetFaultRulesOutput.rules:type_name -> efincore.FaultRule
+	28, // 11: efincore.SetFaultRulesInput.rules:type_name -> efincore.FaultRule
+	8,  // 12: efincore.EfinProxy.GetStats:input_type -> efincore.GetStatsInput
+	10, // 13: efincore.EfinProxy.WatchStats:input_type -> efincore.WatchStatsInput
+	0,  // 14: efincore.EfinProxy.GetRequestsIn:input_type -> efincore.GetRequestsInInput
+	4,  // 15: efincore.EfinProxy.RequestsMod:input_type -> efincore.Request
+	1,  // 16: efincore.EfinProxy.GetRequestsOut:input_type -> efincore.GetRequestsOutInput
+	2,  // 17: efincore.EfinProxy.GetResponsesIn:input_type -> efincore.GetResponsesInInput
+	6,  // 18: efincore.EfinProxy.ResponsesMod:input_type -> efincore.Response
+	3,  // 19: efincore.EfinProxy.GetResponsesOut:input_type -> efincore.GetResponsesOutInput
+	13, // 20: efincore.EfinProxy.GetErrors:input_type -> efincore.GetErrorsInput
+	16, // 21: efincore.EfinProxy.GetPassthroughHosts:input_type -> efincore.GetPassthroughHostsInput
+	18, // 22: efincore.EfinProxy.ResetPassthroughHost:input_type -> efincore.ResetPassthroughHostInput
+	20, // 23: efincore.EfinProxy.SetPinnedHosts:input_type -> efincore.SetPinnedHostsInput
+	24, // 24: efincore.EfinProxy.GetMatchReplaceRules:input_type -> efincore.GetMatchReplaceRulesInput
+	26, // 25: efincore.EfinProxy.SetMatchReplaceRules:input_type -> efincore.SetMatchReplaceRulesInput
+	29, // 26: efincore.EfinProxy.GetFaultRules:input_type -> efincore.GetFaultRulesInput
+	31, // 27: efincore.EfinProxy.SetFaultRules:input_type -> efincore.SetFaultRulesInput
+	9,  // 28: efincore.EfinProxy.GetStats:output_type -> efincore.GetStatsOutput
+	12, // 29: efincore.EfinProxy.WatchStats:output_type -> efincore.StatsUpdate
+	4,  // 30: efincore.EfinProxy.GetRequestsIn:output_type -> efincore.Request
+	4,  // 31: efincore.EfinProxy.RequestsMod:output_type -> efincore.Request
+	4,  // 32: efincore.EfinProxy.GetRequestsOut:output_type -> efincore.Request
+	6,  // 33: efincore.EfinProxy.GetResponsesIn:output_type -> efincore.Response
+	6,  // 34: efincore.EfinProxy.ResponsesMod:output_type -> efincore.Response
+	6,  // 35: efincore.EfinProxy.GetResponsesOut:output_type -> efincore.Response
+	14, // 36: efincore.EfinProxy.GetErrors:output_type -> efincore.ProxyError
+	17, // 37: efincore.EfinProxy.GetPassthroughHosts:output_type -> efincore.GetPassthroughHostsOutput
+	19, // 38: efincore.EfinProxy.ResetPassthroughHost:output_type -> efincore.ResetPassthroughHostOutput
+	21, // 39: efincore.EfinProxy.SetPinnedHosts:output_type -> efincore.SetPinnedHostsOutput
+	25, // 40: efincore.EfinProxy.GetMatchReplaceRules:output_type -> efincore.GetMatchReplaceRulesOutput
+	27, // 41: efincore.EfinProxy.SetMatchReplaceRules:output_type -> efincore.SetMatchReplaceRulesOutput
+	30, // 42: efincore.EfinProxy.GetFaultRules:output_type -> efincore.GetFaultRulesOutput
+	32, // 43: efincore.EfinProxy.SetFaultRules:output_type -> efincore.SetFaultRulesOutput
+	28, // [28:44] is the sub-list for method output_type
+	12, // [12:28] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
 }
 
 func init() { file_efinproxy_proto_init() }
@@ -660,7 +2128,7 @@ func file_efinproxy_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_efinproxy_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   10,
+			NumMessages:   33,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
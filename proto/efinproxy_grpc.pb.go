@@ -19,13 +19,22 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	EfinProxy_GetStats_FullMethodName        = "/efincore.EfinProxy/GetStats"
-	EfinProxy_GetRequestsIn_FullMethodName   = "/efincore.EfinProxy/GetRequestsIn"
-	EfinProxy_RequestsMod_FullMethodName     = "/efincore.EfinProxy/RequestsMod"
-	EfinProxy_GetRequestsOut_FullMethodName  = "/efincore.EfinProxy/GetRequestsOut"
-	EfinProxy_GetResponsesIn_FullMethodName  = "/efincore.EfinProxy/GetResponsesIn"
-	EfinProxy_ResponsesMod_FullMethodName    = "/efincore.EfinProxy/ResponsesMod"
-	EfinProxy_GetResponsesOut_FullMethodName = "/efincore.EfinProxy/GetResponsesOut"
+	EfinProxy_GetStats_FullMethodName             = "/efincore.EfinProxy/GetStats"
+	EfinProxy_WatchStats_FullMethodName           = "/efincore.EfinProxy/WatchStats"
+	EfinProxy_GetRequestsIn_FullMethodName        = "/efincore.EfinProxy/GetRequestsIn"
+	EfinProxy_RequestsMod_FullMethodName          = "/efincore.EfinProxy/RequestsMod"
+	EfinProxy_GetRequestsOut_FullMethodName       = "/efincore.EfinProxy/GetRequestsOut"
+	EfinProxy_GetResponsesIn_FullMethodName       = "/efincore.EfinProxy/GetResponsesIn"
+	EfinProxy_ResponsesMod_FullMethodName         = "/efincore.EfinProxy/ResponsesMod"
+	EfinProxy_GetResponsesOut_FullMethodName      = "/efincore.EfinProxy/GetResponsesOut"
+	EfinProxy_GetErrors_FullMethodName            = "/efincore.EfinProxy/GetErrors"
+	EfinProxy_GetPassthroughHosts_FullMethodName  = "/efincore.EfinProxy/GetPassthroughHosts"
+	EfinProxy_ResetPassthroughHost_FullMethodName = "/efincore.EfinProxy/ResetPassthroughHost"
+	EfinProxy_SetPinnedHosts_FullMethodName       = "/efincore.EfinProxy/SetPinnedHosts"
+	EfinProxy_GetMatchReplaceRules_FullMethodName = "/efincore.EfinProxy/GetMatchReplaceRules"
+	EfinProxy_SetMatchReplaceRules_FullMethodName = "/efincore.EfinProxy/SetMatchReplaceRules"
+	EfinProxy_GetFaultRules_FullMethodName        = "/efincore.EfinProxy/GetFaultRules"
+	EfinProxy_SetFaultRules_FullMethodName        = "/efincore.EfinProxy/SetFaultRules"
 )
 
 // EfinProxyClient is the client API for EfinProxy service.
@@ -33,12 +42,21 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type EfinProxyClient interface {
 	GetStats(ctx context.Context, in *GetStatsInput, opts ...grpc.CallOption) (*GetStatsOutput, error)
+	WatchStats(ctx context.Context, in *WatchStatsInput, opts ...grpc.CallOption) (EfinProxy_WatchStatsClient, error)
 	GetRequestsIn(ctx context.Context, in *GetRequestsInInput, opts ...grpc.CallOption) (EfinProxy_GetRequestsInClient, error)
 	RequestsMod(ctx context.Context, opts ...grpc.CallOption) (EfinProxy_RequestsModClient, error)
 	GetRequestsOut(ctx context.Context, in *GetRequestsOutInput, opts ...grpc.CallOption) (EfinProxy_GetRequestsOutClient, error)
 	GetResponsesIn(ctx context.Context, in *GetResponsesInInput, opts ...grpc.CallOption) (EfinProxy_GetResponsesInClient, error)
 	ResponsesMod(ctx context.Context, opts ...grpc.CallOption) (EfinProxy_ResponsesModClient, error)
 	GetResponsesOut(ctx context.Context, in *GetResponsesOutInput, opts ...grpc.CallOption) (EfinProxy_GetResponsesOutClient, error)
+	GetErrors(ctx context.Context, in *GetErrorsInput, opts ...grpc.CallOption) (EfinProxy_GetErrorsClient, error)
+	GetPassthroughHosts(ctx context.Context, in *GetPassthroughHostsInput, opts ...grpc.CallOption) (*GetPassthroughHostsOutput, error)
+	ResetPassthroughHost(ctx context.Context, in *ResetPassthroughHostInput, opts ...grpc.CallOption) (*ResetPassthroughHostOutput, error)
+	SetPinnedHosts(ctx context.Context, in *SetPinnedHostsInput, opts ...grpc.CallOption) (*SetPinnedHostsOutput, error)
+	GetMatchReplaceRules(ctx context.Context, in *GetMatchReplaceRulesInput, opts ...grpc.CallOption) (*GetMatchReplaceRulesOutput, error)
+	SetMatchReplaceRules(ctx context.Context, in *SetMatchReplaceRulesInput, opts ...grpc.CallOption) (*SetMatchReplaceRulesOutput, error)
+	GetFaultRules(ctx context.Context, in *GetFaultRulesInput, opts ...grpc.CallOption) (*GetFaultRulesOutput, error)
+	SetFaultRules(ctx context.Context, in *SetFaultRulesInput, opts ...grpc.CallOption) (*SetFaultRulesOutput, error)
 }
 
 type efinProxyClient struct {
@@ -58,8 +76,40 @@ func (c *efinProxyClient) GetStats(ctx context.Context, in *GetStatsInput, opts
 	return out, nil
 }
 
+func (c *efinProxyClient) WatchStats(ctx context.Context, in *WatchStatsInput, opts ...grpc.CallOption) (EfinProxy_WatchStatsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &EfinProxy_ServiceDesc.Streams[0], EfinProxy_WatchStats_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &efinProxyWatchStatsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type EfinProxy_WatchStatsClient interface {
+	Recv() (*StatsUpdate, error)
+	grpc.ClientStream
+}
+
+type efinProxyWatchStatsClient struct {
+	grpc.ClientStream
+}
+
+func (x *efinProxyWatchStatsClient) Recv() (*StatsUpdate, error) {
+	m := new(StatsUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *efinProxyClient) GetRequestsIn(ctx context.Context, in *GetRequestsInInput, opts ...grpc.CallOption) (EfinProxy_GetRequestsInClient, error) {
-	stream, err := c.cc.NewStream(ctx, &EfinProxy_ServiceDesc.Streams[0], EfinProxy_GetRequestsIn_FullMethodName, opts...)
+	stream, err := c.cc.NewStream(ctx, &EfinProxy_ServiceDesc.Streams[1], EfinProxy_GetRequestsIn_FullMethodName, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -91,7 +141,7 @@ func (x *efinProxyGetRequestsInClient) Recv() (*Request, error) {
 }
 
 func (c *efinProxyClient) RequestsMod(ctx context.Context, opts ...grpc.CallOption) (EfinProxy_RequestsModClient, error) {
-	stream, err := c.cc.NewStream(ctx, &EfinProxy_ServiceDesc.Streams[1], EfinProxy_RequestsMod_FullMethodName, opts...)
+	stream, err := c.cc.NewStream(ctx, &EfinProxy_ServiceDesc.Streams[2], EfinProxy_RequestsMod_FullMethodName, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -122,7 +172,7 @@ func (x *efinProxyRequestsModClient) Recv() (*Request, error) {
 }
 
 func (c *efinProxyClient) GetRequestsOut(ctx context.Context, in *GetRequestsOutInput, opts ...grpc.CallOption) (EfinProxy_GetRequestsOutClient, error) {
-	stream, err := c.cc.NewStream(ctx, &EfinProxy_ServiceDesc.Streams[2], EfinProxy_GetRequestsOut_FullMethodName, opts...)
+	stream, err := c.cc.NewStream(ctx, &EfinProxy_ServiceDesc.Streams[3], EfinProxy_GetRequestsOut_FullMethodName, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -154,7 +204,7 @@ func (x *efinProxyGetRequestsOutClient) Recv() (*Request, error) {
 }
 
 func (c *efinProxyClient) GetResponsesIn(ctx context.Context, in *GetResponsesInInput, opts ...grpc.CallOption) (EfinProxy_GetResponsesInClient, error) {
-	stream, err := c.cc.NewStream(ctx, &EfinProxy_ServiceDesc.Streams[3], EfinProxy_GetResponsesIn_FullMethodName, opts...)
+	stream, err := c.cc.NewStream(ctx, &EfinProxy_ServiceDesc.Streams[4], EfinProxy_GetResponsesIn_FullMethodName, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -186,7 +236,7 @@ func (x *efinProxyGetResponsesInClient) Recv() (*Response, error) {
 }
 
 func (c *efinProxyClient) ResponsesMod(ctx context.Context, opts ...grpc.CallOption) (EfinProxy_ResponsesModClient, error) {
-	stream, err := c.cc.NewStream(ctx, &EfinProxy_ServiceDesc.Streams[4], EfinProxy_ResponsesMod_FullMethodName, opts...)
+	stream, err := c.cc.NewStream(ctx, &EfinProxy_ServiceDesc.Streams[5], EfinProxy_ResponsesMod_FullMethodName, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -217,7 +267,7 @@ func (x *efinProxyResponsesModClient) Recv() (*Response, error) {
 }
 
 func (c *efinProxyClient) GetResponsesOut(ctx context.Context, in *GetResponsesOutInput, opts ...grpc.CallOption) (EfinProxy_GetResponsesOutClient, error) {
-	stream, err := c.cc.NewStream(ctx, &EfinProxy_ServiceDesc.Streams[5], EfinProxy_GetResponsesOut_FullMethodName, opts...)
+	stream, err := c.cc.NewStream(ctx, &EfinProxy_ServiceDesc.Streams[6], EfinProxy_GetResponsesOut_FullMethodName, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -248,17 +298,121 @@ func (x *efinProxyGetResponsesOutClient) Recv() (*Response, error) {
 	return m, nil
 }
 
+func (c *efinProxyClient) GetErrors(ctx context.Context, in *GetErrorsInput, opts ...grpc.CallOption) (EfinProxy_GetErrorsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &EfinProxy_ServiceDesc.Streams[7], EfinProxy_GetErrors_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &efinProxyGetErrorsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type EfinProxy_GetErrorsClient interface {
+	Recv() (*ProxyError, error)
+	grpc.ClientStream
+}
+
+type efinProxyGetErrorsClient struct {
+	grpc.ClientStream
+}
+
+func (x *efinProxyGetErrorsClient) Recv() (*ProxyError, error) {
+	m := new(ProxyError)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *efinProxyClient) GetPassthroughHosts(ctx context.Context, in *GetPassthroughHostsInput, opts ...grpc.CallOption) (*GetPassthroughHostsOutput, error) {
+	out := new(GetPassthroughHostsOutput)
+	err := c.cc.Invoke(ctx, EfinProxy_GetPassthroughHosts_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *efinProxyClient) ResetPassthroughHost(ctx context.Context, in *ResetPassthroughHostInput, opts ...grpc.CallOption) (*ResetPassthroughHostOutput, error) {
+	out := new(ResetPassthroughHostOutput)
+	err := c.cc.Invoke(ctx, EfinProxy_ResetPassthroughHost_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *efinProxyClient) SetPinnedHosts(ctx context.Context, in *SetPinnedHostsInput, opts ...grpc.CallOption) (*SetPinnedHostsOutput, error) {
+	out := new(SetPinnedHostsOutput)
+	err := c.cc.Invoke(ctx, EfinProxy_SetPinnedHosts_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *efinProxyClient) GetMatchReplaceRules(ctx context.Context, in *GetMatchReplaceRulesInput, opts ...grpc.CallOption) (*GetMatchReplaceRulesOutput, error) {
+	out := new(GetMatchReplaceRulesOutput)
+	err := c.cc.Invoke(ctx, EfinProxy_GetMatchReplaceRules_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *efinProxyClient) SetMatchReplaceRules(ctx context.Context, in *SetMatchReplaceRulesInput, opts ...grpc.CallOption) (*SetMatchReplaceRulesOutput, error) {
+	out := new(SetMatchReplaceRulesOutput)
+	err := c.cc.Invoke(ctx, EfinProxy_SetMatchReplaceRules_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *efinProxyClient) GetFaultRules(ctx context.Context, in *GetFaultRulesInput, opts ...grpc.CallOption) (*GetFaultRulesOutput, error) {
+	out := new(GetFaultRulesOutput)
+	err := c.cc.Invoke(ctx, EfinProxy_GetFaultRules_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *efinProxyClient) SetFaultRules(ctx context.Context, in *SetFaultRulesInput, opts ...grpc.CallOption) (*SetFaultRulesOutput, error) {
+	out := new(SetFaultRulesOutput)
+	err := c.cc.Invoke(ctx, EfinProxy_SetFaultRules_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // EfinProxyServer is the server API for EfinProxy service.
 // All implementations must embed UnimplementedEfinProxyServer
 // for forward compatibility
 type EfinProxyServer interface {
 	GetStats(context.Context, *GetStatsInput) (*GetStatsOutput, error)
+	WatchStats(*WatchStatsInput, EfinProxy_WatchStatsServer) error
 	GetRequestsIn(*GetRequestsInInput, EfinProxy_GetRequestsInServer) error
 	RequestsMod(EfinProxy_RequestsModServer) error
 	GetRequestsOut(*GetRequestsOutInput, EfinProxy_GetRequestsOutServer) error
 	GetResponsesIn(*GetResponsesInInput, EfinProxy_GetResponsesInServer) error
 	ResponsesMod(EfinProxy_ResponsesModServer) error
 	GetResponsesOut(*GetResponsesOutInput, EfinProxy_GetResponsesOutServer) error
+	GetErrors(*GetErrorsInput, EfinProxy_GetErrorsServer) error
+	GetPassthroughHosts(context.Context, *GetPassthroughHostsInput) (*GetPassthroughHostsOutput, error)
+	ResetPassthroughHost(context.Context, *ResetPassthroughHostInput) (*ResetPassthroughHostOutput, error)
+	SetPinnedHosts(context.Context, *SetPinnedHostsInput) (*SetPinnedHostsOutput, error)
+	GetMatchReplaceRules(context.Context, *GetMatchReplaceRulesInput) (*GetMatchReplaceRulesOutput, error)
+	SetMatchReplaceRules(context.Context, *SetMatchReplaceRulesInput) (*SetMatchReplaceRulesOutput, error)
+	GetFaultRules(context.Context, *GetFaultRulesInput) (*GetFaultRulesOutput, error)
+	SetFaultRules(context.Context, *SetFaultRulesInput) (*SetFaultRulesOutput, error)
 	mustEmbedUnimplementedEfinProxyServer()
 }
 
@@ -269,6 +423,9 @@ type UnimplementedEfinProxyServer struct {
 func (UnimplementedEfinProxyServer) GetStats(context.Context, *GetStatsInput) (*GetStatsOutput, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
 }
+func (UnimplementedEfinProxyServer) WatchStats(*WatchStatsInput, EfinProxy_WatchStatsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchStats not implemented")
+}
 func (UnimplementedEfinProxyServer) GetRequestsIn(*GetRequestsInInput, EfinProxy_GetRequestsInServer) error {
 	return status.Errorf(codes.Unimplemented, "method GetRequestsIn not implemented")
 }
@@ -287,6 +444,30 @@ func (UnimplementedEfinProxyServer) ResponsesMod(EfinProxy_ResponsesModServer) e
 func (UnimplementedEfinProxyServer) GetResponsesOut(*GetResponsesOutInput, EfinProxy_GetResponsesOutServer) error {
 	return status.Errorf(codes.Unimplemented, "method GetResponsesOut not implemented")
 }
+func (UnimplementedEfinProxyServer) GetErrors(*GetErrorsInput, EfinProxy_GetErrorsServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetErrors not implemented")
+}
+func (UnimplementedEfinProxyServer) GetPassthroughHosts(context.Context, *GetPassthroughHostsInput) (*GetPassthroughHostsOutput, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPassthroughHosts not implemented")
+}
+func (UnimplementedEfinProxyServer) ResetPassthroughHost(context.Context, *ResetPassthroughHostInput) (*ResetPassthroughHostOutput, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResetPassthroughHost not implemented")
+}
+func (UnimplementedEfinProxyServer) SetPinnedHosts(context.Context, *SetPinnedHostsInput) (*SetPinnedHostsOutput, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetPinnedHosts not implemented")
+}
+func (UnimplementedEfinProxyServer) GetMatchReplaceRules(context.Context, *GetMatchReplaceRulesInput) (*GetMatchReplaceRulesOutput, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMatchReplaceRules not implemented")
+}
+func (UnimplementedEfinProxyServer) SetMatchReplaceRules(context.Context, *SetMatchReplaceRulesInput) (*SetMatchReplaceRulesOutput, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetMatchReplaceRules not implemented")
+}
+func (UnimplementedEfinProxyServer) GetFaultRules(context.Context, *GetFaultRulesInput) (*GetFaultRulesOutput, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFaultRules not implemented")
+}
+func (UnimplementedEfinProxyServer) SetFaultRules(context.Context, *SetFaultRulesInput) (*SetFaultRulesOutput, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetFaultRules not implemented")
+}
 func (UnimplementedEfinProxyServer) mustEmbedUnimplementedEfinProxyServer() {}
 
 // UnsafeEfinProxyServer may be embedded to opt out of forward compatibility for this service.
@@ -318,6 +499,27 @@ func _EfinProxy_GetStats_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _EfinProxy_WatchStats_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchStatsInput)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EfinProxyServer).WatchStats(m, &efinProxyWatchStatsServer{stream})
+}
+
+type EfinProxy_WatchStatsServer interface {
+	Send(*StatsUpdate) error
+	grpc.ServerStream
+}
+
+type efinProxyWatchStatsServer struct {
+	grpc.ServerStream
+}
+
+func (x *efinProxyWatchStatsServer) Send(m *StatsUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _EfinProxy_GetRequestsIn_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(GetRequestsInInput)
 	if err := stream.RecvMsg(m); err != nil {
@@ -454,6 +656,153 @@ func (x *efinProxyGetResponsesOutServer) Send(m *Response) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _EfinProxy_GetErrors_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetErrorsInput)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EfinProxyServer).GetErrors(m, &efinProxyGetErrorsServer{stream})
+}
+
+type EfinProxy_GetErrorsServer interface {
+	Send(*ProxyError) error
+	grpc.ServerStream
+}
+
+type efinProxyGetErrorsServer struct {
+	grpc.ServerStream
+}
+
+func (x *efinProxyGetErrorsServer) Send(m *ProxyError) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _EfinProxy_GetPassthroughHosts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPassthroughHostsInput)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EfinProxyServer).GetPassthroughHosts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EfinProxy_GetPassthroughHosts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EfinProxyServer).GetPassthroughHosts(ctx, req.(*GetPassthroughHostsInput))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EfinProxy_ResetPassthroughHost_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResetPassthroughHostInput)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EfinProxyServer).ResetPassthroughHost(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EfinProxy_ResetPassthroughHost_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EfinProxyServer).ResetPassthroughHost(ctx, req.(*ResetPassthroughHostInput))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EfinProxy_SetPinnedHosts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetPinnedHostsInput)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EfinProxyServer).SetPinnedHosts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EfinProxy_SetPinnedHosts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EfinProxyServer).SetPinnedHosts(ctx, req.(*SetPinnedHostsInput))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EfinProxy_GetMatchReplaceRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMatchReplaceRulesInput)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EfinProxyServer).GetMatchReplaceRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EfinProxy_GetMatchReplaceRules_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EfinProxyServer).GetMatchReplaceRules(ctx, req.(*GetMatchReplaceRulesInput))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EfinProxy_SetMatchReplaceRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetMatchReplaceRulesInput)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EfinProxyServer).SetMatchReplaceRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EfinProxy_SetMatchReplaceRules_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EfinProxyServer).SetMatchReplaceRules(ctx, req.(*SetMatchReplaceRulesInput))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EfinProxy_GetFaultRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFaultRulesInput)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EfinProxyServer).GetFaultRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EfinProxy_GetFaultRules_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EfinProxyServer).GetFaultRules(ctx, req.(*GetFaultRulesInput))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _EfinProxy_SetFaultRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetFaultRulesInput)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EfinProxyServer).SetFaultRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EfinProxy_SetFaultRules_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EfinProxyServer).SetFaultRules(ctx, req.(*SetFaultRulesInput))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // EfinProxy_ServiceDesc is the grpc.ServiceDesc for EfinProxy service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -465,8 +814,41 @@ var EfinProxy_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetStats",
 			Handler:    _EfinProxy_GetStats_Handler,
 		},
+		{
+			MethodName: "GetPassthroughHosts",
+			Handler:    _EfinProxy_GetPassthroughHosts_Handler,
+		},
+		{
+			MethodName: "ResetPassthroughHost",
+			Handler:    _EfinProxy_ResetPassthroughHost_Handler,
+		},
+		{
+			MethodName: "SetPinnedHosts",
+			Handler:    _EfinProxy_SetPinnedHosts_Handler,
+		},
+		{
+			MethodName: "GetMatchReplaceRules",
+			Handler:    _EfinProxy_GetMatchReplaceRules_Handler,
+		},
+		{
+			MethodName: "SetMatchReplaceRules",
+			Handler:    _EfinProxy_SetMatchReplaceRules_Handler,
+		},
+		{
+			MethodName: "GetFaultRules",
+			Handler:    _EfinProxy_GetFaultRules_Handler,
+		},
+		{
+			MethodName: "SetFaultRules",
+			Handler:    _EfinProxy_SetFaultRules_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchStats",
+			Handler:       _EfinProxy_WatchStats_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "GetRequestsIn",
 			Handler:       _EfinProxy_GetRequestsIn_Handler,
@@ -499,6 +881,11 @@ var EfinProxy_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _EfinProxy_GetResponsesOut_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "GetErrors",
+			Handler:       _EfinProxy_GetErrors_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "efinproxy.proto",
 }
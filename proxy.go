@@ -1,25 +1,114 @@
 package efincore
 
 import (
+	"crypto/tls"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
 	"regexp"
 )
 
 type Proxy struct {
-	addr string
-	mitm *mitm
+	addr   string
+	mitm   *mitm
+	stats  StatsService
+	logger *eventLogger
 }
 
 func NewProxy(addr string) *Proxy {
+	stats := NewStatsService()
+	logger := newEventLogger()
+	m := newMitm(stats, logger)
+
 	return &Proxy{
-		addr: addr,
-		mitm: newMitm(),
+		addr:   addr,
+		mitm:   m,
+		stats:  &hostStatsService{StatsService: stats, hosts: m.hostStats},
+		logger: logger,
 	}
 }
 
 func (p *Proxy) ListenAndServe() error {
-	return http.ListenAndServe(p.addr, p.mitm)
+	l, err := net.Listen("tcp", p.addr)
+	if err != nil {
+		return err
+	}
+
+	return p.mitm.newServer(p.addr).Serve(p.mitm.limitListener(l))
+}
+
+// ListenAndServeSOCKS accepts SOCKS4, SOCKS4a and SOCKS5 clients on addr.
+// It can run along with ListenAndServe, sharing the hooks and criteria
+func (p *Proxy) ListenAndServeSOCKS(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	return p.ServeSOCKS(l)
+}
+
+func (p *Proxy) ServeSOCKS(l net.Listener) error {
+	return p.mitm.ServeSOCKS(l)
+}
+
+// ListenAndServeTransparent accepts connections redirected to addr, for
+// example with iptables REDIRECT rules, and intercepts them as if they
+// were sent through a CONNECT tunnel to their original destination
+func (p *Proxy) ListenAndServeTransparent(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	return p.ServeTransparent(l)
+}
+
+func (p *Proxy) ServeTransparent(l net.Listener) error {
+	return p.mitm.ServeTransparent(l)
+}
+
+// SetOriginalDestination sets how the destination of transparent
+// connections is recovered. It is SOOriginalDst by default; with nil only
+// the TLS SNI and the HTTP Host header are used
+func (p *Proxy) SetOriginalDestination(d OriginalDestination) {
+	p.mitm.SetOriginalDestination(d)
+}
+
+// ListenAndServeReverse runs a reverse proxy listener on addr forwarding
+// every request to upstream. The requests go through the same criteria
+// and hooks as the requests of CONNECT tunnels
+func (p *Proxy) ListenAndServeReverse(addr string, upstream *url.URL) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	return p.ServeReverse(l, upstream)
+}
+
+// ListenAndServeReverseTLS is like ListenAndServeReverse, but serves
+// HTTPS. If cert is nil, certificates are forged by the proxy CA
+func (p *Proxy) ListenAndServeReverseTLS(addr string, upstream *url.URL, cert *tls.Certificate) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	return p.ServeReverseTLS(l, upstream, cert)
+}
+
+func (p *Proxy) ServeReverse(l net.Listener, upstream *url.URL) error {
+	return p.mitm.ServeReverse(l, upstream, nil)
+}
+
+func (p *Proxy) ServeReverseTLS(l net.Listener, upstream *url.URL, cert *tls.Certificate) error {
+	return p.mitm.ServeReverse(l, upstream, p.mitm.reverseTLSConfig(upstream, cert))
 }
 
 func (p *Proxy) Addr() string {
@@ -36,53 +125,317 @@ func (p *Proxy) URL() *url.URL {
 }
 
 func (p *Proxy) GetStats() Stats {
-	return GetStatsService().Get()
+	return p.stats.Get()
+}
+
+func (p *Proxy) GetHistograms() map[string]Histogram {
+	return p.stats.GetHistograms()
+}
+
+func (p *Proxy) ResetStats() {
+	p.stats.Reset()
+}
+
+// Metrics implements MetricsSource
+func (p *Proxy) Metrics() Stats {
+	return Stats{
+		StatCACacheSize: p.mitm.ca.CacheSize(),
+	}
+}
+
+// MetricsHandler returns a handler exporting the stats of the proxy in
+// OpenMetrics text format. Other sources, such as a GRPCServer, can be
+// added to the exported metrics
+func (p *Proxy) MetricsHandler(sources ...MetricsSource) http.Handler {
+	return &metricsHandler{
+		stats:   p.stats,
+		sources: append([]MetricsSource{p}, sources...),
+		logger:  p.mitm.logger,
+	}
+}
+
+// StatsService returns the stats of the proxy, so that they can be shared
+// with other components such as the GRPCServer
+func (p *Proxy) StatsService() StatsService {
+	return p.stats
+}
+
+// NewGRPCServer returns a gRPC server on addr that reports the stats of
+// the proxy and manages its passthrough hosts
+func (p *Proxy) NewGRPCServer(addr string) *GRPCServer {
+	s := NewGRPCServer(addr)
+	s.SetStatsService(p.stats)
+	s.SetPassthroughService(p)
+
+	return s
+}
+
+// SetLogger sets the logger used by the proxy and its hooks, including
+// the hooks already registered. By default slog.Default() is used; nil
+// discards every record
+func (p *Proxy) SetLogger(logger *slog.Logger) {
+	p.logger.setLogger(logger)
+}
+
+// SubscribeErrors returns a channel receiving an event for every error
+// logged by the proxy, and a function to unsubscribe. Events are dropped
+// when the channel is full
+func (p *Proxy) SubscribeErrors() (<-chan ErrorEvent, func()) {
+	return p.logger.subscribe()
+}
+
+// SetUpstreamProxies routes the connections to the destination of both
+// plain HTTP requests and CONNECT tunnels through upstream proxies. nil
+// connects directly to every host
+func (p *Proxy) SetUpstreamProxies(u *UpstreamProxies) {
+	p.mitm.SetUpstreamProxies(u)
+}
+
+// SetUpstreamPool configures the reuse of connections to upstream servers
+// across client connections, see DefaultPoolConfig
+func (p *Proxy) SetUpstreamPool(config PoolConfig) {
+	p.mitm.SetUpstreamPool(config)
+}
+
+// SetMaxHostStats sets the number of hosts whose requests are counted
+// separately in the requests-by-host stats, see DefaultMaxHostStats. The
+// requests to other hosts are counted with the label OtherHostsLabel; 0
+// counts every request with it. Hosts already counted keep their label
+func (p *Proxy) SetMaxHostStats(max int) {
+	p.mitm.hostStats.setMax(max)
+}
+
+// SetLimits sets the timeouts of every phase of the connections, the
+// maximum size of request headers and the maximum number of client
+// connections, see DefaultLimits. ListenAndServe has to be called after
+// SetLimits for the limits of the HTTP listener to apply
+func (p *Proxy) SetLimits(l Limits) {
+	p.mitm.SetLimits(l)
+}
+
+// SetResolver sets how the host names of upstream servers are resolved,
+// for example to send them to local test servers. nil uses the system
+// resolver
+func (p *Proxy) SetResolver(r *Resolver) {
+	p.mitm.SetResolver(r)
+}
+
+// SetMapRules sets the rules serving requests from local files or sending
+// them to other URLs, checked before the requests are sent upstream. nil
+// disables them
+func (p *Proxy) SetMapRules(mr *MapRules) {
+	p.mitm.SetMapRules(mr)
+}
+
+// SetFaults sets the latency, bandwidth limits, resets, truncated bodies
+// and status codes injected in the requests of inspected tunnels. nil
+// disables them
+func (p *Proxy) SetFaults(f *Faults) {
+	p.mitm.SetFaults(f)
 }
 
 func (p *Proxy) SetDomainRegex(re *regexp.Regexp) {
-	criteria := p.mitm.GetCriteria()
-	criteria = criteria.WithDomainRegex(re)
-	p.mitm.SetCriteria(criteria)
+	p.mitm.UpdateCriteria(func(c *criteria) *criteria {
+		return c.WithDomainRegex(re)
+	})
+}
+
+// SetPinnedHosts sets the patterns of the hosts whose connections are
+// passed through without terminating TLS, even if they match the domain
+// regex or the scope
+func (p *Proxy) SetPinnedHosts(patterns []string) error {
+	return p.mitm.SetPinnedHosts(patterns)
+}
+
+func (p *Proxy) PinnedHosts() []string {
+	return p.mitm.PinnedHosts()
+}
+
+// SetPassthroughThreshold sets the number of certificate rejections after
+// which the connections to a host are passed through, see
+// DefaultPassthroughThreshold
+func (p *Proxy) SetPassthroughThreshold(n int) {
+	p.mitm.SetPassthroughThreshold(n)
+}
+
+func (p *Proxy) PassthroughHosts() []PassthroughHost {
+	return p.mitm.PassthroughHosts()
+}
+
+func (p *Proxy) ResetPassthroughHost(host string) {
+	p.mitm.ResetPassthroughHost(host)
+}
+
+func (p *Proxy) SetScope(s *Scope) {
+	p.mitm.UpdateCriteria(func(c *criteria) *criteria {
+		return c.WithScope(s)
+	})
 }
 
-func (p *Proxy) AddRequestFilter(f RequestFilter) {
+func (p *Proxy) InScope(rawURL string) (bool, error) {
+	r, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return false, err
+	}
+
 	criteria := p.mitm.GetCriteria()
-	criteria = criteria.AddRequestFilter(f)
-	p.mitm.SetCriteria(criteria)
+	port := portOrDefault(r.URL.Port(), r.URL.Scheme)
+
+	return criteria.shouldInterceptHost(r.URL.Scheme, r.URL.Hostname(), port) && criteria.shouldInterceptRequest(r), nil
+}
+
+func (p *Proxy) AddRequestFilter(f RequestFilter, opts ...RegisterOption) *Handle {
+	reg := newRegistration(f, opts)
+
+	p.mitm.UpdateCriteria(func(c *criteria) *criteria {
+		return c.AddRequestFilter(f, reg)
+	})
+
+	return p.filterHandle(reg.id)
+}
+
+func (p *Proxy) RemoveRequestFilter(id uint64) {
+	p.mitm.UpdateCriteria(func(c *criteria) *criteria {
+		return c.RemoveRequestFilter(id)
+	})
+}
+
+func (p *Proxy) SetRequestFilterEnabled(id uint64, enabled bool) {
+	p.mitm.UpdateCriteria(func(c *criteria) *criteria {
+		return c.SetRequestFilterEnabled(id, enabled)
+	})
+}
+
+func (p *Proxy) RequestFilters() []FilterInfo {
+	return p.mitm.GetCriteria().ListRequestFilters()
+}
+
+func (p *Proxy) AddRequestInHook(h HookRequestRead, opts ...RegisterOption) *Handle {
+	return p.addHook(RequestInHook(h, opts...))
 }
 
-func (p *Proxy) AddRequestInHook(h HookRequestRead) {
-	hooks := p.mitm.GetHooks()
-	hooks = hooks.AddRequestInHook(h)
-	p.mitm.SetHooks(hooks)
+func (p *Proxy) AddRequestOutHook(h HookRequestRead, opts ...RegisterOption) *Handle {
+	return p.addHook(RequestOutHook(h, opts...))
 }
 
-func (p *Proxy) AddRequestOutHook(h HookRequestRead) {
-	hooks := p.mitm.GetHooks()
-	hooks = hooks.AddRequestOutHook(h)
-	p.mitm.SetHooks(hooks)
+func (p *Proxy) AddRequestModHook(h HookRequestMod, opts ...RegisterOption) *Handle {
+	return p.addHook(RequestModHook(h, opts...))
 }
 
-func (p *Proxy) AddRequestModHook(h HookRequestMod) {
-	hooks := p.mitm.GetHooks()
-	hooks = hooks.AddRequestModHook(h)
-	p.mitm.SetHooks(hooks)
+func (p *Proxy) AddResponseInHook(h HookResponseRead, opts ...RegisterOption) *Handle {
+	return p.addHook(ResponseInHook(h, opts...))
 }
 
-func (p *Proxy) AddResponseInHook(h HookResponseRead) {
-	hooks := p.mitm.GetHooks()
-	hooks = hooks.AddResponseInHook(h)
-	p.mitm.SetHooks(hooks)
+func (p *Proxy) AddResponseOutHook(h HookResponseRead, opts ...RegisterOption) *Handle {
+	return p.addHook(ResponseOutHook(h, opts...))
 }
 
-func (p *Proxy) AddResponseOutHook(h HookResponseRead) {
-	hooks := p.mitm.GetHooks()
-	hooks = hooks.AddResponseOutHook(h)
-	p.mitm.SetHooks(hooks)
+func (p *Proxy) AddResponseModHook(h HookResponseMod, opts ...RegisterOption) *Handle {
+	return p.addHook(ResponseModHook(h, opts...))
 }
 
-func (p *Proxy) AddResponseModHook(h HookResponseMod) {
-	hooks := p.mitm.GetHooks()
-	hooks = hooks.AddResponseModHook(h)
-	p.mitm.SetHooks(hooks)
+// AddErrorHook adds a hook receiving the errors that prevented the proxy
+// from completing a request. Error hooks run like read hooks
+func (p *Proxy) AddErrorHook(h HookErrorRead, opts ...RegisterOption) *Handle {
+	return p.addHook(ErrorHook(h, opts...))
+}
+
+// AddStreamHook adds a hook receiving the data of the tunnels that do not
+// carry HTTP and are passed through
+func (p *Proxy) AddStreamHook(h HookStreamRead, opts ...RegisterOption) *Handle {
+	return p.addHook(StreamHook(h, opts...))
+}
+
+// AddConnectionHook adds a hook receiving the host, transferred bytes and
+// duration of every tunnel that is passed through, once it is closed
+func (p *Proxy) AddConnectionHook(h HookConnectionRead, opts ...RegisterOption) *Handle {
+	return p.addHook(ConnectionHook(h, opts...))
+}
+
+// AddMatchReplace adds the request and response mod hooks applying the
+// rules of mr. The handles are in the same order as mr.Hooks
+func (p *Proxy) AddMatchReplace(mr *MatchReplace, opts ...RegisterOption) []*Handle {
+	handles := []*Handle{}
+	for _, hr := range mr.Hooks(opts...) {
+		handles = append(handles, p.addHook(hr))
+	}
+
+	return handles
+}
+
+// SetErrorPage sets the function building the response sent to clients
+// when a request fails. By default DefaultErrorPage is used
+func (p *Proxy) SetErrorPage(page ErrorPage) {
+	p.mitm.SetErrorPage(page)
+}
+
+// SetCredentialChecker requires clients to authenticate with Basic
+// credentials in the Proxy-Authorization header. nil disables the
+// authentication
+func (p *Proxy) SetCredentialChecker(c CredentialChecker) {
+	p.mitm.SetCredentialChecker(c)
+}
+
+// ReplaceHooks atomically replaces every registered hook with the given
+// ones. The returned handles are in the same order as the registrations
+func (p *Proxy) ReplaceHooks(registrations ...HookRegistration) []*Handle {
+	var newHooks *hooks
+	handles := []*Handle{}
+	for _, hr := range registrations {
+		var id uint64
+		newHooks, id = hr.addTo(newHooks, p.stats, p.logger)
+		handles = append(handles, p.hookHandle(id))
+	}
+
+	var oldHooks *hooks
+	p.mitm.UpdateHooks(func(h *hooks) *hooks {
+		oldHooks = h
+		return newHooks
+	})
+	oldHooks.close()
+
+	return handles
+}
+
+func (p *Proxy) RemoveHook(id uint64) {
+	p.mitm.UpdateHooks(func(h *hooks) *hooks {
+		return h.Remove(id)
+	})
+}
+
+func (p *Proxy) SetHookEnabled(id uint64, enabled bool) {
+	p.mitm.UpdateHooks(func(h *hooks) *hooks {
+		return h.SetEnabled(id, enabled)
+	})
+}
+
+func (p *Proxy) Hooks() []HookInfo {
+	return p.mitm.GetHooks().List()
+}
+
+func (p *Proxy) addHook(hr HookRegistration) *Handle {
+	var id uint64
+	p.mitm.UpdateHooks(func(h *hooks) *hooks {
+		var newHooks *hooks
+		newHooks, id = hr.addTo(h, p.stats, p.logger)
+		return newHooks
+	})
+
+	return p.hookHandle(id)
+}
+
+func (p *Proxy) hookHandle(id uint64) *Handle {
+	return &Handle{
+		id:         id,
+		remove:     p.RemoveHook,
+		setEnabled: p.SetHookEnabled,
+	}
+}
+
+func (p *Proxy) filterHandle(id uint64) *Handle {
+	return &Handle{
+		id:         id,
+		remove:     p.RemoveRequestFilter,
+		setEnabled: p.SetRequestFilterEnabled,
+	}
 }
@@ -1,6 +1,7 @@
 package efincore
 
 import (
+	"bufio"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -792,6 +794,199 @@ func TestResponseInModAndOutHooks_OutHookAndServerGetModifiedBody_UsingRBodyGetB
 	}
 }
 
+func TestRemoveHook_HookNotCalledAfterRemove(t *testing.T) {
+	proxy := runTestProxy(t)
+
+	calls := 0
+	handle := proxy.AddRequestModHook(HookRequestModFunc(func(r *http.Request, id uuid.UUID) error {
+		calls++
+		return nil
+	}), WithName("counter"))
+
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {})
+	client := newTestClientProxy(t, proxy.URL().String())
+
+	if _, err := client.Get(server.URL); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	handle.Remove()
+
+	if _, err := client.Get(server.URL); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("hook calls: got '%d', expected '%d'", calls, 1)
+	}
+
+	if len(proxy.Hooks()) != 0 {
+		t.Errorf("expected no hooks to be registered, got %v", proxy.Hooks())
+	}
+}
+
+func TestDisableRequestFilter_RequestsAreIntercepted(t *testing.T) {
+	proxy := runTestProxy(t)
+
+	calls := 0
+	proxy.AddRequestModHook(HookRequestModFunc(func(r *http.Request, id uuid.UUID) error {
+		calls++
+		return nil
+	}))
+
+	handle := proxy.AddRequestFilter(RequestFilterFunc(func(r *http.Request) bool {
+		return false
+	}), WithName("reject-all"), WithDescription("rejects every request"))
+
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {})
+	client := newTestClientProxy(t, proxy.URL().String())
+
+	if _, err := client.Get(server.URL); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	if calls != 0 {
+		t.Errorf("hook calls with filter enabled: got '%d', expected '%d'", calls, 0)
+	}
+
+	handle.Disable()
+
+	if _, err := client.Get(server.URL); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("hook calls with filter disabled: got '%d', expected '%d'", calls, 1)
+	}
+
+	filters := proxy.RequestFilters()
+	if len(filters) != 1 || filters[0].Name != "reject-all" || filters[0].Enabled {
+		t.Errorf("unexpected filters list: %v", filters)
+	}
+}
+
+func TestFailingRequestModHook_FailClosed_ClientGets502(t *testing.T) {
+	proxy := runTestProxy(t)
+
+	handle := proxy.AddRequestModHook(HookRequestModFunc(func(r *http.Request, id uuid.UUID) error {
+		panic("boom")
+	}))
+
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {})
+	client := newTestClientProxy(t, proxy.URL().String())
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("response status code: got '%d', expected '%d'", resp.StatusCode, http.StatusBadGateway)
+	}
+
+	// the tunnel must still be usable
+	handle.Remove()
+
+	resp, err = client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("response status code: got '%d', expected '%d'", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestFailingResponseModHook_FailClosed_TunnelStillUsable(t *testing.T) {
+	proxy := runTestProxy(t)
+
+	var calls atomic.Int64
+	proxy.AddResponseModHook(HookResponseModFunc(func(r *http.Response, id uuid.UUID) error {
+		if calls.Add(1) == 1 {
+			return errors.New("failed")
+		}
+		return nil
+	}))
+
+	// a body left unread in the tunnel would be parsed as the next response
+	respBody := "HTTP/1.1 418 I'm a teapot\r\nContent-Length: 0\r\n\r\n"
+	var mutex sync.Mutex
+	remoteAddrs := map[string]bool{}
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		remoteAddrs[r.RemoteAddr] = true
+		mutex.Unlock()
+		w.Write([]byte(respBody))
+	})
+	client := newTestClientProxy(t, proxy.URL().String())
+
+	expected := []int{http.StatusBadGateway, http.StatusOK}
+	for i, status := range expected {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("request %d failed: %v", i, err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("could not read response body %d: %v", i, err)
+		}
+
+		if resp.StatusCode != status {
+			t.Errorf("response %d status code: got '%d', expected '%d'", i, resp.StatusCode, status)
+		}
+
+		if status == http.StatusOK && string(body) != respBody {
+			t.Errorf("response %d body: got '%s', expected '%s'", i, body, respBody)
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(remoteAddrs) != 1 {
+		t.Errorf("upstream connections: got '%d', expected '%d'", len(remoteAddrs), 1)
+	}
+}
+
+func TestFailingRequestModHook_FailOpen_ServerGetsUnmodifiedRequest(t *testing.T) {
+	proxy := runTestProxy(t)
+
+	proxy.AddRequestModHook(HookRequestModFunc(func(r *http.Request, id uuid.UUID) error {
+		r.Header.Set("x-modified", "true")
+		return errors.New("failed")
+	}), WithFailurePolicy(HookFailOpen))
+
+	proxy.AddRequestModHook(HookRequestModFunc(func(r *http.Request, id uuid.UUID) error {
+		time.Sleep(time.Second)
+		r.Header.Set("x-slow", "true")
+		return nil
+	}), WithFailurePolicy(HookFailOpen), WithTimeout(20*time.Millisecond))
+
+	var gotModified, gotSlow string
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {
+		gotModified = r.Header.Get("x-modified")
+		gotSlow = r.Header.Get("x-slow")
+	})
+	client := newTestClientProxy(t, proxy.URL().String())
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("response status code: got '%d', expected '%d'", resp.StatusCode, http.StatusOK)
+	}
+
+	if gotModified != "" || gotSlow != "" {
+		t.Errorf("expected changes of failed hooks to be discarded, got x-modified '%s', x-slow '%s'", gotModified, gotSlow)
+	}
+}
+
 func runTestProxy(t *testing.T) *Proxy {
 	port := getFreePort(t)
 	addr := "127.0.0.1:" + strconv.Itoa(port)
@@ -952,3 +1147,35 @@ func TestClosedChanWritePanicHandling(t *testing.T) {
 		c <- struct{}{}
 	}()
 }
+
+// sendTestConnect sends a CONNECT request to the proxy and returns its
+// response and the connection, which is the tunnel if the response is 200
+func sendTestConnect(t *testing.T, proxy *Proxy, target string, header http.Header) (*http.Response, net.Conn) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", proxy.Addr())
+	if err != nil {
+		t.Fatalf("could not connect to proxy: %v", err)
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: target},
+		Host:   target,
+		Header: header,
+	}
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("could not send CONNECT request: %v", err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		t.Fatalf("could not read CONNECT response: %v", err)
+	}
+
+	return resp, conn
+}
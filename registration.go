@@ -0,0 +1,280 @@
+package efincore
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+type HookKind int
+
+const (
+	HookKindRequestIn HookKind = iota
+	HookKindRequestMod
+	HookKindRequestOut
+	HookKindResponseIn
+	HookKindResponseMod
+	HookKindResponseOut
+	HookKindError
+	HookKindStream
+	HookKindConnection
+)
+
+func (k HookKind) String() string {
+	switch k {
+	case HookKindRequestIn:
+		return "request-in"
+	case HookKindRequestMod:
+		return "request-mod"
+	case HookKindRequestOut:
+		return "request-out"
+	case HookKindResponseIn:
+		return "response-in"
+	case HookKindResponseMod:
+		return "response-mod"
+	case HookKindResponseOut:
+		return "response-out"
+	case HookKindError:
+		return "error"
+	case HookKindStream:
+		return "stream"
+	case HookKindConnection:
+		return "connection"
+	}
+
+	return "unknown"
+}
+
+type HookInfo struct {
+	ID          uint64
+	Kind        HookKind
+	Name        string
+	Description string
+	Enabled     bool
+	Priority    int
+	Before      []string
+	After       []string
+
+	Timeout       time.Duration
+	FailurePolicy HookFailurePolicy
+	Failures      int64
+
+	// DroppedEvents and Ordering are only used by read hooks
+	DroppedEvents int64
+	Ordering      HookOrdering
+}
+
+type FilterInfo struct {
+	ID          uint64
+	Name        string
+	Description string
+	Enabled     bool
+}
+
+// registration holds the metadata shared by every hook and request filter
+// added to a Proxy
+type registration struct {
+	id          uint64
+	name        string
+	description string
+	enabled     bool
+
+	// ordering of mod hooks
+	priority int
+	before   []string
+	after    []string
+
+	// failure handling of hooks. The counters are pointers so that they
+	// are shared by every copy of the hooks
+	timeout       time.Duration
+	failurePolicy HookFailurePolicy
+	maxFailures   int64
+	failures      *atomic.Int64
+	autoDisabled  *atomic.Bool
+
+	// dispatching of read hooks
+	queueDepth  int
+	queuePolicy QueueFullPolicy
+	workers     int
+	queue       *hookQueue
+	ordering    HookOrdering
+
+	stats  StatsService
+	logger *eventLogger
+}
+
+type RegisterOption func(*registration)
+
+func WithName(name string) RegisterOption {
+	return func(r *registration) {
+		r.name = name
+	}
+}
+
+func WithDescription(description string) RegisterOption {
+	return func(r *registration) {
+		r.description = description
+	}
+}
+
+// WithDisabled registers a hook or filter without enabling it
+func WithDisabled() RegisterOption {
+	return func(r *registration) {
+		r.enabled = false
+	}
+}
+
+// WithPriority sets the priority of a mod hook. Hooks with lower values
+// run first; hooks with the same priority run in registration order.
+func WithPriority(priority int) RegisterOption {
+	return func(r *registration) {
+		r.priority = priority
+	}
+}
+
+// WithBefore makes a mod hook run before the hooks with the given names
+func WithBefore(names ...string) RegisterOption {
+	return func(r *registration) {
+		r.before = append(r.before, names...)
+	}
+}
+
+// WithAfter makes a mod hook run after the hooks with the given names
+func WithAfter(names ...string) RegisterOption {
+	return func(r *registration) {
+		r.after = append(r.after, names...)
+	}
+}
+
+var lastRegistrationID atomic.Uint64
+
+func newRegistration(target any, opts []RegisterOption) registration {
+	reg := registration{
+		id:      lastRegistrationID.Add(1),
+		name:    defaultRegistrationName(target),
+		enabled: true,
+
+		failures:     &atomic.Int64{},
+		autoDisabled: &atomic.Bool{},
+
+		queueDepth: DefaultHookQueueDepth,
+		workers:    DefaultHookWorkers,
+
+		stats: discardStats,
+	}
+
+	for _, opt := range opts {
+		opt(&reg)
+	}
+
+	return reg
+}
+
+func defaultRegistrationName(target any) string {
+	v := reflect.ValueOf(target)
+	if v.Kind() == reflect.Func {
+		if f := runtime.FuncForPC(v.Pointer()); f != nil {
+			return f.Name()
+		}
+	}
+
+	return fmt.Sprintf("%T", target)
+}
+
+// Handle identifies a hook or request filter added to a Proxy
+type Handle struct {
+	id uint64
+
+	remove     func(uint64)
+	setEnabled func(uint64, bool)
+}
+
+func (h *Handle) ID() uint64 {
+	return h.id
+}
+
+func (h *Handle) Remove() {
+	h.remove(h.id)
+}
+
+func (h *Handle) Enable() {
+	h.setEnabled(h.id, true)
+}
+
+func (h *Handle) Disable() {
+	h.setEnabled(h.id, false)
+}
+
+// HookRegistration describes a hook to be added with Proxy.ReplaceHooks
+type HookRegistration struct {
+	kind HookKind
+	hook any
+	opts []RegisterOption
+}
+
+func RequestInHook(h HookRequestRead, opts ...RegisterOption) HookRegistration {
+	return HookRegistration{HookKindRequestIn, h, opts}
+}
+
+func RequestModHook(h HookRequestMod, opts ...RegisterOption) HookRegistration {
+	return HookRegistration{HookKindRequestMod, h, opts}
+}
+
+func RequestOutHook(h HookRequestRead, opts ...RegisterOption) HookRegistration {
+	return HookRegistration{HookKindRequestOut, h, opts}
+}
+
+func ResponseInHook(h HookResponseRead, opts ...RegisterOption) HookRegistration {
+	return HookRegistration{HookKindResponseIn, h, opts}
+}
+
+func ResponseModHook(h HookResponseMod, opts ...RegisterOption) HookRegistration {
+	return HookRegistration{HookKindResponseMod, h, opts}
+}
+
+func ResponseOutHook(h HookResponseRead, opts ...RegisterOption) HookRegistration {
+	return HookRegistration{HookKindResponseOut, h, opts}
+}
+
+func ErrorHook(h HookErrorRead, opts ...RegisterOption) HookRegistration {
+	return HookRegistration{HookKindError, h, opts}
+}
+
+func StreamHook(h HookStreamRead, opts ...RegisterOption) HookRegistration {
+	return HookRegistration{HookKindStream, h, opts}
+}
+
+func ConnectionHook(h HookConnectionRead, opts ...RegisterOption) HookRegistration {
+	return HookRegistration{HookKindConnection, h, opts}
+}
+
+func (hr HookRegistration) addTo(h *hooks, stats StatsService, logger *eventLogger) (*hooks, uint64) {
+	reg := newRegistration(hr.hook, hr.opts)
+	reg.stats = stats
+	reg.logger = logger
+
+	switch hr.kind {
+	case HookKindRequestIn:
+		h = h.AddRequestInHook(hr.hook.(HookRequestRead), reg)
+	case HookKindRequestMod:
+		h = h.AddRequestModHook(hr.hook.(HookRequestMod), reg)
+	case HookKindRequestOut:
+		h = h.AddRequestOutHook(hr.hook.(HookRequestRead), reg)
+	case HookKindResponseIn:
+		h = h.AddResponseInHook(hr.hook.(HookResponseRead), reg)
+	case HookKindResponseMod:
+		h = h.AddResponseModHook(hr.hook.(HookResponseMod), reg)
+	case HookKindResponseOut:
+		h = h.AddResponseOutHook(hr.hook.(HookResponseRead), reg)
+	case HookKindError:
+		h = h.AddErrorHook(hr.hook.(HookErrorRead), reg)
+	case HookKindStream:
+		h = h.AddStreamHook(hr.hook.(HookStreamRead), reg)
+	case HookKindConnection:
+		h = h.AddConnectionHook(hr.hook.(HookConnectionRead), reg)
+	}
+
+	return h, reg.id
+}
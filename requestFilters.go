@@ -42,3 +42,33 @@ func ExcludeFileTypes(includeRegex, excludeRegex string) (RequestFilter, error)
 		return includeRe.MatchString(header) || !excludeRe.MatchString(header)
 	}), nil
 }
+
+func And(filters ...RequestFilter) RequestFilter {
+	return RequestFilterFunc(func(r *http.Request) bool {
+		for _, f := range filters {
+			if !f.ShouldInterceptRequest(r) {
+				return false
+			}
+		}
+
+		return true
+	})
+}
+
+func Or(filters ...RequestFilter) RequestFilter {
+	return RequestFilterFunc(func(r *http.Request) bool {
+		for _, f := range filters {
+			if f.ShouldInterceptRequest(r) {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+func Not(f RequestFilter) RequestFilter {
+	return RequestFilterFunc(func(r *http.Request) bool {
+		return !f.ShouldInterceptRequest(r)
+	})
+}
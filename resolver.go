@@ -0,0 +1,460 @@
+package efincore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ResolverConfig configures how the host names of upstream servers are
+// resolved. Hosts maps host names, or globs like "*.example.com", to the
+// IP they resolve to, as an /etc/hosts file would. The other names are
+// resolved by Server, a DNS server as host:port, by the DNS-over-HTTPS
+// endpoint DoHURL, or by the system resolver if both are empty.
+//
+// Tunnels going through an upstream proxy are opened to the IP in Hosts,
+// keeping the host name as TLS server name, and the other names are
+// resolved by the proxy. Plain HTTP requests sent through an upstream
+// proxy always use the host name
+type ResolverConfig struct {
+	Hosts  map[string]string `json:"hosts"`
+	Server string            `json:"server"`
+	DoHURL string            `json:"doh_url"`
+}
+
+// Resolver resolves the host names of upstream servers. A nil Resolver
+// uses the system resolver
+type Resolver struct {
+	config   ResolverConfig
+	hosts    map[string]net.IP
+	globs    []hostOverride
+	resolver *net.Resolver
+}
+
+type hostOverride struct {
+	match compiledScopeRule
+	ip    net.IP
+}
+
+func NewResolver(config ResolverConfig) (*Resolver, error) {
+	r := &Resolver{
+		config:   config,
+		hosts:    map[string]net.IP{},
+		resolver: net.DefaultResolver,
+	}
+
+	for host, rawIP := range config.Hosts {
+		ip := net.ParseIP(rawIP)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP '%s' for host '%s'", rawIP, host)
+		}
+
+		if !strings.Contains(host, "*") {
+			r.hosts[strings.ToLower(host)] = ip
+			continue
+		}
+
+		match, err := compileScopeRule(ScopeRule{Host: host})
+		if err != nil {
+			return nil, fmt.Errorf("invalid host '%s': %w", host, err)
+		}
+		r.globs = append(r.globs, hostOverride{match: match, ip: ip})
+	}
+
+	switch {
+	case config.Server != "" && config.DoHURL != "":
+		return nil, errors.New("only one of DNS server and DNS-over-HTTPS URL can be set")
+
+	case config.Server != "":
+		if _, _, err := net.SplitHostPort(config.Server); err != nil {
+			return nil, fmt.Errorf("invalid DNS server '%s': %w", config.Server, err)
+		}
+
+		r.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, config.Server)
+			},
+		}
+
+	case config.DoHURL != "":
+		dohURL, err := url.Parse(config.DoHURL)
+		if err != nil || dohURL.Scheme != "https" && dohURL.Scheme != "http" || dohURL.Host == "" {
+			return nil, fmt.Errorf("invalid DNS-over-HTTPS URL '%s'", config.DoHURL)
+		}
+
+		// requests to the DoH server must not go through this resolver
+		client := &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+		r.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return newDoHConn(client, dohURL.String()), nil
+			},
+		}
+	}
+
+	return r, nil
+}
+
+type resolverFile struct {
+	ResolverConfig
+
+	// HostsFile is the path of a file in /etc/hosts format whose entries
+	// are added to Hosts
+	HostsFile string `json:"hosts_file"`
+}
+
+// ParseResolver reads the configuration of a resolver in JSON format
+func ParseResolver(r io.Reader) (*Resolver, error) {
+	var f resolverFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("could not decode resolver: %v", err)
+	}
+
+	if f.HostsFile != "" {
+		hf, err := os.Open(f.HostsFile)
+		if err != nil {
+			return nil, err
+		}
+		defer hf.Close()
+
+		hosts, err := ParseHosts(hf)
+		if err != nil {
+			return nil, err
+		}
+
+		if f.Hosts == nil {
+			f.Hosts = map[string]string{}
+		}
+		for host, ip := range hosts {
+			if _, ok := f.Hosts[host]; !ok {
+				f.Hosts[host] = ip
+			}
+		}
+	}
+
+	return NewResolver(f.ResolverConfig)
+}
+
+func LoadResolver(path string) (*Resolver, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseResolver(f)
+}
+
+// ParseHosts reads host overrides in /etc/hosts format: an IP followed by
+// the names that resolve to it on each line, and comments starting with
+// '#'. The first entry of a name is used
+func ParseHosts(r io.Reader) (map[string]string, error) {
+	hosts := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if net.ParseIP(fields[0]) == nil {
+			return nil, fmt.Errorf("invalid IP '%s' in line %d", fields[0], line)
+		}
+
+		if len(fields) == 1 {
+			return nil, fmt.Errorf("missing host names in line %d", line)
+		}
+
+		for _, host := range fields[1:] {
+			if _, ok := hosts[host]; !ok {
+				hosts[host] = fields[0]
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return hosts, nil
+}
+
+func (r *Resolver) Config() ResolverConfig {
+	if r == nil {
+		return ResolverConfig{}
+	}
+
+	return r.config
+}
+
+// LookupIP returns the IPs of host. IP literals are returned as they are
+func (r *Resolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	if r == nil {
+		return net.DefaultResolver.LookupIP(ctx, "ip", host)
+	}
+
+	if ip, ok := r.override(host); ok {
+		return []net.IP{ip}, nil
+	}
+
+	return r.resolver.LookupIP(ctx, "ip", host)
+}
+
+// override returns the IP host resolves to in Hosts, if any
+func (r *Resolver) override(host string) (net.IP, bool) {
+	if r == nil {
+		return nil, false
+	}
+
+	if ip, ok := r.hosts[strings.ToLower(host)]; ok {
+		return ip, true
+	}
+
+	for _, o := range r.globs {
+		if o.match.matchesHost("", host, 0) {
+			return o.ip, true
+		}
+	}
+
+	return nil, false
+}
+
+// dial connects to addr trying every IP of its host in order
+func (r *Resolver) dial(ctx context.Context, dialer *net.Dialer, addr string) (net.Conn, error) {
+	if r == nil {
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := r.LookupIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var firstErr error
+	for _, ip := range ips {
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, firstErr
+}
+
+// dohConn sends the DNS queries written by the Go resolver to a
+// DNS-over-HTTPS server (RFC 8484). Messages use the framing of DNS over
+// TCP, a 2 bytes length followed by the message
+type dohConn struct {
+	client *http.Client
+	url    string
+
+	mutex    *sync.Mutex
+	deadline time.Time
+	wbuf     bytes.Buffer
+	rbuf     bytes.Buffer
+}
+
+func newDoHConn(client *http.Client, url string) *dohConn {
+	return &dohConn{
+		client: client,
+		url:    url,
+		mutex:  &sync.Mutex{},
+	}
+}
+
+func (c *dohConn) Write(p []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.wbuf.Write(p)
+
+	for c.wbuf.Len() >= 2 {
+		size := int(binary.BigEndian.Uint16(c.wbuf.Bytes()))
+		if c.wbuf.Len() < 2+size {
+			break
+		}
+
+		c.wbuf.Next(2)
+		query := bytes.Clone(c.wbuf.Next(size))
+
+		answer, err := c.exchange(query)
+		if err != nil {
+			return 0, err
+		}
+
+		c.rbuf.Write(binary.BigEndian.AppendUint16(nil, uint16(len(answer))))
+		c.rbuf.Write(answer)
+	}
+
+	return len(p), nil
+}
+
+func (c *dohConn) exchange(query []byte) ([]byte, error) {
+	ctx := context.Background()
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("DNS-over-HTTPS request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DNS-over-HTTPS server answered '%s'", resp.Status)
+	}
+
+	answer, err := io.ReadAll(io.LimitReader(resp.Body, 0xffff+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(answer) > 0xffff {
+		return nil, errors.New("DNS-over-HTTPS answer too large")
+	}
+
+	return answer, nil
+}
+
+func (c *dohConn) Read(p []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.rbuf.Len() == 0 {
+		return 0, io.EOF
+	}
+
+	return c.rbuf.Read(p)
+}
+
+func (c *dohConn) Close() error {
+	return nil
+}
+
+func (c *dohConn) LocalAddr() net.Addr {
+	return dohAddr{}
+}
+
+func (c *dohConn) RemoteAddr() net.Addr {
+	return dohAddr{}
+}
+
+func (c *dohConn) SetDeadline(t time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.deadline = t
+	return nil
+}
+
+func (c *dohConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *dohConn) SetWriteDeadline(t time.Time) error {
+	return c.SetDeadline(t)
+}
+
+type dohAddr struct{}
+
+func (dohAddr) Network() string {
+	return "doh"
+}
+
+func (dohAddr) String() string {
+	return "doh"
+}
+
+type resolvedIPKey struct{}
+
+func withResolvedIP(ctx context.Context, ip net.IP) context.Context {
+	return context.WithValue(ctx, resolvedIPKey{}, ip)
+}
+
+// withResolvedIPTrace returns a copy of r that records the IP of the
+// connection it is sent over, for plain HTTP requests whose connection is
+// only known once they are sent
+func withResolvedIPTrace(r *http.Request) *http.Request {
+	resolved := &atomic.Pointer[net.IP]{}
+
+	ctx := context.WithValue(r.Context(), resolvedIPKey{}, resolved)
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if addr, ok := info.Conn.RemoteAddr().(*net.TCPAddr); ok {
+				resolved.Store(&addr.IP)
+			}
+		},
+	})
+
+	return r.WithContext(ctx)
+}
+
+// ResolvedIP returns the IP of the upstream server a request is sent to.
+// Through an upstream proxy it is only known for the tunnels to hosts in
+// ResolverConfig.Hosts. For plain HTTP requests it is known once the
+// request is sent
+func ResolvedIP(ctx context.Context) (net.IP, bool) {
+	switch v := ctx.Value(resolvedIPKey{}).(type) {
+	case net.IP:
+		return v, true
+	case *atomic.Pointer[net.IP]:
+		if ip := v.Load(); ip != nil {
+			return *ip, true
+		}
+	}
+
+	return nil, false
+}
+
+// SetResolver sets how the host names of upstream servers are resolved;
+// nil uses the system resolver. Idle upstream connections are closed, so
+// that the next requests use the new addresses
+func (m *mitm) SetResolver(r *Resolver) {
+	m.resolver.Store(r)
+
+	m.pool.closeIdle()
+	m.client.Load().CloseIdleConnections()
+}
@@ -0,0 +1,362 @@
+package efincore
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testDNSAnswer answers a DNS query with ip for A questions, and with no
+// records for any other question
+func testDNSAnswer(query []byte, ip net.IP) []byte {
+	// skip the header and the labels of the question name
+	end := 12
+	for end < len(query) && query[end] != 0 {
+		end += int(query[end]) + 1
+	}
+	end += 5
+	qtype := binary.BigEndian.Uint16(query[end-4:])
+
+	answer := append([]byte{}, query[:2]...)
+	answer = append(answer, 0x81, 0x80, 0x00, 0x01)
+	if qtype == 1 {
+		answer = append(answer, 0x00, 0x01)
+	} else {
+		answer = append(answer, 0x00, 0x00)
+	}
+	answer = append(answer, 0x00, 0x00, 0x00, 0x00)
+	answer = append(answer, query[12:end]...)
+
+	if qtype == 1 {
+		answer = append(answer, 0xc0, 0x0c, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x3c, 0x00, 0x04)
+		answer = append(answer, ip.To4()...)
+	}
+
+	return answer
+}
+
+func runTestDNSServer(t *testing.T, ip net.IP) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(testDNSAnswer(buf[:n], ip), addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func runTestDoHServer(t *testing.T, ip net.IP) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		query, err := io.ReadAll(r.Body)
+		if err != nil {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(testDNSAnswer(query, ip))
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL + "/dns-query"
+}
+
+func TestParseHosts(t *testing.T) {
+	hosts, err := ParseHosts(strings.NewReader(`
+# local services
+127.0.0.1 api.example.com www.example.com # web
+::1       ipv6.example.com
+10.0.0.1  api.example.com
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"api.example.com":  "127.0.0.1",
+		"www.example.com":  "127.0.0.1",
+		"ipv6.example.com": "::1",
+	}
+
+	if len(hosts) != len(expected) {
+		t.Errorf("hosts: got '%v', expected '%v'", hosts, expected)
+	}
+	for host, ip := range expected {
+		if hosts[host] != ip {
+			t.Errorf("IP of '%s': got '%s', expected '%s'", host, hosts[host], ip)
+		}
+	}
+
+	for _, invalid := range []string{"example.com 127.0.0.1", "127.0.0.1"} {
+		if _, err := ParseHosts(strings.NewReader(invalid)); err == nil {
+			t.Errorf("invalid hosts '%s' accepted", invalid)
+		}
+	}
+}
+
+func TestResolver_LookupIP(t *testing.T) {
+	resolver, err := NewResolver(ResolverConfig{
+		Hosts: map[string]string{
+			"api.example.com": "127.0.0.1",
+			"*.staging.test":  "127.0.0.2",
+		},
+		Server: runTestDNSServer(t, net.ParseIP("127.0.0.3")),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"API.example.com", "127.0.0.1"},
+		{"web.staging.test", "127.0.0.2"},
+		{"other.example.com", "127.0.0.3"},
+		{"10.1.1.1", "10.1.1.1"},
+	}
+
+	for _, tt := range tests {
+		ips, err := resolver.LookupIP(context.Background(), tt.host)
+		if err != nil {
+			t.Errorf("lookup of '%s' failed: %v", tt.host, err)
+			continue
+		}
+
+		if len(ips) == 0 || ips[0].String() != tt.expected {
+			t.Errorf("IPs of '%s': got '%v', expected '%s'", tt.host, ips, tt.expected)
+		}
+	}
+}
+
+func TestResolver_DoH(t *testing.T) {
+	resolver, err := NewResolver(ResolverConfig{DoHURL: runTestDoHServer(t, net.ParseIP("127.0.0.4"))})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ips, err := resolver.LookupIP(context.Background(), "doh.example.com")
+	if err != nil {
+		t.Fatalf("lookup failed: %v", err)
+	}
+
+	if len(ips) == 0 || ips[0].String() != "127.0.0.4" {
+		t.Errorf("IPs: got '%v', expected '%s'", ips, "127.0.0.4")
+	}
+}
+
+func TestNewResolver_InvalidConfig(t *testing.T) {
+	configs := []ResolverConfig{
+		{Hosts: map[string]string{"example.com": "localhost"}},
+		{Server: "127.0.0.1"},
+		{DoHURL: "ftp://example.com"},
+		{Server: "127.0.0.1:53", DoHURL: "https://example.com/dns-query"},
+	}
+
+	for _, c := range configs {
+		if _, err := NewResolver(c); err == nil {
+			t.Errorf("invalid config '%+v' accepted", c)
+		}
+	}
+}
+
+func TestProxy_ResolverOverridesTunnelHost(t *testing.T) {
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("response"))
+	})
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	proxy := runTestProxy(t)
+
+	resolver, err := NewResolver(ResolverConfig{
+		Hosts: map[string]string{"api.example.com": "127.0.0.1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	proxy.SetResolver(resolver)
+
+	resolvedIPs := make(chan net.IP, 1)
+	proxy.AddRequestInHook(HookRequestReadFunc(func(r *http.Request, id uuid.UUID) error {
+		ip, _ := ResolvedIP(r.Context())
+		resolvedIPs <- ip
+		return nil
+	}))
+
+	resp, err := newTestClientProxy(t, proxy.URL().String()).Get("https://api.example.com:" + serverURL.Port() + "/")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read response body: %v", err)
+	}
+
+	if string(body) != "response" {
+		t.Errorf("response body: got '%s', expected '%s'", body, "response")
+	}
+
+	select {
+	case ip := <-resolvedIPs:
+		if !ip.Equal(net.ParseIP("127.0.0.1")) {
+			t.Errorf("resolved IP: got '%v', expected '%s'", ip, "127.0.0.1")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("request hook not called")
+	}
+}
+
+func TestProxy_SetResolver_ClosesIdleUpstreamConnections(t *testing.T) {
+	server1 := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("server 1"))
+	})
+	serverURL, err := url.Parse(server1.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the second server listens on the same port of another loopback IP
+	l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.2", serverURL.Port()))
+	if err != nil {
+		t.Skipf("could not listen on 127.0.0.2: %v", err)
+	}
+	server2 := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("server 2"))
+	}))
+	server2.Listener.Close()
+	server2.Listener = l
+	server2.TLS = server1.TLS
+	server2.StartTLS()
+	defer server2.Close()
+
+	proxy := runTestProxy(t)
+
+	get := func() string {
+		t.Helper()
+
+		// a new client opens a new tunnel, which takes the idle upstream
+		// connection of the previous one from the pool
+		client := newTestClientProxy(t, proxy.URL().String())
+		defer client.CloseIdleConnections()
+
+		resp, err := client.Get("https://api.example.com:" + serverURL.Port() + "/")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("could not read response body: %v", err)
+		}
+
+		return string(body)
+	}
+
+	for i, ip := range []string{"127.0.0.1", "127.0.0.2"} {
+		resolver, err := NewResolver(ResolverConfig{Hosts: map[string]string{"api.example.com": ip}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		proxy.SetResolver(resolver)
+
+		expected := "server " + strconv.Itoa(i+1)
+		if got := get(); got != expected {
+			t.Errorf("response with host resolved to '%s': got '%s', expected '%s'", ip, got, expected)
+		}
+
+		// the upstream connection is returned to the pool when the tunnel
+		// is closed
+		deadline := time.Now().Add(2 * time.Second)
+		for proxy.GetStats()[StatIdleUpstreamConnections] != 1 {
+			if time.Now().After(deadline) {
+				t.Fatalf("upstream connection not returned to the pool")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestProxy_PlainRequest_RecordsResolvedIP(t *testing.T) {
+	server := newTestServer(func(w http.ResponseWriter, r *http.Request) {})
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	proxy := runTestProxy(t)
+
+	resolver, err := NewResolver(ResolverConfig{
+		Hosts: map[string]string{"api.example.com": "127.0.0.1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	proxy.SetResolver(resolver)
+
+	// the IP is read from the request once it has been sent upstream
+	resolvedIPs := make(chan net.IP, 1)
+	transport := proxy.mitm.client.Load().Transport
+	proxy.mitm.client.Store(&http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		resp, err := transport.RoundTrip(r)
+		ip, _ := ResolvedIP(r.Context())
+		resolvedIPs <- ip
+		return resp, err
+	})})
+
+	resp, err := newTestClientProxy(t, proxy.URL().String()).Get("http://api.example.com:" + serverURL.Port() + "/")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	select {
+	case ip := <-resolvedIPs:
+		if !ip.Equal(net.ParseIP("127.0.0.1")) {
+			t.Errorf("resolved IP: got '%v', expected '%s'", ip, "127.0.0.1")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("request not sent upstream")
+	}
+}
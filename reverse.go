@@ -0,0 +1,156 @@
+package efincore
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reverseRequestTimeout limits the time a client has to send its request
+// when the upstream can not be reached and an error page is sent
+const reverseRequestTimeout = 10 * time.Second
+
+func checkReverseUpstream(upstream *url.URL) error {
+	if upstream == nil {
+		return fmt.Errorf("missing upstream URL")
+	}
+
+	if upstream.Scheme != "http" && upstream.Scheme != "https" {
+		return fmt.Errorf("unsupported upstream scheme '%s'", upstream.Scheme)
+	}
+
+	if upstream.Hostname() == "" {
+		return fmt.Errorf("missing upstream host in '%s'", upstream.String())
+	}
+
+	return nil
+}
+
+// ServeReverse accepts clients on l and forwards their requests to
+// upstream, running them through the same criteria and hooks as the
+// requests of CONNECT tunnels. If tlsConfig is not nil the listener serves
+// HTTPS
+func (m *mitm) ServeReverse(l net.Listener, upstream *url.URL, tlsConfig *tls.Config) error {
+	if err := checkReverseUpstream(upstream); err != nil {
+		return err
+	}
+
+	l = m.limitListener(l)
+	if tlsConfig != nil {
+		l = tls.NewListener(l, tlsConfig)
+	}
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		go m.serveReverseConn(conn, upstream, tlsConfig != nil)
+	}
+}
+
+// reverseTLSConfig returns the TLS configuration of a reverse proxy
+// listener. Without a certificate, one is forged by the CA for the name
+// requested by the client, or the upstream host
+func (m *mitm) reverseTLSConfig(upstream *url.URL, cert *tls.Certificate) *tls.Config {
+	if cert != nil {
+		return &tls.Config{Certificates: []tls.Certificate{*cert}}
+	}
+
+	return &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			name := hello.ServerName
+			if name == "" {
+				name = upstream.Hostname()
+			}
+
+			cert, err := m.ca.GetCertificateFor(name)
+			if err != nil {
+				m.stats.Increase(ErrorStat(ErrorKindCertificate))
+				return nil, err
+			}
+
+			return &cert, nil
+		},
+	}
+}
+
+func (m *mitm) serveReverseConn(conn net.Conn, upstream *url.URL, isTLS bool) {
+	m.stats.Increase(StatActiveConnections)
+	defer m.stats.Decrease(StatActiveConnections)
+
+	defer conn.Close()
+
+	port := portOrDefault(upstream.Port(), upstream.Scheme)
+	hostPort := net.JoinHostPort(upstream.Hostname(), strconv.Itoa(port))
+	logger := m.logger.with(slog.String("client", conn.RemoteAddr().String()), hostAttr(hostPort))
+
+	serverName := ""
+	if upstream.Scheme == "https" {
+		serverName = upstream.Hostname()
+	}
+
+	destConn, err := m.dialPooled(context.Background(), hostPort, serverName)
+	if err != nil {
+		proxyErr := newProxyError(upstream.Hostname(), fmt.Errorf("could not connect to upstream: %w", err))
+		m.reportError(logger, "could not connect to upstream", proxyErr)
+
+		// answer the first request with the error page
+		conn.SetReadDeadline(time.Now().Add(reverseRequestTimeout))
+		if req, err := http.ReadRequest(bufio.NewReader(conn)); err == nil {
+			writeErrorResponse(conn, m.getErrorPage(), req, proxyErr)
+		}
+		return
+	}
+
+	clientScheme := "http"
+	if isTLS {
+		clientScheme = "https"
+	}
+
+	clientIP, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+
+	connectURL := &url.URL{Scheme: upstream.Scheme, Host: hostPort}
+	m.inspectTunnel(conn, destConn, connectURL, func(r *http.Request) {
+		rewriteReverseRequest(r, upstream, clientScheme, clientIP)
+	})
+}
+
+// rewriteReverseRequest makes a request received by a reverse proxy
+// listener target the upstream, keeping the original host and client in
+// X-Forwarded headers
+func rewriteReverseRequest(r *http.Request, upstream *url.URL, clientScheme, clientIP string) {
+	r.Header.Set("X-Forwarded-Host", r.Host)
+	r.Header.Set("X-Forwarded-Proto", clientScheme)
+	if prior := r.Header.Get("X-Forwarded-For"); prior != "" {
+		clientIP = prior + ", " + clientIP
+	}
+	r.Header.Set("X-Forwarded-For", clientIP)
+
+	r.Host = upstream.Host
+
+	if upstream.Path != "" {
+		r.URL.Path = strings.TrimSuffix(upstream.Path, "/") + "/" + strings.TrimPrefix(r.URL.Path, "/")
+		r.URL.RawPath = ""
+	}
+
+	switch {
+	case upstream.RawQuery == "":
+	case r.URL.RawQuery == "":
+		r.URL.RawQuery = upstream.RawQuery
+	default:
+		r.URL.RawQuery = upstream.RawQuery + "&" + r.URL.RawQuery
+	}
+
+	// the request line is written from RequestURI
+	r.RequestURI = r.URL.RequestURI()
+}
@@ -0,0 +1,156 @@
+package efincore
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func runTestReverseProxy(t *testing.T, upstream string, useTLS bool) (*Proxy, string) {
+	t.Helper()
+
+	upstreamURL, err := url.Parse(upstream)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	proxy := runTestProxy(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	if useTLS {
+		go proxy.ServeReverseTLS(l, upstreamURL, nil)
+	} else {
+		go proxy.ServeReverse(l, upstreamURL)
+	}
+
+	return proxy, l.Addr().String()
+}
+
+func TestReverseProxy_RewritesRequestsToUpstream(t *testing.T) {
+	var gotPath, gotHost, gotForwardedHost string
+	server := newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.RequestURI()
+		gotHost = r.Host
+		gotForwardedHost = r.Header.Get("X-Forwarded-Host")
+		w.Write([]byte("response"))
+	})
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	proxy, addr := runTestReverseProxy(t, server.URL+"/api", false)
+
+	hookCalled := make(chan string, 1)
+	proxy.AddRequestInHook(HookRequestReadFunc(func(r *http.Request, id uuid.UUID) error {
+		hookCalled <- r.URL.String()
+		return nil
+	}))
+
+	resp, err := http.Get("http://" + addr + "/users?page=2")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read response body: %v", err)
+	}
+
+	if string(body) != "response" {
+		t.Errorf("response body: got '%s', expected '%s'", body, "response")
+	}
+
+	if gotPath != "/api/users?page=2" {
+		t.Errorf("upstream path: got '%s', expected '%s'", gotPath, "/api/users?page=2")
+	}
+
+	if gotHost != serverURL.Host {
+		t.Errorf("upstream host: got '%s', expected '%s'", gotHost, serverURL.Host)
+	}
+
+	if gotForwardedHost != addr {
+		t.Errorf("X-Forwarded-Host: got '%s', expected '%s'", gotForwardedHost, addr)
+	}
+
+	select {
+	case u := <-hookCalled:
+		if u != server.URL+"/api/users?page=2" {
+			t.Errorf("hook request URL: got '%s', expected '%s'", u, server.URL+"/api/users?page=2")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("request hook not called")
+	}
+
+	if got := proxy.GetStats()[HostStat(serverURL.Hostname())]; got != 1 {
+		t.Errorf("requests by host: got '%d', expected '%d'", got, 1)
+	}
+}
+
+func TestReverseProxyTLS_ForgesCertificateAndRunsModHooks(t *testing.T) {
+	var gotHeader string
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("X-Hook")
+	})
+
+	proxy, addr := runTestReverseProxy(t, server.URL, true)
+
+	proxy.AddRequestModHook(HookRequestModFunc(func(r *http.Request, id uuid.UUID) error {
+		r.Header.Set("X-Hook", "modified")
+		return nil
+	}))
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+				ServerName:         "staging.example.com",
+			},
+		},
+	}
+
+	resp, err := client.Get("https://" + addr + "/")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("response status code: got '%d', expected '%d'", resp.StatusCode, http.StatusOK)
+	}
+
+	if names := resp.TLS.PeerCertificates[0].DNSNames; len(names) == 0 || names[0] != "staging.example.com" {
+		t.Errorf("certificate names: got '%v', expected '%v'", names, []string{"staging.example.com"})
+	}
+
+	if gotHeader != "modified" {
+		t.Errorf("upstream header: got '%s', expected '%s'", gotHeader, "modified")
+	}
+}
+
+func TestReverseProxy_UnreachableUpstream_Gets502(t *testing.T) {
+	_, addr := runTestReverseProxy(t, "http://127.0.0.1:"+strconv.Itoa(getFreePort(t)), false)
+
+	resp, err := http.Get("http://" + addr + "/")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("response status code: got '%d', expected '%d'", resp.StatusCode, http.StatusBadGateway)
+	}
+}
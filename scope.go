@@ -0,0 +1,318 @@
+package efincore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type ScopeAction int
+
+const (
+	ScopeInclude ScopeAction = iota
+	ScopeExclude
+)
+
+func (a ScopeAction) String() string {
+	switch a {
+	case ScopeInclude:
+		return "include"
+	case ScopeExclude:
+		return "exclude"
+	}
+
+	return "unknown"
+}
+
+func (a ScopeAction) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *ScopeAction) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "include", "":
+		*a = ScopeInclude
+	case "exclude":
+		*a = ScopeExclude
+	default:
+		return fmt.Errorf("invalid scope action '%s'", string(text))
+	}
+
+	return nil
+}
+
+// ScopeRule matches requests by their target. Empty fields match anything.
+// Host accepts exact names, wildcards ("*.example.com") and CIDR ranges
+// for IP targets. Path is a prefix unless it contains glob characters
+// ('*' and '?'), in which case it has to match the whole path.
+type ScopeRule struct {
+	Action ScopeAction `json:"action" yaml:"action"`
+	Scheme string      `json:"scheme,omitempty" yaml:"scheme,omitempty"`
+	Host   string      `json:"host,omitempty" yaml:"host,omitempty"`
+	Port   int         `json:"port,omitempty" yaml:"port,omitempty"`
+	Path   string      `json:"path,omitempty" yaml:"path,omitempty"`
+	Method string      `json:"method,omitempty" yaml:"method,omitempty"`
+}
+
+type compiledScopeRule struct {
+	rule ScopeRule
+
+	hostRe  *regexp.Regexp
+	hostNet *net.IPNet
+	pathRe  *regexp.Regexp
+}
+
+// Scope is an ordered list of include and exclude rules. The first rule
+// matching a request decides whether it is in scope. Requests that do not
+// match any rule are in scope only if the scope has no include rules.
+type Scope struct {
+	rules []compiledScopeRule
+
+	hasIncludes bool
+}
+
+type scopeFile struct {
+	Rules []ScopeRule `json:"rules"`
+}
+
+func NewScope(rules ...ScopeRule) (*Scope, error) {
+	s := &Scope{}
+
+	for i, r := range rules {
+		cr, err := compileScopeRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scope rule %d: %v", i, err)
+		}
+
+		s.rules = append(s.rules, cr)
+		if r.Action == ScopeInclude {
+			s.hasIncludes = true
+		}
+	}
+
+	return s, nil
+}
+
+func ParseScope(r io.Reader) (*Scope, error) {
+	var f scopeFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("could not decode scope: %v", err)
+	}
+
+	return NewScope(f.Rules...)
+}
+
+func LoadScope(path string) (*Scope, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseScope(f)
+}
+
+func (s *Scope) Rules() []ScopeRule {
+	if s == nil {
+		return nil
+	}
+
+	result := make([]ScopeRule, len(s.rules))
+	for i, r := range s.rules {
+		result[i] = r.rule
+	}
+
+	return result
+}
+
+func (s *Scope) InScope(r *http.Request) bool {
+	if s == nil {
+		return true
+	}
+
+	scheme, host, port := requestTarget(r)
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	for _, rule := range s.rules {
+		if rule.matches(scheme, host, port, r.URL.Path, method) {
+			return rule.rule.Action == ScopeInclude
+		}
+	}
+
+	return !s.hasIncludes
+}
+
+func (s *Scope) InScopeURL(method, rawURL string) (bool, error) {
+	r, err := http.NewRequest(method, rawURL, nil)
+	if err != nil {
+		return false, err
+	}
+
+	return s.InScope(r), nil
+}
+
+// ShouldInterceptRequest allows a Scope to be used as a RequestFilter
+func (s *Scope) ShouldInterceptRequest(r *http.Request) bool {
+	return s.InScope(r)
+}
+
+// inScopeHost reports whether any request to the given host could be in
+// scope. It is used to decide if a CONNECT tunnel has to be intercepted,
+// when only the host and port are known.
+func (s *Scope) inScopeHost(scheme, host string, port int) bool {
+	if s == nil {
+		return true
+	}
+
+	for _, rule := range s.rules {
+		if !rule.matchesHost(scheme, host, port) {
+			continue
+		}
+
+		if rule.rule.Action == ScopeInclude {
+			return true
+		}
+
+		// exclude rules that depend on the path or method only exclude
+		// some of the requests, later rules may still include others
+		if rule.rule.Path == "" && rule.rule.Method == "" {
+			return false
+		}
+	}
+
+	return !s.hasIncludes
+}
+
+func compileScopeRule(r ScopeRule) (compiledScopeRule, error) {
+	cr := compiledScopeRule{rule: r}
+
+	if r.Port < 0 || r.Port > 65535 {
+		return cr, fmt.Errorf("invalid port %d", r.Port)
+	}
+
+	if r.Host != "" {
+		if _, ipNet, err := net.ParseCIDR(r.Host); err == nil {
+			cr.hostNet = ipNet
+		} else {
+			re, err := regexp.Compile(`(?i)^` + globToRegex(r.Host) + `$`)
+			if err != nil {
+				return cr, err
+			}
+			cr.hostRe = re
+		}
+	}
+
+	if strings.ContainsAny(r.Path, "*?") {
+		re, err := regexp.Compile(`^` + globToRegex(r.Path) + `$`)
+		if err != nil {
+			return cr, err
+		}
+		cr.pathRe = re
+	}
+
+	return cr, nil
+}
+
+func (cr compiledScopeRule) matches(scheme, host string, port int, path, method string) bool {
+	if !cr.matchesHost(scheme, host, port) {
+		return false
+	}
+
+	if cr.rule.Method != "" && !strings.EqualFold(cr.rule.Method, method) {
+		return false
+	}
+
+	if cr.rule.Path != "" {
+		if path == "" {
+			path = "/"
+		}
+
+		if cr.pathRe != nil {
+			return cr.pathRe.MatchString(path)
+		}
+
+		return strings.HasPrefix(path, cr.rule.Path)
+	}
+
+	return true
+}
+
+func (cr compiledScopeRule) matchesHost(scheme, host string, port int) bool {
+	if cr.rule.Scheme != "" && !strings.EqualFold(cr.rule.Scheme, scheme) {
+		return false
+	}
+
+	if cr.rule.Port != 0 && cr.rule.Port != port {
+		return false
+	}
+
+	if cr.hostNet != nil {
+		ip := net.ParseIP(host)
+		return ip != nil && cr.hostNet.Contains(ip)
+	}
+
+	if cr.hostRe != nil {
+		return cr.hostRe.MatchString(host)
+	}
+
+	return true
+}
+
+// globToRegex converts a glob pattern into a regular expression where
+// '*' matches any sequence, so "*.example.com" matches every subdomain
+// of example.com but not example.com itself.
+func globToRegex(glob string) string {
+	var b strings.Builder
+	for _, c := range glob {
+		switch c {
+		case '*':
+			b.WriteString(`.*`)
+		case '?':
+			b.WriteString(`.`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return b.String()
+}
+
+func requestTarget(r *http.Request) (string, string, int) {
+	scheme := r.URL.Scheme
+	if scheme == "" {
+		scheme = "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+	}
+
+	hostPort := r.URL.Host
+	if hostPort == "" {
+		hostPort = r.Host
+	}
+
+	u := &url.URL{Host: hostPort}
+	return scheme, u.Hostname(), portOrDefault(u.Port(), scheme)
+}
+
+func portOrDefault(port, scheme string) int {
+	if p, err := strconv.Atoi(port); err == nil {
+		return p
+	}
+
+	if strings.EqualFold(scheme, "https") {
+		return 443
+	}
+
+	return 80
+}
@@ -0,0 +1,188 @@
+package efincore
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNilScope_InScope_ReturnsTrue(t *testing.T) {
+	var s *Scope
+
+	got, err := s.InScopeURL(http.MethodGet, "https://www.example.com/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got != true {
+		t.Errorf("expected 'true', got '%t'", got)
+	}
+}
+
+func TestScope_InScopeURL(t *testing.T) {
+	scope, err := NewScope(
+		ScopeRule{Action: ScopeExclude, Host: "*.example.com", Path: "/static/"},
+		ScopeRule{Action: ScopeExclude, Host: "*.example.com", Method: http.MethodDelete},
+		ScopeRule{Action: ScopeInclude, Scheme: "https", Host: "*.example.com"},
+		ScopeRule{Action: ScopeInclude, Host: "api.test.com", Port: 8443, Path: "/v*/users"},
+		ScopeRule{Action: ScopeInclude, Host: "10.0.0.0/8"},
+	)
+	if err != nil {
+		t.Fatalf("could not create scope: %v", err)
+	}
+
+	tests := []struct {
+		method   string
+		url      string
+		expected bool
+	}{
+		{http.MethodGet, "https://www.example.com/", true},
+		{http.MethodGet, "https://a.b.example.com/index.html", true},
+		{http.MethodGet, "https://example.com/", false},
+		{http.MethodGet, "http://www.example.com/", false},
+		{http.MethodGet, "https://www.example.com/static/app.js", false},
+		{http.MethodDelete, "https://www.example.com/", false},
+		{http.MethodGet, "https://api.test.com:8443/v1/users", true},
+		{http.MethodGet, "https://api.test.com:8443/v1/groups", false},
+		{http.MethodGet, "https://api.test.com/v1/users", false},
+		{http.MethodGet, "http://10.1.2.3/", true},
+		{http.MethodGet, "http://11.1.2.3/", false},
+		{http.MethodGet, "https://other.com/", false},
+	}
+
+	for _, tt := range tests {
+		got, err := scope.InScopeURL(tt.method, tt.url)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got != tt.expected {
+			t.Errorf("%s %s: expected '%t', got '%t'", tt.method, tt.url, tt.expected, got)
+		}
+	}
+}
+
+func TestScope_OnlyExcludes_EverythingElseInScope(t *testing.T) {
+	scope, err := NewScope(ScopeRule{Action: ScopeExclude, Host: "tracker.com"})
+	if err != nil {
+		t.Fatalf("could not create scope: %v", err)
+	}
+
+	if got, _ := scope.InScopeURL(http.MethodGet, "https://www.example.com/"); got != true {
+		t.Errorf("expected 'true', got '%t'", got)
+	}
+
+	if got, _ := scope.InScopeURL(http.MethodGet, "https://tracker.com/"); got != false {
+		t.Errorf("expected 'false', got '%t'", got)
+	}
+}
+
+func TestScope_InScopeHost(t *testing.T) {
+	scope, err := NewScope(
+		ScopeRule{Action: ScopeExclude, Host: "www.example.com", Path: "/logout"},
+		ScopeRule{Action: ScopeExclude, Host: "cdn.example.com"},
+		ScopeRule{Action: ScopeInclude, Host: "*.example.com"},
+	)
+	if err != nil {
+		t.Fatalf("could not create scope: %v", err)
+	}
+
+	tests := []struct {
+		host     string
+		expected bool
+	}{
+		{"www.example.com", true},
+		{"cdn.example.com", false},
+		{"other.com", false},
+	}
+
+	for _, tt := range tests {
+		got := scope.inScopeHost("https", tt.host, 443)
+		if got != tt.expected {
+			t.Errorf("%s: expected '%t', got '%t'", tt.host, tt.expected, got)
+		}
+	}
+}
+
+func TestNewScope_InvalidRule_ReturnsError(t *testing.T) {
+	if _, err := NewScope(ScopeRule{Port: 70000}); err == nil {
+		t.Errorf("expected an error for an invalid port")
+	}
+}
+
+func TestLoadScope(t *testing.T) {
+	content := `{
+	"rules": [
+		{"action": "exclude", "host": "*.example.com", "path": "/static/"},
+		{"action": "include", "host": "*.example.com", "method": "GET"}
+	]
+}`
+
+	path := filepath.Join(t.TempDir(), "scope.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("could not write scope file: %v", err)
+	}
+
+	scope, err := LoadScope(path)
+	if err != nil {
+		t.Fatalf("could not load scope: %v", err)
+	}
+
+	rules := scope.Rules()
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d", len(rules))
+	}
+
+	if rules[0].Action != ScopeExclude {
+		t.Errorf("expected first rule to be '%s', got '%s'", ScopeExclude, rules[0].Action)
+	}
+
+	if got, _ := scope.InScopeURL(http.MethodGet, "https://www.example.com/"); got != true {
+		t.Errorf("expected 'true', got '%t'", got)
+	}
+
+	if got, _ := scope.InScopeURL(http.MethodPost, "https://www.example.com/"); got != false {
+		t.Errorf("expected 'false', got '%t'", got)
+	}
+}
+
+func TestParseScope_InvalidAction_ReturnsError(t *testing.T) {
+	_, err := ParseScope(strings.NewReader(`{"rules": [{"action": "maybe"}]}`))
+	if err == nil {
+		t.Errorf("expected an error for an invalid action")
+	}
+}
+
+func TestRequestFilterCombinators(t *testing.T) {
+	yes := RequestFilterFunc(func(*http.Request) bool { return true })
+	no := RequestFilterFunc(func(*http.Request) bool { return false })
+
+	r, err := http.NewRequest(http.MethodGet, "https://www.example.com", nil)
+	if err != nil {
+		t.Fatalf("could not create request: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		filter   RequestFilter
+		expected bool
+	}{
+		{"and", And(yes, yes), true},
+		{"and with false", And(yes, no), false},
+		{"empty and", And(), true},
+		{"or", Or(no, yes), true},
+		{"or all false", Or(no, no), false},
+		{"empty or", Or(), false},
+		{"not", Not(no), true},
+		{"nested", Not(And(yes, Or(no, no))), true},
+	}
+
+	for _, tt := range tests {
+		got := tt.filter.ShouldInterceptRequest(r)
+		if got != tt.expected {
+			t.Errorf("%s: expected '%t', got '%t'", tt.name, tt.expected, got)
+		}
+	}
+}
@@ -25,7 +25,7 @@ func TestRBody(t *testing.T) {
 
 		bytes := make([]byte, 4)
 		if _, err := reader.Read(bytes); err != nil {
-			t.Fatalf("read 1 from reader1 failed: %v", err)
+			t.Errorf("read 1 from reader1 failed: %v", err)
 		}
 
 		client1Got = string(bytes)
@@ -33,7 +33,7 @@ func TestRBody(t *testing.T) {
 
 		<-syncChan
 		if _, err := reader.Read(bytes); err != nil {
-			t.Fatalf("read 2 from reader1 failed: %v", err)
+			t.Errorf("read 2 from reader1 failed: %v", err)
 		}
 		client1RestGot = string(bytes)
 	}()
@@ -45,13 +45,13 @@ func TestRBody(t *testing.T) {
 		<-syncChan
 		bytes := make([]byte, 4)
 		if _, err := reader2.Read(bytes); err != nil {
-			t.Fatalf("read 1 from reader2 failed: %v", err)
+			t.Errorf("read 1 from reader2 failed: %v", err)
 		}
 
 		client2Got = string(bytes)
 
 		if _, err := reader2.Read(bytes); err != nil {
-			t.Fatalf("read 2 from reader2 failed: %v", err)
+			t.Errorf("read 2 from reader2 failed: %v", err)
 		}
 
 		client2RestGot = string(bytes)
@@ -0,0 +1,131 @@
+package efincore
+
+import (
+	"bufio"
+	"crypto/tls"
+	"net"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// httpALPNProtocols are the application protocols offered by HTTP clients
+var httpALPNProtocols = []string{"http/1.1", "http/1.0", "h2"}
+
+// clientTLSConn is the TLS connection of the proxy with a client. It keeps
+// the application protocols offered by the client, which tell if the
+// tunnel carries HTTP
+type clientTLSConn struct {
+	*tls.Conn
+	offeredProtos []string
+}
+
+func newClientTLSConn(conn net.Conn, cert tls.Certificate) *clientTLSConn {
+	c := &clientTLSConn{}
+	c.Conn = tls.Server(conn, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			c.offeredProtos = hello.SupportedProtos
+			return nil, nil
+		},
+	})
+
+	return c
+}
+
+// carriesHTTP reports whether the client sends HTTP through the tunnel. If
+// the client did not offer any application protocol, the first bytes it
+// sends are checked. Clients that send nothing within clientPeekTimeout
+// are inspected, as slow HTTP clients would otherwise be passed through
+func (c *clientTLSConn) carriesHTTP(r *bufio.Reader) bool {
+	if len(c.offeredProtos) > 0 {
+		return slices.ContainsFunc(c.offeredProtos, func(p string) bool {
+			return slices.Contains(httpALPNProtocols, p)
+		})
+	}
+
+	c.SetReadDeadline(time.Now().Add(clientPeekTimeout))
+	first, err := r.Peek(1)
+	c.SetReadDeadline(time.Time{})
+
+	if isTimeout(err) {
+		return true
+	}
+
+	return err == nil && first[0] >= 'A' && first[0] <= 'Z'
+}
+
+// inspectClientTLS completes the TLS handshake with the client and
+// inspects its requests. Tunnels that do not carry HTTP are passed
+// through. Once the clients of a host reject the certificate as many times
+// as the passthrough threshold, the host is passed through in the
+// following connections. Other handshake errors, such as timeouts and
+// resets, are not counted
+func (m *mitm) inspectClientTLS(srcConn *clientTLSConn, destConn net.Conn, connectURL *url.URL, logger *eventLogger) {
+	if err := srcConn.Handshake(); err != nil {
+		srcConn.Close()
+		destConn.Close()
+
+		if !isCertificateRejection(err) {
+			m.stats.Increase(ErrorStat(ErrorKindClient))
+			logger.Debug("TLS handshake with client failed", errAttr(err))
+			return
+		}
+
+		m.stats.Increase(StatCertificateRejections)
+		logger.Info("client rejected the certificate", errAttr(err))
+
+		if m.passthroughHosts.recordFailure(connectURL.Hostname(), err) {
+			m.stats.Increase(StatPassthroughHosts)
+			logger.Warn("too many certificate rejections, the host will be passed through", errAttr(err))
+		}
+		return
+	}
+
+	reader := bufio.NewReader(srcConn)
+	if !srcConn.carriesHTTP(reader) {
+		m.stats.Increase(StatNonHTTPTunnels)
+		logger.Debug("passing through tunnel without HTTP", "protocols", srcConn.offeredProtos)
+		m.streamTunnel(newBufferedConn(srcConn, reader), destConn, connectURL.Host)
+		return
+	}
+
+	m.inspectTunnel(newBufferedConn(srcConn, reader), destConn, connectURL, nil)
+}
+
+// streamTunnel passes a tunnel through, sending its data to the stream
+// hooks
+func (m *mitm) streamTunnel(srcConn, destConn net.Conn, host string) {
+	m.hooksMutex.Lock()
+	hooks := m.hooks
+	m.hooksMutex.Unlock()
+
+	connID := uuid.New()
+	m.spliceTunnel(
+		connID,
+		&streamHookConn{Conn: srcConn, hooks: hooks, chunk: StreamChunk{ConnectionID: connID, Host: host, Direction: StreamToUpstream}},
+		&streamHookConn{Conn: destConn, hooks: hooks, chunk: StreamChunk{ConnectionID: connID, Host: host, Direction: StreamToClient}},
+		host,
+	)
+}
+
+// streamHookConn runs the stream hooks with the data read from a
+// connection
+type streamHookConn struct {
+	net.Conn
+	hooks *hooks
+	chunk StreamChunk
+}
+
+func (c *streamHookConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		chunk := c.chunk
+		chunk.Data = p[:n]
+		c.hooks.RunStreamHooks(&chunk)
+	}
+
+	return n, err
+}
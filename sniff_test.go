@@ -0,0 +1,304 @@
+package efincore
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// runTestTLSEchoServer runs a TLS server, with a certificate for
+// example.com, that writes back everything it receives
+func runTestTLSEchoServer(t *testing.T) string {
+	t.Helper()
+
+	cert, err := NewCA().GetCertificateFor("example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func testTLSTunnel(t *testing.T, proxy *Proxy, target string, config *tls.Config) (*tls.Conn, error) {
+	t.Helper()
+
+	resp, conn := sendTestConnect(t, proxy, target, nil)
+	resp.Body.Close()
+	t.Cleanup(func() { conn.Close() })
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+func TestNonHTTPTunnel_IsPassedThroughToStreamHooks(t *testing.T) {
+	tests := []struct {
+		name      string
+		protocols []string
+		data      string
+	}{
+		{name: "alpn", protocols: []string{"mqtt"}, data: "ping"},
+		{name: "sniffed", data: "\x10\x00ping"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := runTestProxy(t)
+
+			chunks := make(chan *StreamChunk, 10)
+			proxy.AddStreamHook(HookStreamReadFunc(func(c *StreamChunk) error {
+				chunks <- c
+				return nil
+			}))
+
+			tlsConn, err := testTLSTunnel(t, proxy, runTestTLSEchoServer(t), &tls.Config{
+				InsecureSkipVerify: true,
+				NextProtos:         tt.protocols,
+			})
+			if err != nil {
+				t.Fatalf("TLS handshake failed: %v", err)
+			}
+
+			if _, err := tlsConn.Write([]byte(tt.data)); err != nil {
+				t.Fatalf("could not write to tunnel: %v", err)
+			}
+
+			got := make([]byte, len(tt.data))
+			if _, err := io.ReadFull(tlsConn, got); err != nil {
+				t.Fatalf("could not read from tunnel: %v", err)
+			}
+
+			if string(got) != tt.data {
+				t.Errorf("tunnel data: got '%q', expected '%q'", got, tt.data)
+			}
+
+			select {
+			case c := <-chunks:
+				if c.Direction != StreamToUpstream || !bytes.Equal(c.Data, []byte(tt.data)) {
+					t.Errorf("stream chunk: got '%s' '%q', expected '%s' '%q'", c.Direction, c.Data, StreamToUpstream, tt.data)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("stream hook not called")
+			}
+
+			if got := proxy.GetStats()[StatNonHTTPTunnels]; got != 1 {
+				t.Errorf("non HTTP tunnels: got '%d', expected '%d'", got, 1)
+			}
+		})
+	}
+}
+
+func TestCertificateRejection_HostIsPassedThrough(t *testing.T) {
+	proxy := runTestProxy(t)
+	proxy.SetPassthroughThreshold(1)
+	target := runTestTLSEchoServer(t)
+
+	// the client does not trust the CA of the proxy
+	_, err := testTLSTunnel(t, proxy, target, &tls.Config{ServerName: "127.0.0.1"})
+	if err == nil {
+		t.Fatalf("TLS handshake with untrusted certificate succeeded")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for proxy.GetStats()[StatCertificateRejections] != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("certificate rejection not detected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the next connection gets the certificate of the server
+	tlsConn, err := testTLSTunnel(t, proxy, target, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("TLS handshake failed: %v", err)
+	}
+
+	names := tlsConn.ConnectionState().PeerCertificates[0].DNSNames
+	if len(names) == 0 || names[0] != "example.com" {
+		t.Errorf("certificate names: got '%v', expected '%v'", names, []string{"example.com"})
+	}
+}
+
+func TestHandshakeFailures_HostIsPassedThroughAfterThreshold(t *testing.T) {
+	proxy := runTestProxy(t)
+	proxy.SetPassthroughThreshold(2)
+	target := runTestTLSEchoServer(t)
+
+	for i := 1; i <= 2; i++ {
+		if got := proxy.PassthroughHosts(); len(got) > 0 && got[0].PassedThrough {
+			t.Fatalf("host passed through after %d failures", i-1)
+		}
+
+		if _, err := testTLSTunnel(t, proxy, target, &tls.Config{ServerName: "127.0.0.1"}); err == nil {
+			t.Fatalf("TLS handshake with untrusted certificate succeeded")
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for proxy.GetStats()[StatCertificateRejections] != i {
+			if time.Now().After(deadline) {
+				t.Fatalf("certificate rejection not detected")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	hosts := proxy.PassthroughHosts()
+	if len(hosts) != 1 || hosts[0].Host != "127.0.0.1" || hosts[0].Failures != 2 || !hosts[0].PassedThrough {
+		t.Fatalf("passthrough hosts: got '%+v', expected host '%s' passed through after %d failures", hosts, "127.0.0.1", 2)
+	}
+
+	if got := proxy.GetStats()[StatPassthroughHosts]; got != 1 {
+		t.Errorf("passthrough hosts stat: got '%d', expected '%d'", got, 1)
+	}
+
+	tlsConn, err := testTLSTunnel(t, proxy, target, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("TLS handshake failed: %v", err)
+	}
+
+	if names := tlsConn.ConnectionState().PeerCertificates[0].DNSNames; len(names) == 0 || names[0] != "example.com" {
+		t.Errorf("certificate names: got '%v', expected '%v'", names, []string{"example.com"})
+	}
+
+	proxy.ResetPassthroughHost("127.0.0.1")
+	if got := proxy.PassthroughHosts(); len(got) != 0 {
+		t.Errorf("passthrough hosts after reset: got '%+v', expected none", got)
+	}
+
+	if got := proxy.GetStats()[StatPassthroughHosts]; got != 0 {
+		t.Errorf("passthrough hosts stat after reset: got '%d', expected '%d'", got, 0)
+	}
+}
+
+func TestPinnedHosts_ArePassedThrough(t *testing.T) {
+	proxy := runTestProxy(t)
+	if err := proxy.SetPinnedHosts([]string{"127.0.0.0/8"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tlsConn, err := testTLSTunnel(t, proxy, runTestTLSEchoServer(t), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("TLS handshake failed: %v", err)
+	}
+
+	if names := tlsConn.ConnectionState().PeerCertificates[0].DNSNames; len(names) == 0 || names[0] != "example.com" {
+		t.Errorf("certificate names: got '%v', expected '%v'", names, []string{"example.com"})
+	}
+}
+
+// closeAfterWriteConn closes the connection once the first write, the
+// ClientHello of a TLS handshake, is sent
+type closeAfterWriteConn struct {
+	net.Conn
+}
+
+func (c closeAfterWriteConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.Conn.Close()
+	return n, err
+}
+
+func TestAbortedHandshakes_AreNotCountedAsRejections(t *testing.T) {
+	proxy := runTestProxy(t)
+	proxy.SetPassthroughThreshold(1)
+	target := runTestTLSEchoServer(t)
+
+	for range 3 {
+		resp, conn := sendTestConnect(t, proxy, target, nil)
+		resp.Body.Close()
+
+		tls.Client(closeAfterWriteConn{conn}, &tls.Config{InsecureSkipVerify: true}).Handshake()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for proxy.GetStats()[ErrorStat(ErrorKindClient)] != 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("failed handshakes not detected: got '%d', expected '%d'", proxy.GetStats()[ErrorStat(ErrorKindClient)], 3)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := proxy.PassthroughHosts(); len(got) != 0 {
+		t.Errorf("passthrough hosts: got '%+v', expected none", got)
+	}
+}
+
+func TestSlowClientWithoutALPN_IsInspected(t *testing.T) {
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("response"))
+	})
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	proxy := runTestProxy(t)
+
+	requests := make(chan string, 1)
+	proxy.AddRequestInHook(HookRequestReadFunc(func(r *http.Request, id uuid.UUID) error {
+		requests <- r.URL.Path
+		return nil
+	}))
+
+	tlsConn, err := testTLSTunnel(t, proxy, serverURL.Host, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("TLS handshake failed: %v", err)
+	}
+
+	// the first request is sent after the protocol detection gave up
+	time.Sleep(clientPeekTimeout + 200*time.Millisecond)
+
+	if _, err := tlsConn.Write([]byte("GET /slow HTTP/1.1\r\nHost: " + serverURL.Host + "\r\n\r\n")); err != nil {
+		t.Fatalf("could not write to tunnel: %v", err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(tlsConn), nil)
+	if err != nil {
+		t.Fatalf("could not read response: %v", err)
+	}
+	resp.Body.Close()
+
+	select {
+	case path := <-requests:
+		if path != "/slow" {
+			t.Errorf("inspected request path: got '%s', expected '%s'", path, "/slow")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("request not inspected")
+	}
+
+	if got := proxy.GetStats()[StatNonHTTPTunnels]; got != 0 {
+		t.Errorf("non HTTP tunnels: got '%d', expected '%d'", got, 0)
+	}
+}
@@ -0,0 +1,404 @@
+package efincore
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// socksHandshakeTimeout limits the time a client has to send the
+	// SOCKS request
+	socksHandshakeTimeout = 10 * time.Second
+)
+
+const (
+	socks4Version = 0x04
+
+	socks4Granted  = 0x5a
+	socks4Rejected = 0x5b
+
+	socks5AuthNoAcceptable = 0xff
+
+	socks5Succeeded           = 0x00
+	socks5GeneralFailure      = 0x01
+	socks5NotAllowed          = 0x02
+	socks5HostUnreachable     = 0x04
+	socks5ConnectionRefused   = 0x05
+	socks5TTLExpired          = 0x06
+	socks5CommandNotSupported = 0x07
+	socks5AddrNotSupported    = 0x08
+)
+
+var errSOCKSAuth = errors.New("SOCKS authentication failed")
+
+// socksRequest is the CONNECT request of a SOCKS4, SOCKS4a or SOCKS5
+// client
+type socksRequest struct {
+	version byte
+	host    string
+	port    int
+}
+
+func (r socksRequest) addr() string {
+	return net.JoinHostPort(r.host, strconv.Itoa(r.port))
+}
+
+// reply answers the request. code is one of the SOCKS5 replies, it is
+// translated for SOCKS4 clients
+func (r socksRequest) reply(w io.Writer, code byte) error {
+	if r.version == socks4Version {
+		status := byte(socks4Granted)
+		if code != socks5Succeeded {
+			status = socks4Rejected
+		}
+
+		_, err := w.Write([]byte{0x00, status, 0, 0, 0, 0, 0, 0})
+		return err
+	}
+
+	// the bound address is not meaningful for the client
+	_, err := w.Write([]byte{socks5Version, code, 0x00, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
+
+func socksReplyCode(kind ErrorKind) byte {
+	switch kind {
+	case ErrorKindDNS:
+		return socks5HostUnreachable
+	case ErrorKindConnectionRefused:
+		return socks5ConnectionRefused
+	case ErrorKindTimeout:
+		return socks5TTLExpired
+	}
+
+	return socks5GeneralFailure
+}
+
+// ServeSOCKS accepts SOCKS4, SOCKS4a and SOCKS5 connections. TLS tunnels
+// go through the same interception pipeline as CONNECT requests, other
+// protocols are passed through
+func (m *mitm) ServeSOCKS(l net.Listener) error {
+	l = m.limitListener(l)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		go m.serveSOCKSConn(conn)
+	}
+}
+
+func (m *mitm) serveSOCKSConn(conn net.Conn) {
+	m.stats.Increase(StatActiveConnections)
+	defer m.stats.Decrease(StatActiveConnections)
+
+	defer conn.Close()
+
+	logger := m.logger.with(slog.String("client", conn.RemoteAddr().String()))
+
+	conn.SetDeadline(time.Now().Add(socksHandshakeTimeout))
+	reader := bufio.NewReader(conn)
+
+	req, err := m.socksHandshake(conn, reader)
+	if err != nil {
+		if !errors.Is(err, errSOCKSAuth) {
+			logger.Warn("invalid SOCKS request", errAttr(err))
+		}
+		return
+	}
+	logger = logger.with(hostAttr(req.addr()))
+
+	destConn, err := m.dial(context.Background(), req.addr())
+	if err != nil {
+		proxyErr := newProxyError(req.host, fmt.Errorf("could not connect to destination: %w", err))
+		m.reportError(logger, "could not connect to destination", proxyErr)
+		req.reply(conn, socksReplyCode(proxyErr.Kind))
+		return
+	}
+	defer destConn.Close()
+
+	if err := req.reply(conn, socks5Succeeded); err != nil {
+		m.stats.Increase(ErrorStat(ErrorKindClient))
+		logger.Warn("could not answer SOCKS request", errAttr(err))
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	m.stats.Increase(StatActiveConnectRequests)
+	defer m.stats.Decrease(StatActiveConnectRequests)
+
+	if !isTLSClientHello(conn, reader) {
+		m.passthroughTunnel(newBufferedConn(conn, reader), destConn, req.addr())
+		return
+	}
+
+	hello, srcConn, err := peekClientHello(conn, reader)
+	if err != nil {
+		logger.Debug("could not parse ClientHello", errAttr(err))
+		m.passthroughTunnel(srcConn, destConn, req.addr())
+		return
+	}
+
+	// clients connecting to IP addresses still send the name of the host
+	serverName := req.host
+	if hello.ServerName != "" {
+		serverName = hello.ServerName
+	}
+
+	if !m.shouldInterceptHost("https", serverName, req.port) || m.passthroughHosts.contains(serverName) {
+		m.passthroughTunnel(srcConn, destConn, req.addr())
+		return
+	}
+
+	m.inspectTLS(srcConn, destConn, serverName, req.port, logger)
+}
+
+// inspectTLS terminates TLS with both the client and the destination of a
+// tunnel that was already established, and inspects its requests
+func (m *mitm) inspectTLS(srcConn, destConn net.Conn, serverName string, port int, logger *eventLogger) {
+	// force http/1.1 requests
+	destTLSConn := tls.Client(destConn, &tls.Config{
+		NextProtos:         []string{"http/1.1"},
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	})
+	if err := destTLSConn.Handshake(); err != nil {
+		proxyErr := newProxyError(serverName, fmt.Errorf("could not connect to destination: %w", err))
+		m.reportError(logger, "could not connect to destination", proxyErr)
+		return
+	}
+
+	cert, err := m.ca.GetCertificateFor(serverName)
+	if err != nil {
+		m.stats.Increase(ErrorStat(ErrorKindCertificate))
+		logger.Error("could not create certificate", errAttr(err))
+		return
+	}
+
+	connectURL := &url.URL{Host: net.JoinHostPort(serverName, strconv.Itoa(port))}
+	m.inspectClientTLS(newClientTLSConn(srcConn, cert), destTLSConn, connectURL, logger)
+}
+
+func (m *mitm) socksHandshake(conn net.Conn, r *bufio.Reader) (socksRequest, error) {
+	version, err := r.ReadByte()
+	if err != nil {
+		return socksRequest{}, err
+	}
+
+	switch version {
+	case socks5Version:
+		return m.socks5Handshake(conn, r)
+	case socks4Version:
+		return m.socks4Handshake(conn, r)
+	}
+
+	return socksRequest{}, fmt.Errorf("unsupported SOCKS version %d", version)
+}
+
+func (m *mitm) socks5Handshake(conn net.Conn, r *bufio.Reader) (socksRequest, error) {
+	req := socksRequest{version: socks5Version}
+
+	n, err := r.ReadByte()
+	if err != nil {
+		return req, err
+	}
+
+	methods := make([]byte, n)
+	if _, err := io.ReadFull(r, methods); err != nil {
+		return req, err
+	}
+
+	checker := m.credentialChecker.Load()
+
+	method := byte(socks5AuthNone)
+	if checker != nil {
+		method = socks5AuthPassword
+	}
+
+	accepted := false
+	for _, c := range methods {
+		accepted = accepted || c == method
+	}
+
+	if !accepted {
+		if checker != nil {
+			m.stats.Increase(StatProxyAuthFailures)
+			m.logger.Warn("proxy authentication failed", slog.String("client", conn.RemoteAddr().String()))
+			conn.Write([]byte{socks5Version, socks5AuthNoAcceptable})
+			return req, errSOCKSAuth
+		}
+
+		conn.Write([]byte{socks5Version, socks5AuthNoAcceptable})
+		return req, errors.New("no acceptable SOCKS authentication method")
+	}
+
+	if _, err := conn.Write([]byte{socks5Version, method}); err != nil {
+		return req, err
+	}
+
+	if checker != nil {
+		user, password, err := readSOCKS5Credentials(r)
+		if err != nil {
+			return req, err
+		}
+
+		if !(*checker).CheckCredentials(user, password) {
+			m.stats.Increase(StatProxyAuthFailures)
+			m.logger.Warn("proxy authentication failed", slog.String("client", conn.RemoteAddr().String()), slog.String("user", user))
+			conn.Write([]byte{0x01, 0x01})
+			return req, errSOCKSAuth
+		}
+
+		if _, err := conn.Write([]byte{0x01, 0x00}); err != nil {
+			return req, err
+		}
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return req, err
+	}
+
+	if header[1] != socks5CmdConnect {
+		req.reply(conn, socks5CommandNotSupported)
+		return req, fmt.Errorf("unsupported SOCKS command %d", header[1])
+	}
+
+	switch header[3] {
+	case socks5AddrIPv4, socks5AddrIPv6:
+		ip := make([]byte, net.IPv4len)
+		if header[3] == socks5AddrIPv6 {
+			ip = make([]byte, net.IPv6len)
+		}
+
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return req, err
+		}
+		req.host = net.IP(ip).String()
+	case socks5AddrDomain:
+		l, err := r.ReadByte()
+		if err != nil {
+			return req, err
+		}
+
+		host := make([]byte, l)
+		if _, err := io.ReadFull(r, host); err != nil {
+			return req, err
+		}
+		req.host = string(host)
+	default:
+		req.reply(conn, socks5AddrNotSupported)
+		return req, fmt.Errorf("unsupported SOCKS address type %d", header[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(r, port); err != nil {
+		return req, err
+	}
+	req.port = int(binary.BigEndian.Uint16(port))
+
+	return req, nil
+}
+
+func readSOCKS5Credentials(r *bufio.Reader) (string, string, error) {
+	if _, err := r.ReadByte(); err != nil {
+		return "", "", err
+	}
+
+	fields := make([]string, 2)
+	for i := range fields {
+		l, err := r.ReadByte()
+		if err != nil {
+			return "", "", err
+		}
+
+		field := make([]byte, l)
+		if _, err := io.ReadFull(r, field); err != nil {
+			return "", "", err
+		}
+		fields[i] = string(field)
+	}
+
+	return fields[0], fields[1], nil
+}
+
+// socks4Handshake reads a SOCKS4 or SOCKS4a request. SOCKS4 has no
+// passwords, so it is rejected when the proxy requires authentication
+func (m *mitm) socks4Handshake(conn net.Conn, r *bufio.Reader) (socksRequest, error) {
+	req := socksRequest{version: socks4Version}
+
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return req, err
+	}
+
+	// the user id is ignored
+	if _, err := readSOCKS4String(r); err != nil {
+		req.reply(conn, socks5GeneralFailure)
+		return req, err
+	}
+
+	if header[0] != socks5CmdConnect {
+		req.reply(conn, socks5CommandNotSupported)
+		return req, fmt.Errorf("unsupported SOCKS command %d", header[0])
+	}
+
+	req.port = int(binary.BigEndian.Uint16(header[1:3]))
+	ip := net.IP(header[3:7])
+
+	// SOCKS4a: an address 0.0.0.x is followed by the host name
+	if ip[0] == 0 && ip[1] == 0 && ip[2] == 0 && ip[3] != 0 {
+		host, err := readSOCKS4String(r)
+		if err != nil {
+			req.reply(conn, socks5GeneralFailure)
+			return req, err
+		}
+		req.host = host
+	} else {
+		req.host = ip.String()
+	}
+
+	if m.credentialChecker.Load() != nil {
+		m.stats.Increase(StatProxyAuthFailures)
+		m.logger.Warn("proxy authentication failed", slog.String("client", conn.RemoteAddr().String()), hostAttr(req.addr()))
+		req.reply(conn, socks5NotAllowed)
+		return req, errSOCKSAuth
+	}
+
+	return req, nil
+}
+
+// maxSOCKS4StringLength limits the user id and the host name of SOCKS4
+// requests, as the length of the host names of SOCKS5
+const maxSOCKS4StringLength = 255
+
+// readSOCKS4String reads a null terminated string of a SOCKS4 request
+func readSOCKS4String(r *bufio.Reader) (string, error) {
+	b := make([]byte, 0, 32)
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+
+		if c == 0x00 {
+			return string(b), nil
+		}
+
+		if len(b) == maxSOCKS4StringLength {
+			return "", fmt.Errorf("SOCKS4 field longer than %d bytes", maxSOCKS4StringLength)
+		}
+		b = append(b, c)
+	}
+}
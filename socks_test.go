@@ -0,0 +1,244 @@
+package efincore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func runTestSOCKSProxy(t *testing.T) (*Proxy, string) {
+	t.Helper()
+
+	proxy := runTestProxy(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go proxy.ServeSOCKS(l)
+
+	return proxy, l.Addr().String()
+}
+
+// runTestEchoServer runs a TCP server that writes back everything it
+// receives
+func runTestEchoServer(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func TestSOCKS5_HTTPSRequestIsIntercepted(t *testing.T) {
+	proxy, socksAddr := runTestSOCKSProxy(t)
+
+	hookCalled := make(chan string, 1)
+	proxy.AddRequestInHook(HookRequestReadFunc(func(r *http.Request, id uuid.UUID) error {
+		hookCalled <- r.URL.Path
+		return nil
+	}))
+
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("response"))
+	})
+
+	client := newTestClientProxy(t, "socks5://"+socksAddr)
+
+	resp, err := client.Get(server.URL + "/path")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read response body: %v", err)
+	}
+
+	if string(body) != "response" {
+		t.Errorf("response body: got '%s', expected '%s'", body, "response")
+	}
+
+	select {
+	case path := <-hookCalled:
+		if path != "/path" {
+			t.Errorf("hook request path: got '%s', expected '%s'", path, "/path")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("request hook not called")
+	}
+}
+
+func TestSOCKS4a_PlainTCPIsPassedThrough(t *testing.T) {
+	proxy, socksAddr := runTestSOCKSProxy(t)
+
+	hookCalled := false
+	proxy.AddRequestInHook(HookRequestReadFunc(func(r *http.Request, id uuid.UUID) error {
+		hookCalled = true
+		return nil
+	}))
+
+	_, portStr, err := net.SplitHostPort(runTestEchoServer(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	port, _ := strconv.Atoi(portStr)
+
+	conn, err := net.Dial("tcp", socksAddr)
+	if err != nil {
+		t.Fatalf("could not connect to proxy: %v", err)
+	}
+	defer conn.Close()
+
+	// SOCKS4a request with the host name after the user id
+	req := []byte{0x04, 0x01}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	req = append(req, 0, 0, 0, 1)
+	req = append(req, "user\x00localhost\x00"...)
+	if _, err := conn.Write(req); err != nil {
+		t.Fatalf("could not send SOCKS request: %v", err)
+	}
+
+	reply := make([]byte, 8)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		t.Fatalf("could not read SOCKS reply: %v", err)
+	}
+
+	if reply[1] != socks4Granted {
+		t.Fatalf("SOCKS reply: got '%d', expected '%d'", reply[1], socks4Granted)
+	}
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("could not write to tunnel: %v", err)
+	}
+
+	got := make([]byte, 4)
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatalf("could not read from tunnel: %v", err)
+	}
+
+	if !bytes.Equal(got, []byte("ping")) {
+		t.Errorf("tunnel data: got '%s', expected '%s'", got, "ping")
+	}
+
+	if hookCalled {
+		t.Errorf("hook called for plain TCP tunnel")
+	}
+}
+
+func TestSOCKS5_Credentials(t *testing.T) {
+	proxy, socksAddr := runTestSOCKSProxy(t)
+	proxy.SetCredentialChecker(StaticCredentials("user", "secret"))
+
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	proxyURL := &url.URL{Scheme: "socks5", Host: socksAddr, User: url.UserPassword("user", "secret")}
+	resp, err := newTestClientProxy(t, proxyURL.String()).Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("response status code: got '%d', expected '%d'", resp.StatusCode, http.StatusOK)
+	}
+
+	for _, u := range []string{"socks5://" + socksAddr, "socks5://user:wrong@" + socksAddr} {
+		if _, err := newTestClientProxy(t, u).Get(server.URL); err == nil {
+			t.Errorf("request through '%s' succeeded without valid credentials", u)
+		}
+	}
+
+	if got := proxy.GetStats()[StatProxyAuthFailures]; got != 2 {
+		t.Errorf("auth failures: got '%d', expected '%d'", got, 2)
+	}
+}
+
+func TestSOCKS5_ConnectionRefused_GetsRefusedReply(t *testing.T) {
+	_, socksAddr := runTestSOCKSProxy(t)
+
+	conn, err := net.Dial("tcp", socksAddr)
+	if err != nil {
+		t.Fatalf("could not connect to proxy: %v", err)
+	}
+	defer conn.Close()
+
+	req := []byte{0x05, 0x01, 0x00, 0x05, 0x01, 0x00, 0x01, 127, 0, 0, 1}
+	req = binary.BigEndian.AppendUint16(req, uint16(getFreePort(t)))
+	if _, err := conn.Write(req); err != nil {
+		t.Fatalf("could not send SOCKS request: %v", err)
+	}
+
+	reply := make([]byte, 12)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		t.Fatalf("could not read SOCKS reply: %v", err)
+	}
+
+	if reply[3] != socks5ConnectionRefused {
+		t.Errorf("SOCKS reply: got '%d', expected '%d'", reply[3], socks5ConnectionRefused)
+	}
+}
+
+func TestSOCKS4_LongFieldsAreRejected(t *testing.T) {
+	_, socksAddr := runTestSOCKSProxy(t)
+
+	long := strings.Repeat("a", maxSOCKS4StringLength+1)
+	tests := map[string]string{
+		"user id":   long + "\x00",
+		"host name": "user\x00" + long + "\x00",
+	}
+
+	for name, fields := range tests {
+		conn, err := net.Dial("tcp", socksAddr)
+		if err != nil {
+			t.Fatalf("could not connect to proxy: %v", err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+		req := []byte{0x04, 0x01, 0x00, 0x50, 0, 0, 0, 1}
+		req = append(req, fields...)
+		if _, err := conn.Write(req); err != nil {
+			t.Fatalf("could not send SOCKS request: %v", err)
+		}
+
+		reply := make([]byte, 8)
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			t.Fatalf("could not read SOCKS reply with a long %s: %v", name, err)
+		}
+
+		if reply[1] != socks4Rejected {
+			t.Errorf("SOCKS reply with a long %s: got '%d', expected '%d'", name, reply[1], socks4Rejected)
+		}
+	}
+}
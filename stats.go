@@ -1,6 +1,11 @@
 package efincore
 
-import "sync"
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
 
 const (
 	StatActiveConnections      string = "active-connections"
@@ -9,75 +14,261 @@ const (
 	StatInterceptedRequests    string = "intercepted-requests"
 	StatInterceptedResponses   string = "intercepted-responses"
 	StatUpgradedRequests       string = "upgraded-requests"
+	StatHookFailures           string = "hook-failures"
+	StatHookTimeouts           string = "hook-timeouts"
+	StatHookPanics             string = "hook-panics"
+	StatDisabledHooks          string = "disabled-hooks"
+	StatDroppedHookEvents      string = "dropped-hook-events"
+	StatBytesToUpstream        string = "bytes-to-upstream"
+	StatBytesToClient          string = "bytes-to-client"
+	StatProxyAuthFailures      string = "proxy-auth-failures"
+	StatCertificateRejections  string = "certificate-rejections"
+	StatNonHTTPTunnels         string = "non-http-tunnels"
+	StatPassthroughHosts       string = "passthrough-hosts"
+	StatPassthroughConnections string = "passthrough-connections"
+
+	StatIdleUpstreamConnections   string = "idle-upstream-connections"
+	StatReusedUpstreamConnections string = "reused-upstream-connections"
+
+	StatClientTimeouts      string = "client-timeouts"
+	StatRejectedConnections string = "rejected-connections"
+	StatOversizedHeaders    string = "oversized-headers"
+
+	StatMappedRequests string = "mapped-requests"
+	StatInjectedFaults string = "injected-faults"
+
+	// labeled stats, see LabeledStat
+	StatRequestsByHost    string = "requests-by-host"
+	StatResponsesByStatus string = "responses-by-status"
+	StatErrorsByKind      string = "errors-by-kind"
+
+	// computed when they are read, see MetricsSource
+	StatCACacheSize     string = "ca-cache-size"
+	StatGRPCSubscribers string = "grpc-subscribers"
 )
 
+const (
+	HistogramUpstreamLatency string = "upstream-latency"
+	HistogramHooksLatency    string = "hooks-latency"
+	HistogramTotalLatency    string = "total-latency"
+
+	// labeled by hook name, see LabeledStat
+	HistogramHookLatency string = "hook-latency"
+)
+
+// DefaultHistogramBuckets are the upper bounds, in seconds, of the buckets
+// of the latency histograms
+var DefaultHistogramBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
 type StatsService interface {
 	Increase(stat string)
 	Decrease(stat string)
+	Add(stat string, delta int)
 	Set(stat string, value int)
+	Observe(histogram string, d time.Duration)
 	Get() Stats
+	GetHistograms() map[string]Histogram
 	Reset()
 }
 
 type Stats map[string]int
 
+// Histogram holds the distribution of a latency. Counts[i] is the number of
+// observations in the bucket with upper bound Buckets[i]; the last element
+// of Counts holds the observations above the last bound
+type Histogram struct {
+	Buckets []float64
+	Counts  []uint64
+	Count   uint64
+	Sum     float64
+}
+
 type defaultStatsService struct {
+	mutex      *sync.Mutex
+	stats      Stats
+	histograms map[string]*Histogram
+}
+
+// discardStats is used where no stats service was provided, all the
+// methods of a nil *defaultStatsService are no-ops
+var discardStats StatsService = (*defaultStatsService)(nil)
+
+// LabeledStat returns the name of the stat or histogram with the given
+// label, for example the counter of requests of a single host
+func LabeledStat(stat, label string) string {
+	return stat + "/" + label
+}
+
+// SplitLabeledStat returns the name and the label of a stat created with
+// LabeledStat
+func SplitLabeledStat(stat string) (string, string, bool) {
+	return strings.Cut(stat, "/")
+}
+
+func StatusStat(statusCode int) string {
+	return LabeledStat(StatResponsesByStatus, strconv.Itoa(statusCode))
+}
+
+func HostStat(host string) string {
+	return LabeledStat(StatRequestsByHost, host)
+}
+
+const (
+	// DefaultMaxHostStats is the number of hosts whose requests are counted
+	// separately by a proxy, see Proxy.SetMaxHostStats
+	DefaultMaxHostStats = 1000
+
+	// OtherHostsLabel is the host label of the requests to the hosts that
+	// are not counted separately. It is not a valid host name
+	OtherHostsLabel = "_other"
+)
+
+// hostStats bounds the number of host labels of StatRequestsByHost, which
+// would otherwise grow with every host seen by the proxy
+type hostStats struct {
 	mutex *sync.Mutex
-	stats Stats
+	hosts map[string]struct{}
+	max   int
+}
+
+func newHostStats(max int) *hostStats {
+	return &hostStats{
+		mutex: &sync.Mutex{},
+		hosts: map[string]struct{}{},
+		max:   max,
+	}
+}
+
+// stat returns the stat counting the requests to host. The first max hosts
+// get their own label, the rest share OtherHostsLabel
+func (h *hostStats) stat(host string) string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if _, ok := h.hosts[host]; !ok {
+		if len(h.hosts) >= h.max {
+			return HostStat(OtherHostsLabel)
+		}
+		h.hosts[host] = struct{}{}
+	}
+
+	return HostStat(host)
+}
+
+func (h *hostStats) setMax(max int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.max = max
+}
+
+// reset lets the next hosts get their own label again
+func (h *hostStats) reset() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.hosts = map[string]struct{}{}
+}
+
+// hostStatsService resets the host labels of a proxy along with its stats
+type hostStatsService struct {
+	StatsService
+	hosts *hostStats
 }
 
-var statsService StatsService
+func (s *hostStatsService) Reset() {
+	s.StatsService.Reset()
+	s.hosts.reset()
+}
 
-func init() {
-	statsService = newStatsService()
+func ErrorStat(kind ErrorKind) string {
+	return LabeledStat(StatErrorsByKind, string(kind))
 }
 
-func newStatsService() StatsService {
+func NewStatsService() StatsService {
 	return &defaultStatsService{
-		mutex: &sync.Mutex{},
-		stats: Stats{
-			StatActiveConnections:      0,
-			StatActiveConnectRequests:  0,
-			StatActiveUpgradedRequests: 0,
-			StatInterceptedRequests:    0,
-			StatInterceptedResponses:   0,
-			StatUpgradedRequests:       0,
-		},
+		mutex:      &sync.Mutex{},
+		stats:      initialStats(),
+		histograms: map[string]*Histogram{},
 	}
 }
 
-func GetStatsService() StatsService {
-	return statsService
+func initialStats() Stats {
+	return Stats{
+		StatActiveConnections:      0,
+		StatActiveConnectRequests:  0,
+		StatActiveUpgradedRequests: 0,
+		StatInterceptedRequests:    0,
+		StatInterceptedResponses:   0,
+		StatUpgradedRequests:       0,
+		StatHookFailures:           0,
+		StatHookTimeouts:           0,
+		StatHookPanics:             0,
+		StatDisabledHooks:          0,
+		StatDroppedHookEvents:      0,
+		StatBytesToUpstream:        0,
+		StatBytesToClient:          0,
+		StatProxyAuthFailures:      0,
+		StatCertificateRejections:  0,
+		StatNonHTTPTunnels:         0,
+		StatPassthroughHosts:       0,
+		StatPassthroughConnections: 0,
+
+		StatIdleUpstreamConnections:   0,
+		StatReusedUpstreamConnections: 0,
+
+		StatClientTimeouts:      0,
+		StatRejectedConnections: 0,
+		StatOversizedHeaders:    0,
+
+		StatMappedRequests: 0,
+		StatInjectedFaults: 0,
+	}
 }
 
 func (ss *defaultStatsService) Increase(stat string) {
+	ss.Add(stat, 1)
+}
+
+func (ss *defaultStatsService) Decrease(stat string) {
+	ss.Add(stat, -1)
+}
+
+func (ss *defaultStatsService) Add(stat string, delta int) {
 	if ss == nil {
 		return
 	}
 
 	ss.mutex.Lock()
-	ss.stats[stat]++
+	ss.stats[stat] += delta
 	ss.mutex.Unlock()
 }
 
-func (ss *defaultStatsService) Decrease(stat string) {
+func (ss *defaultStatsService) Set(stat string, value int) {
 	if ss == nil {
 		return
 	}
 
 	ss.mutex.Lock()
-	ss.stats[stat]--
+	ss.stats[stat] = value
 	ss.mutex.Unlock()
 }
 
-func (ss *defaultStatsService) Set(stat string, value int) {
+func (ss *defaultStatsService) Observe(histogram string, d time.Duration) {
 	if ss == nil {
 		return
 	}
 
 	ss.mutex.Lock()
-	ss.stats[stat] = value
-	ss.mutex.Unlock()
+	defer ss.mutex.Unlock()
+
+	h, ok := ss.histograms[histogram]
+	if !ok {
+		h = newHistogram(DefaultHistogramBuckets)
+		ss.histograms[histogram] = h
+	}
+
+	h.observe(d.Seconds())
 }
 
 func (ss *defaultStatsService) Get() Stats {
@@ -96,17 +287,82 @@ func (ss *defaultStatsService) Get() Stats {
 	return result
 }
 
+func (ss *defaultStatsService) GetHistograms() map[string]Histogram {
+	if ss == nil {
+		return map[string]Histogram{}
+	}
+
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	result := map[string]Histogram{}
+	for k, h := range ss.histograms {
+		result[k] = h.clone()
+	}
+
+	return result
+}
+
 func (ss *defaultStatsService) Reset() {
 	if ss == nil {
 		return
 	}
 
 	ss.mutex.Lock()
-	defer ss.mutex.Lock()
+	defer ss.mutex.Unlock()
 
-	newStats := map[string]int{}
-	for k, _ := range ss.stats {
-		newStats[k] = 0
+	// gauges keep their values, they reflect the current state. Labeled
+	// stats are removed, so that the labels no longer seen are not exported
+	newStats := initialStats()
+	for k, v := range ss.stats {
+		switch {
+		case isGauge(k):
+			newStats[k] = v
+		case !isLabeled(k):
+			newStats[k] = 0
+		}
 	}
 	ss.stats = newStats
+	ss.histograms = map[string]*Histogram{}
+}
+
+func isLabeled(stat string) bool {
+	_, _, ok := SplitLabeledStat(stat)
+	return ok
+}
+
+func isGauge(stat string) bool {
+	switch stat {
+	case StatActiveConnections, StatActiveConnectRequests, StatActiveUpgradedRequests, StatDisabledHooks:
+		return true
+	}
+
+	return false
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		Buckets: buckets,
+		Counts:  make([]uint64, len(buckets)+1),
+	}
+}
+
+func (h *Histogram) observe(v float64) {
+	i := 0
+	for i < len(h.Buckets) && v > h.Buckets[i] {
+		i++
+	}
+
+	h.Counts[i]++
+	h.Count++
+	h.Sum += v
+}
+
+func (h *Histogram) clone() Histogram {
+	return Histogram{
+		Buckets: append([]float64{}, h.Buckets...),
+		Counts:  append([]uint64{}, h.Counts...),
+		Count:   h.Count,
+		Sum:     h.Sum,
+	}
 }
@@ -0,0 +1,99 @@
+package efincore
+
+import (
+	"context"
+	"maps"
+	"time"
+)
+
+const DefaultStatsWatchInterval = time.Second
+
+// StatsSnapshot is a point in time copy of the stats. Rates contains the
+// change of each counter since the previous snapshot
+type StatsSnapshot struct {
+	Time  time.Time
+	Stats Stats
+	Rates map[string]StatRate
+}
+
+type StatRate struct {
+	Delta     int
+	PerSecond float64
+}
+
+// WatchStats sends a snapshot of the stats every interval until ctx is
+// done. If onChange is true, snapshots are only sent when a stat changed;
+// stats are still checked every interval. The first snapshot is sent
+// immediately and has no rates
+func WatchStats(ctx context.Context, stats StatsService, interval time.Duration, onChange bool) <-chan StatsSnapshot {
+	if interval <= 0 {
+		interval = DefaultStatsWatchInterval
+	}
+
+	c := make(chan StatsSnapshot)
+
+	go func() {
+		defer close(c)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var last *StatsSnapshot
+		for {
+			current := StatsSnapshot{
+				Time:  time.Now(),
+				Stats: stats.Get(),
+				Rates: map[string]StatRate{},
+			}
+
+			if last == nil || !onChange || !maps.Equal(last.Stats, current.Stats) {
+				if last != nil {
+					current.Rates = statRates(*last, current)
+				}
+
+				select {
+				case c <- current:
+				case <-ctx.Done():
+					return
+				}
+
+				last = &current
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return c
+}
+
+// statRates computes the rates of the counters, gauges are not included
+func statRates(prev, current StatsSnapshot) map[string]StatRate {
+	elapsed := current.Time.Sub(prev.Time).Seconds()
+
+	result := map[string]StatRate{}
+	for k, v := range current.Stats {
+		name, _, _ := SplitLabeledStat(k)
+		if isGauge(name) {
+			continue
+		}
+
+		// counters lower than before were reset, count from zero
+		delta := v - prev.Stats[k]
+		if delta < 0 {
+			delta = v
+		}
+
+		rate := StatRate{Delta: delta}
+		if elapsed > 0 {
+			rate.PerSecond = float64(delta) / elapsed
+		}
+		result[k] = rate
+	}
+
+	return result
+}
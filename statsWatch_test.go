@@ -0,0 +1,153 @@
+package efincore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/artilugio0/efincore/proto"
+	"google.golang.org/grpc"
+)
+
+func TestWatchStats_SendsSnapshotsWithRates(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stats := NewStatsService()
+	c := WatchStats(ctx, stats, 10*time.Millisecond, false)
+
+	first := <-c
+	if len(first.Rates) != 0 {
+		t.Errorf("first snapshot rates: got '%v', expected none", first.Rates)
+	}
+
+	stats.Add(StatBytesToClient, 100)
+	stats.Increase(StatActiveConnections)
+
+	var second StatsSnapshot
+	for second = range c {
+		if second.Stats[StatBytesToClient] == 100 {
+			break
+		}
+	}
+
+	rate, ok := second.Rates[StatBytesToClient]
+	if !ok {
+		t.Fatalf("rate of '%s' not found", StatBytesToClient)
+	}
+
+	if rate.Delta != 100 {
+		t.Errorf("delta: got '%d', expected '%d'", rate.Delta, 100)
+	}
+
+	if rate.PerSecond <= 0 {
+		t.Errorf("per second: got '%f', expected more than 0", rate.PerSecond)
+	}
+
+	if _, ok := second.Rates[StatActiveConnections]; ok {
+		t.Errorf("gauge '%s' should not have a rate", StatActiveConnections)
+	}
+}
+
+func TestWatchStats_OnChange_OnlySendsChanges(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stats := NewStatsService()
+	c := WatchStats(ctx, stats, 5*time.Millisecond, true)
+
+	<-c
+
+	select {
+	case s := <-c:
+		t.Fatalf("unexpected snapshot without changes: %v", s.Stats)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	stats.Increase(StatInterceptedRequests)
+
+	select {
+	case s := <-c:
+		if s.Rates[StatInterceptedRequests].Delta != 1 {
+			t.Errorf("delta: got '%d', expected '%d'", s.Rates[StatInterceptedRequests].Delta, 1)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("snapshot not sent after change")
+	}
+}
+
+func TestWatchStats_ChannelClosedWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := WatchStats(ctx, NewStatsService(), time.Hour, false)
+
+	<-c
+	cancel()
+
+	select {
+	case _, ok := <-c:
+		if ok {
+			t.Errorf("unexpected snapshot after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("channel not closed after cancel")
+	}
+}
+
+type testWatchStatsStream struct {
+	grpc.ServerStream
+
+	ctx     context.Context
+	updates chan *proto.StatsUpdate
+}
+
+func (s *testWatchStatsStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testWatchStatsStream) Send(u *proto.StatsUpdate) error {
+	s.updates <- u
+	return nil
+}
+
+func TestGRPCServerWatchStats_SendsUpdates(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stats := NewStatsService()
+	stats.Add(StatInterceptedRequests, 2)
+
+	server := NewGRPCServer("127.0.0.1:0")
+	server.SetStatsService(stats)
+
+	stream := &testWatchStatsStream{
+		ctx:     ctx,
+		updates: make(chan *proto.StatsUpdate, 1),
+	}
+
+	errChan := make(chan error)
+	go func() {
+		errChan <- server.WatchStats(&proto.WatchStatsInput{IntervalMs: 10}, stream)
+	}()
+
+	update := <-stream.updates
+	found := false
+	for _, s := range update.Stats {
+		if s.Name == StatInterceptedRequests && s.Value == 2 {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("stat '%s' with value 2 not found in %v", StatInterceptedRequests, update.Stats)
+	}
+
+	cancel()
+	// unblock a pending send
+	go func() {
+		for range stream.updates {
+		}
+	}()
+
+	if err := <-errChan; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
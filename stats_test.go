@@ -0,0 +1,226 @@
+package efincore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/artilugio0/efincore/proto"
+)
+
+func TestStatsService_ResetKeepsGauges(t *testing.T) {
+	stats := NewStatsService()
+
+	stats.Increase(StatActiveConnections)
+	stats.Increase(StatInterceptedRequests)
+	stats.Increase(HostStat("example.com"))
+
+	done := make(chan struct{})
+	go func() {
+		stats.Reset()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("reset did not finish")
+	}
+
+	got := stats.Get()
+	if got[StatActiveConnections] != 1 {
+		t.Errorf("active connections: got '%d', expected '%d'", got[StatActiveConnections], 1)
+	}
+
+	if got[StatInterceptedRequests] != 0 {
+		t.Errorf("intercepted requests: got '%d', expected '%d'", got[StatInterceptedRequests], 0)
+	}
+
+	if got[HostStat("example.com")] != 0 {
+		t.Errorf("requests by host: got '%d', expected '%d'", got[HostStat("example.com")], 0)
+	}
+}
+
+func TestStatsService_ObserveFillsHistogramBuckets(t *testing.T) {
+	stats := NewStatsService()
+
+	stats.Observe(HistogramUpstreamLatency, 2*time.Millisecond)
+	stats.Observe(HistogramUpstreamLatency, 3*time.Millisecond)
+	stats.Observe(HistogramUpstreamLatency, time.Minute)
+
+	h, ok := stats.GetHistograms()[HistogramUpstreamLatency]
+	if !ok {
+		t.Fatalf("histogram '%s' not found", HistogramUpstreamLatency)
+	}
+
+	if h.Count != 3 {
+		t.Errorf("count: got '%d', expected '%d'", h.Count, 3)
+	}
+
+	// 2ms and 3ms fall in the 5ms bucket
+	if h.Counts[1] != 2 {
+		t.Errorf("5ms bucket: got '%d', expected '%d'", h.Counts[1], 2)
+	}
+
+	if h.Counts[len(h.Counts)-1] != 1 {
+		t.Errorf("+Inf bucket: got '%d', expected '%d'", h.Counts[len(h.Counts)-1], 1)
+	}
+}
+
+func TestSplitLabeledStat(t *testing.T) {
+	name, label, ok := SplitLabeledStat(StatusStat(404))
+	if !ok || name != StatResponsesByStatus || label != "404" {
+		t.Errorf("split: got '%s' '%s' '%t', expected '%s' '%s' '%t'", name, label, ok, StatResponsesByStatus, "404", true)
+	}
+}
+
+func TestTwoProxies_StatsAreIndependent(t *testing.T) {
+	proxy1 := runTestProxy(t)
+	proxy2 := runTestProxy(t)
+
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("response"))
+	})
+
+	client := newTestClientProxy(t, proxy1.URL().String())
+	response, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stats1 := proxy1.GetStats()
+	stats2 := proxy2.GetStats()
+
+	if got := stats1[HostStat(serverURL.Hostname())]; got != 1 {
+		t.Errorf("proxy 1 requests by host: got '%d', expected '%d'", got, 1)
+	}
+
+	if got := stats1[StatusStat(http.StatusAccepted)]; got != 1 {
+		t.Errorf("proxy 1 responses by status: got '%d', expected '%d'", got, 1)
+	}
+
+	if got := stats1[StatBytesToClient]; got == 0 {
+		t.Errorf("proxy 1 bytes to client: got '%d', expected more than 0", got)
+	}
+
+	if got := stats2[HostStat(serverURL.Hostname())]; got != 0 {
+		t.Errorf("proxy 2 requests by host: got '%d', expected '%d'", got, 0)
+	}
+
+	if got := stats2[StatBytesToClient]; got != 0 {
+		t.Errorf("proxy 2 bytes to client: got '%d', expected '%d'", got, 0)
+	}
+
+	if _, ok := proxy1.GetHistograms()[HistogramTotalLatency]; !ok {
+		t.Errorf("proxy 1 histogram '%s' not found", HistogramTotalLatency)
+	}
+}
+
+func TestHostStats_CapsDistinctHosts(t *testing.T) {
+	h := newHostStats(2)
+
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"a.example.com", HostStat("a.example.com")},
+		{"b.example.com", HostStat("b.example.com")},
+		{"c.example.com", HostStat(OtherHostsLabel)},
+		{"a.example.com", HostStat("a.example.com")},
+	}
+
+	for _, tt := range tests {
+		if got := h.stat(tt.host); got != tt.expected {
+			t.Errorf("stat of '%s': got '%s', expected '%s'", tt.host, got, tt.expected)
+		}
+	}
+
+	h.setMax(0)
+	if got := h.stat("d.example.com"); got != HostStat(OtherHostsLabel) {
+		t.Errorf("stat with max 0: got '%s', expected '%s'", got, HostStat(OtherHostsLabel))
+	}
+}
+
+func TestProxy_SetMaxHostStats_CountsOtherHosts(t *testing.T) {
+	proxy := runTestProxy(t)
+	proxy.SetMaxHostStats(1)
+
+	server := newTestServer(func(w http.ResponseWriter, r *http.Request) {})
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	client := newTestClientProxy(t, proxy.URL().String())
+	for _, host := range []string{"127.0.0.1", "localhost"} {
+		resp, err := client.Get("http://" + host + ":" + serverURL.Port() + "/")
+		if err != nil {
+			t.Fatalf("request to '%s' failed: %v", host, err)
+		}
+		resp.Body.Close()
+	}
+
+	stats := proxy.GetStats()
+	if got := stats[HostStat("127.0.0.1")]; got != 1 {
+		t.Errorf("requests to the first host: got '%d', expected '%d'", got, 1)
+	}
+
+	if got := stats[HostStat(OtherHostsLabel)]; got != 1 {
+		t.Errorf("requests to other hosts: got '%d', expected '%d'", got, 1)
+	}
+
+	if _, ok := stats[HostStat("localhost")]; ok {
+		t.Errorf("requests to the second host counted with its own label")
+	}
+}
+
+func TestProxy_ResetStats_ResetsHostLabels(t *testing.T) {
+	proxy := NewProxy("127.0.0.1:0")
+	proxy.SetMaxHostStats(1)
+
+	proxy.mitm.stats.Increase(proxy.mitm.hostStats.stat("a.example.com"))
+	proxy.mitm.stats.Increase(proxy.mitm.hostStats.stat("b.example.com"))
+	proxy.ResetStats()
+
+	if got := proxy.mitm.hostStats.stat("b.example.com"); got != HostStat("b.example.com") {
+		t.Errorf("stat after reset: got '%s', expected '%s'", got, HostStat("b.example.com"))
+	}
+
+	if _, ok := proxy.GetStats()[HostStat("a.example.com")]; ok {
+		t.Errorf("label of a host seen before the reset still exported")
+	}
+}
+
+func TestProxyNewGRPCServer_ReportsProxyStats(t *testing.T) {
+	proxy := NewProxy("127.0.0.1:0")
+	proxy.StatsService().Increase(StatInterceptedRequests)
+
+	out, err := proxy.NewGRPCServer("127.0.0.1:0").GetStats(context.Background(), &proto.GetStatsInput{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got int64
+	for _, stat := range out.Stats {
+		if stat.Name == StatInterceptedRequests {
+			got = stat.Value
+		}
+	}
+
+	if got != 1 {
+		t.Errorf("intercepted requests: got '%d', expected '%d'", got, 1)
+	}
+}
@@ -0,0 +1,277 @@
+package efincore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// transparentHeaderTimeout limits the time a plain HTTP client has to
+	// send the headers of its first request
+	transparentHeaderTimeout = 10 * time.Second
+
+	// maxPeekedHeaderSize is the size of the headers that can be read to
+	// find the Host of a plain HTTP request
+	maxPeekedHeaderSize = 64 << 10
+)
+
+// OriginalDestination recovers the address, as host:port, a redirected
+// connection was originally sent to
+type OriginalDestination interface {
+	OriginalDestination(conn net.Conn) (string, error)
+}
+
+type OriginalDestinationFunc func(conn net.Conn) (string, error)
+
+func (f OriginalDestinationFunc) OriginalDestination(conn net.Conn) (string, error) {
+	return f(conn)
+}
+
+// SOOriginalDst reads the SO_ORIGINAL_DST socket option set by iptables
+// REDIRECT and DNAT rules. It is only supported on linux
+var SOOriginalDst OriginalDestination = OriginalDestinationFunc(originalDst)
+
+func (m *mitm) SetOriginalDestination(d OriginalDestination) {
+	if d == nil {
+		m.originalDestination.Store(nil)
+		return
+	}
+
+	m.originalDestination.Store(&d)
+}
+
+// getOriginalDestination returns the original destination of conn, or an
+// empty string if it is unknown. Connections that were not redirected
+// report the address of the listener, which is not a valid destination
+func (m *mitm) getOriginalDestination(conn net.Conn) string {
+	d := m.originalDestination.Load()
+	if d == nil {
+		return ""
+	}
+
+	dst, err := (*d).OriginalDestination(conn)
+	if err != nil {
+		m.logger.Debug("could not get original destination", errAttr(err))
+		return ""
+	}
+
+	if dst == conn.LocalAddr().String() {
+		return ""
+	}
+
+	return dst
+}
+
+// ServeTransparent accepts connections redirected to the proxy, for
+// example by iptables. The destination is the original destination of the
+// connection if it is known, or otherwise the TLS SNI or HTTP Host header.
+// TLS and HTTP connections are inspected like CONNECT tunnels, other
+// protocols are passed through when the original destination is known
+func (m *mitm) ServeTransparent(l net.Listener) error {
+	l = m.limitListener(l)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		go m.serveTransparentConn(conn)
+	}
+}
+
+func (m *mitm) serveTransparentConn(conn net.Conn) {
+	m.stats.Increase(StatActiveConnections)
+	defer m.stats.Decrease(StatActiveConnections)
+
+	defer conn.Close()
+
+	logger := m.logger.with(slog.String("client", conn.RemoteAddr().String()))
+
+	origDst := m.getOriginalDestination(conn)
+	reader := bufio.NewReaderSize(conn, maxPeekedHeaderSize)
+
+	if isTLSClientHello(conn, reader) {
+		m.serveTransparentTLS(conn, reader, origDst, logger)
+		return
+	}
+
+	if isHTTPRequest(conn, reader) {
+		m.serveTransparentHTTP(conn, reader, origDst, logger)
+		return
+	}
+
+	if origDst == "" {
+		logger.Warn("could not find the destination of a transparent connection")
+		return
+	}
+
+	destConn, err := m.dialTransparent(origDst, logger)
+	if err != nil {
+		return
+	}
+	defer destConn.Close()
+
+	m.passthroughTunnel(newBufferedConn(conn, reader), destConn, origDst)
+}
+
+func (m *mitm) serveTransparentTLS(conn net.Conn, reader *bufio.Reader, origDst string, logger *eventLogger) {
+	hello, srcConn, err := peekClientHello(conn, reader)
+	if err != nil && origDst == "" {
+		logger.Warn("could not parse ClientHello", errAttr(err))
+		return
+	}
+
+	serverName := ""
+	if hello != nil {
+		serverName = hello.ServerName
+	}
+
+	dst := origDst
+	if dst == "" {
+		if serverName == "" {
+			logger.Warn("could not find the destination of a transparent connection: no SNI")
+			return
+		}
+
+		dst = net.JoinHostPort(serverName, "443")
+	}
+
+	host, portStr, err := net.SplitHostPort(dst)
+	if err != nil {
+		logger.Warn("invalid destination", errAttr(err), hostAttr(dst))
+		return
+	}
+	port, _ := strconv.Atoi(portStr)
+
+	if serverName == "" {
+		serverName = host
+	}
+	logger = logger.with(hostAttr(net.JoinHostPort(serverName, portStr)))
+
+	destConn, err := m.dialTransparent(dst, logger)
+	if err != nil {
+		return
+	}
+	defer destConn.Close()
+
+	if hello == nil || !m.shouldInterceptHost("https", serverName, port) || m.passthroughHosts.contains(serverName) {
+		m.passthroughTunnel(srcConn, destConn, dst)
+		return
+	}
+
+	m.stats.Increase(StatActiveConnectRequests)
+	defer m.stats.Decrease(StatActiveConnectRequests)
+
+	m.inspectTLS(srcConn, destConn, serverName, port, logger)
+}
+
+func (m *mitm) serveTransparentHTTP(conn net.Conn, reader *bufio.Reader, origDst string, logger *eventLogger) {
+	conn.SetReadDeadline(time.Now().Add(transparentHeaderTimeout))
+	req, err := peekHTTPRequest(reader)
+	conn.SetReadDeadline(time.Time{})
+
+	srcConn := newBufferedConn(conn, reader)
+
+	if err != nil {
+		if origDst == "" {
+			logger.Warn("could not read transparent HTTP request", errAttr(err))
+			return
+		}
+
+		destConn, err := m.dialTransparent(origDst, logger)
+		if err != nil {
+			return
+		}
+		defer destConn.Close()
+
+		m.passthroughTunnel(srcConn, destConn, origDst)
+		return
+	}
+
+	hostPort := req.Host
+	if hostPort == "" {
+		hostPort = origDst
+	}
+
+	if hostPort == "" {
+		logger.Warn("could not find the destination of a transparent connection: no Host header")
+		return
+	}
+
+	target := &url.URL{Host: hostPort}
+	host := target.Hostname()
+	port := portOrDefault(target.Port(), "http")
+	hostPort = net.JoinHostPort(host, strconv.Itoa(port))
+
+	dst := origDst
+	if dst == "" {
+		dst = hostPort
+	}
+	logger = logger.with(hostAttr(hostPort))
+
+	destConn, err := m.dialTransparent(dst, logger)
+	if err != nil {
+		return
+	}
+	defer destConn.Close()
+
+	if !m.shouldInterceptHost("http", host, port) {
+		m.passthroughTunnel(srcConn, destConn, dst)
+		return
+	}
+
+	m.stats.Increase(StatActiveConnectRequests)
+	defer m.stats.Decrease(StatActiveConnectRequests)
+
+	m.inspectTunnel(srcConn, destConn, &url.URL{Scheme: "http", Host: hostPort}, nil)
+}
+
+func (m *mitm) dialTransparent(dst string, logger *eventLogger) (net.Conn, error) {
+	destConn, err := m.dial(context.Background(), dst)
+	if err != nil {
+		host, _, _ := net.SplitHostPort(dst)
+		proxyErr := newProxyError(host, fmt.Errorf("could not connect to destination: %w", err))
+		m.reportError(logger, "could not connect to destination", proxyErr)
+		return nil, err
+	}
+
+	return destConn, nil
+}
+
+// isHTTPRequest reports whether the first byte sent by the client may
+// start an HTTP method
+func isHTTPRequest(conn net.Conn, r *bufio.Reader) bool {
+	conn.SetReadDeadline(time.Now().Add(clientPeekTimeout))
+	first, err := r.Peek(1)
+	conn.SetReadDeadline(time.Time{})
+
+	return err == nil && first[0] >= 'A' && first[0] <= 'Z'
+}
+
+// peekHTTPRequest parses the headers of the next request without
+// consuming them. The body of the returned request can not be read
+func peekHTTPRequest(r *bufio.Reader) (*http.Request, error) {
+	for {
+		peeked, _ := r.Peek(r.Buffered())
+		if end := bytes.Index(peeked, []byte("\r\n\r\n")); end >= 0 {
+			return http.ReadRequest(bufio.NewReader(bytes.NewReader(peeked[:end+4])))
+		}
+
+		// wait for at least one more byte than the ones already buffered
+		if _, err := r.Peek(r.Buffered() + 1); err != nil {
+			if errors.Is(err, bufio.ErrBufferFull) {
+				return nil, errors.New("request headers too large")
+			}
+			return nil, err
+		}
+	}
+}
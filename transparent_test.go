@@ -0,0 +1,183 @@
+package efincore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func runTestTransparentProxy(t *testing.T, origDst OriginalDestination) (*Proxy, string) {
+	t.Helper()
+
+	proxy := runTestProxy(t)
+	proxy.SetOriginalDestination(origDst)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go proxy.ServeTransparent(l)
+
+	return proxy, l.Addr().String()
+}
+
+// newTestTransparentClient returns a client whose connections are all
+// sent to addr, as if they were redirected by iptables
+func newTestTransparentClient(addr string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, addr)
+			},
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+	}
+}
+
+func staticOriginalDestination(dst string) OriginalDestination {
+	return OriginalDestinationFunc(func(conn net.Conn) (string, error) {
+		return dst, nil
+	})
+}
+
+func TestTransparent_HTTPSWithOriginalDestination_IsIntercepted(t *testing.T) {
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("response"))
+	})
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	proxy, addr := runTestTransparentProxy(t, staticOriginalDestination(serverURL.Host))
+
+	hookCalled := make(chan *url.URL, 1)
+	proxy.AddRequestInHook(HookRequestReadFunc(func(r *http.Request, id uuid.UUID) error {
+		hookCalled <- r.URL
+		return nil
+	}))
+
+	// the host name is only known from the SNI
+	resp, err := newTestTransparentClient(addr).Get("https://example.com/path")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read response body: %v", err)
+	}
+
+	if string(body) != "response" {
+		t.Errorf("response body: got '%s', expected '%s'", body, "response")
+	}
+
+	select {
+	case u := <-hookCalled:
+		expected := "https://example.com:" + serverURL.Port() + "/path"
+		if u.String() != expected {
+			t.Errorf("hook request URL: got '%s', expected '%s'", u.String(), expected)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("request hook not called")
+	}
+}
+
+func TestTransparent_PlainHTTPWithoutOriginalDestination_UsesHostHeader(t *testing.T) {
+	server := newTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("response"))
+	})
+
+	proxy, addr := runTestTransparentProxy(t, nil)
+
+	hookCalled := make(chan *url.URL, 1)
+	proxy.AddRequestInHook(HookRequestReadFunc(func(r *http.Request, id uuid.UUID) error {
+		hookCalled <- r.URL
+		return nil
+	}))
+
+	resp, err := newTestTransparentClient(addr).Get(server.URL + "/path")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read response body: %v", err)
+	}
+
+	if string(body) != "response" {
+		t.Errorf("response body: got '%s', expected '%s'", body, "response")
+	}
+
+	select {
+	case u := <-hookCalled:
+		if u.String() != server.URL+"/path" {
+			t.Errorf("hook request URL: got '%s', expected '%s'", u.String(), server.URL+"/path")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("request hook not called")
+	}
+}
+
+func TestTransparent_OtherProtocolIsPassedThrough(t *testing.T) {
+	_, addr := runTestTransparentProxy(t, staticOriginalDestination(runTestEchoServer(t)))
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("could not connect to proxy: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("\x00ping")); err != nil {
+		t.Fatalf("could not write to proxy: %v", err)
+	}
+
+	got := make([]byte, 5)
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatalf("could not read from proxy: %v", err)
+	}
+
+	if !bytes.Equal(got, []byte("\x00ping")) {
+		t.Errorf("data: got '%q', expected '%q'", got, "\x00ping")
+	}
+}
+
+func TestPeekHTTPRequest_DoesNotConsumeRequest(t *testing.T) {
+	raw := "GET /path HTTP/1.1\r\nHost: example.com\r\n\r\n"
+	r := bufio.NewReader(strings.NewReader(raw))
+
+	req, err := peekHTTPRequest(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if req.Host != "example.com" {
+		t.Errorf("host: got '%s', expected '%s'", req.Host, "example.com")
+	}
+
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(rest) != raw {
+		t.Errorf("remaining data: got '%s', expected '%s'", rest, raw)
+	}
+}
@@ -0,0 +1,414 @@
+package efincore
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UpstreamProxyRule routes the connections to the hosts matching Host and
+// Port through Proxy. Host accepts the same patterns as ScopeRule. Proxy is
+// a URL with scheme http, https, socks5 or socks5h, and optional user
+// credentials; an empty Proxy or "direct" connects directly.
+type UpstreamProxyRule struct {
+	Host  string `json:"host,omitempty"`
+	Port  int    `json:"port,omitempty"`
+	Proxy string `json:"proxy"`
+}
+
+type compiledUpstreamProxyRule struct {
+	rule  UpstreamProxyRule
+	match compiledScopeRule
+	proxy *url.URL
+}
+
+// UpstreamProxies is an ordered list of upstream proxy rules. The first
+// rule matching a host decides how to connect to it. Hosts that do not
+// match any rule are connected directly.
+type UpstreamProxies struct {
+	rules []compiledUpstreamProxyRule
+}
+
+type upstreamProxiesFile struct {
+	Rules []UpstreamProxyRule `json:"rules"`
+}
+
+func NewUpstreamProxies(rules ...UpstreamProxyRule) (*UpstreamProxies, error) {
+	u := &UpstreamProxies{}
+
+	for i, r := range rules {
+		cr, err := compileUpstreamProxyRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid upstream proxy rule %d: %v", i, err)
+		}
+
+		u.rules = append(u.rules, cr)
+	}
+
+	return u, nil
+}
+
+func ParseUpstreamProxies(r io.Reader) (*UpstreamProxies, error) {
+	var f upstreamProxiesFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("could not decode upstream proxies: %v", err)
+	}
+
+	return NewUpstreamProxies(f.Rules...)
+}
+
+func LoadUpstreamProxies(path string) (*UpstreamProxies, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseUpstreamProxies(f)
+}
+
+func (u *UpstreamProxies) Rules() []UpstreamProxyRule {
+	if u == nil {
+		return nil
+	}
+
+	result := make([]UpstreamProxyRule, len(u.rules))
+	for i, r := range u.rules {
+		result[i] = r.rule
+	}
+
+	return result
+}
+
+// ProxyFor returns the proxy used to connect to the given host, or nil if
+// the connection is direct
+func (u *UpstreamProxies) ProxyFor(host string, port int) *url.URL {
+	if u == nil {
+		return nil
+	}
+
+	for _, r := range u.rules {
+		if r.match.matchesHost("", host, port) {
+			return r.proxy
+		}
+	}
+
+	return nil
+}
+
+func compileUpstreamProxyRule(r UpstreamProxyRule) (compiledUpstreamProxyRule, error) {
+	cr := compiledUpstreamProxyRule{rule: r}
+
+	match, err := compileScopeRule(ScopeRule{Host: r.Host, Port: r.Port})
+	if err != nil {
+		return cr, err
+	}
+	cr.match = match
+
+	if r.Proxy == "" || strings.EqualFold(r.Proxy, "direct") {
+		return cr, nil
+	}
+
+	proxyURL, err := url.Parse(r.Proxy)
+	if err != nil {
+		return cr, err
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return cr, fmt.Errorf("unsupported proxy scheme '%s'", proxyURL.Scheme)
+	}
+
+	if proxyURL.Hostname() == "" {
+		return cr, fmt.Errorf("missing proxy host in '%s'", r.Proxy)
+	}
+
+	if proxyURL.Port() == "" {
+		port := "1080"
+		switch proxyURL.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+		proxyURL.Host = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+	cr.proxy = proxyURL
+
+	return cr, nil
+}
+
+// UpstreamProxyError is returned when an upstream proxy refuses to connect
+// to the destination
+type UpstreamProxyError struct {
+	Proxy  string
+	Reason string
+}
+
+func (e *UpstreamProxyError) Error() string {
+	return fmt.Sprintf("upstream proxy '%s' failed: %s", e.Proxy, e.Reason)
+}
+
+// dialUpstream connects to addr, through an upstream proxy if one of the
+// rules matches the host. The proxy is asked to connect to the IP of the
+// host if it is overridden by the resolver
+func dialUpstream(ctx context.Context, upstream *UpstreamProxies, resolver *Resolver, addr string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, _ := strconv.Atoi(portStr)
+
+	dialer := &net.Dialer{}
+
+	proxyURL := upstream.ProxyFor(host, port)
+	if proxyURL == nil {
+		return resolver.dial(ctx, dialer, addr)
+	}
+
+	if ip, ok := resolver.override(host); ok {
+		host = ip.String()
+		addr = net.JoinHostPort(host, portStr)
+	}
+
+	conn, err := resolver.dial(ctx, dialer, proxyURL.Host)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to upstream proxy: %w", err)
+	}
+
+	// the handshake with the proxy must not outlive the context
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	var tunnel net.Conn
+	switch proxyURL.Scheme {
+	case "https":
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("could not connect to upstream proxy: %w", err)
+		}
+		tunnel, err = httpConnect(tlsConn, proxyURL, addr)
+		conn = tlsConn
+	case "http":
+		tunnel, err = httpConnect(conn, proxyURL, addr)
+	default:
+		tunnel, err = socks5Connect(ctx, conn, proxyURL, resolver, host, port)
+	}
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tunnel, nil
+}
+
+func httpConnect(conn net.Conn, proxyURL *url.URL, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := proxyURL.User.Username() + ":" + password
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("could not send CONNECT to upstream proxy: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, fmt.Errorf("could not read CONNECT response of upstream proxy: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &UpstreamProxyError{Proxy: proxyURL.Redacted(), Reason: resp.Status}
+	}
+
+	return newBufferedConn(conn, reader), nil
+}
+
+const (
+	socks5Version = 0x05
+
+	socks5AuthNone     = 0x00
+	socks5AuthPassword = 0x02
+
+	socks5CmdConnect = 0x01
+
+	socks5AddrIPv4   = 0x01
+	socks5AddrDomain = 0x03
+	socks5AddrIPv6   = 0x04
+)
+
+var socks5Replies = map[byte]string{
+	0x01: "general failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// socks5Connect establishes a tunnel using the SOCKS5 protocol (RFC 1928)
+// with username and password authentication (RFC 1929). With the socks5
+// scheme the host is resolved locally, with socks5h by the proxy
+func socks5Connect(ctx context.Context, conn net.Conn, proxyURL *url.URL, resolver *Resolver, host string, port int) (net.Conn, error) {
+	proxyName := proxyURL.Redacted()
+
+	methods := []byte{socks5AuthNone}
+	if proxyURL.User != nil {
+		methods = append(methods, socks5AuthPassword)
+	}
+
+	greeting := append([]byte{socks5Version, byte(len(methods))}, methods...)
+	if _, err := conn.Write(greeting); err != nil {
+		return nil, fmt.Errorf("could not send SOCKS5 greeting: %w", err)
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, fmt.Errorf("could not read SOCKS5 greeting: %w", err)
+	}
+
+	if reply[0] != socks5Version {
+		return nil, &UpstreamProxyError{Proxy: proxyName, Reason: fmt.Sprintf("unexpected SOCKS version %d", reply[0])}
+	}
+
+	switch reply[1] {
+	case socks5AuthNone:
+	case socks5AuthPassword:
+		if proxyURL.User == nil {
+			return nil, &UpstreamProxyError{Proxy: proxyName, Reason: "authentication required"}
+		}
+
+		if err := socks5Authenticate(conn, proxyURL); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, &UpstreamProxyError{Proxy: proxyName, Reason: "no acceptable authentication method"}
+	}
+
+	request := []byte{socks5Version, socks5CmdConnect, 0x00}
+
+	ip := net.ParseIP(host)
+	if ip == nil && proxyURL.Scheme == "socks5" {
+		ips, err := resolver.LookupIP(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		ip = ips[0]
+	}
+
+	switch {
+	case ip == nil:
+		if len(host) > 255 {
+			return nil, fmt.Errorf("host name too long: '%s'", host)
+		}
+		request = append(request, socks5AddrDomain, byte(len(host)))
+		request = append(request, host...)
+	case ip.To4() != nil:
+		request = append(request, socks5AddrIPv4)
+		request = append(request, ip.To4()...)
+	default:
+		request = append(request, socks5AddrIPv6)
+		request = append(request, ip.To16()...)
+	}
+	request = binary.BigEndian.AppendUint16(request, uint16(port))
+
+	if _, err := conn.Write(request); err != nil {
+		return nil, fmt.Errorf("could not send SOCKS5 request: %w", err)
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, fmt.Errorf("could not read SOCKS5 reply: %w", err)
+	}
+
+	if header[1] != 0x00 {
+		reason, ok := socks5Replies[header[1]]
+		if !ok {
+			reason = fmt.Sprintf("unknown reply %d", header[1])
+		}
+
+		return nil, &UpstreamProxyError{Proxy: proxyName, Reason: reason}
+	}
+
+	// skip the bound address
+	var addrLen int
+	switch header[3] {
+	case socks5AddrIPv4:
+		addrLen = net.IPv4len
+	case socks5AddrIPv6:
+		addrLen = net.IPv6len
+	case socks5AddrDomain:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return nil, fmt.Errorf("could not read SOCKS5 reply: %w", err)
+		}
+		addrLen = int(l[0])
+	default:
+		return nil, &UpstreamProxyError{Proxy: proxyName, Reason: fmt.Sprintf("unknown address type %d", header[3])}
+	}
+
+	if _, err := io.ReadFull(conn, make([]byte, addrLen+2)); err != nil {
+		return nil, fmt.Errorf("could not read SOCKS5 reply: %w", err)
+	}
+
+	return conn, nil
+}
+
+func socks5Authenticate(conn net.Conn, proxyURL *url.URL) error {
+	user := proxyURL.User.Username()
+	password, _ := proxyURL.User.Password()
+
+	if len(user) > 255 || len(password) > 255 {
+		return errors.New("SOCKS5 credentials too long")
+	}
+
+	auth := []byte{0x01, byte(len(user))}
+	auth = append(auth, user...)
+	auth = append(auth, byte(len(password)))
+	auth = append(auth, password...)
+
+	if _, err := conn.Write(auth); err != nil {
+		return fmt.Errorf("could not send SOCKS5 credentials: %w", err)
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("could not read SOCKS5 authentication reply: %w", err)
+	}
+
+	if reply[1] != 0x00 {
+		return &UpstreamProxyError{Proxy: proxyURL.Redacted(), Reason: "authentication failed"}
+	}
+
+	return nil
+}
@@ -0,0 +1,310 @@
+package efincore
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PoolConfig configures the idle connections to upstream servers that are
+// kept open to be reused by other requests and tunnels
+type PoolConfig struct {
+	// MaxIdleConns limits the idle connections kept for all the hosts. Zero
+	// means no limit
+	MaxIdleConns int
+
+	// MaxIdleConnsPerHost limits the idle connections kept for each host
+	// and TLS settings. Zero or less disables the reuse of connections
+	MaxIdleConnsPerHost int
+
+	// IdleConnTimeout closes the connections that are idle for longer.
+	// Zero means no limit
+	IdleConnTimeout time.Duration
+}
+
+var DefaultPoolConfig = PoolConfig{
+	MaxIdleConns:        100,
+	MaxIdleConnsPerHost: 4,
+	IdleConnTimeout:     90 * time.Second,
+}
+
+// poolKey identifies the connections that can be used interchangeably
+type poolKey struct {
+	addr       string
+	serverName string
+	proxy      string
+}
+
+// pooledConn is a connection to an upstream server that can be returned to
+// the pool when the response of its last request was completely read. The
+// reader keeps the data received after that response
+type pooledConn struct {
+	net.Conn
+	reader *bufio.Reader
+	key    poolKey
+
+	pool    *upstreamPool
+	idle    bool
+	timer   *time.Timer
+	watcher chan error
+}
+
+func newPooledConn(conn net.Conn, key poolKey, pool *upstreamPool) *pooledConn {
+	return &pooledConn{
+		Conn:   conn,
+		reader: bufio.NewReader(conn),
+		key:    key,
+		pool:   pool,
+	}
+}
+
+// release returns the connection to the pool, or closes it if the pool is
+// full
+func (c *pooledConn) release() {
+	c.pool.put(c)
+}
+
+// upstreamPool keeps idle connections to upstream servers. While a
+// connection is idle it is watched, so it is discarded as soon as the
+// server closes it or sends unexpected data
+type upstreamPool struct {
+	mutex  *sync.Mutex
+	config PoolConfig
+	idle   map[poolKey][]*pooledConn
+	count  int
+	stats  StatsService
+}
+
+func newUpstreamPool(config PoolConfig, stats StatsService) *upstreamPool {
+	return &upstreamPool{
+		mutex:  &sync.Mutex{},
+		config: config,
+		idle:   map[poolKey][]*pooledConn{},
+		stats:  stats,
+	}
+}
+
+func (p *upstreamPool) getConfig() PoolConfig {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.config
+}
+
+func (p *upstreamPool) setConfig(config PoolConfig) {
+	p.mutex.Lock()
+	p.config = config
+	p.mutex.Unlock()
+
+	p.closeIdle()
+}
+
+func (p *upstreamPool) put(c *pooledConn) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.config.MaxIdleConnsPerHost <= 0 ||
+		len(p.idle[c.key]) >= p.config.MaxIdleConnsPerHost ||
+		(p.config.MaxIdleConns > 0 && p.count >= p.config.MaxIdleConns) {
+		c.Close()
+		return
+	}
+
+	c.idle = true
+	c.watcher = make(chan error, 1)
+	p.idle[c.key] = append(p.idle[c.key], c)
+	p.count++
+	p.stats.Increase(StatIdleUpstreamConnections)
+
+	if p.config.IdleConnTimeout > 0 {
+		c.timer = time.AfterFunc(p.config.IdleConnTimeout, func() {
+			if p.remove(c) {
+				c.Close()
+			}
+		})
+	}
+
+	go func() {
+		_, err := c.reader.Peek(1)
+		c.watcher <- err
+
+		// the server closed the connection or sent data without a request
+		if !errors.Is(err, os.ErrDeadlineExceeded) && p.remove(c) {
+			c.Close()
+		}
+	}()
+}
+
+// get returns an idle connection for key, or nil if there is none
+func (p *upstreamPool) get(key poolKey) *pooledConn {
+	for {
+		p.mutex.Lock()
+		conns := p.idle[key]
+		if len(conns) == 0 {
+			p.mutex.Unlock()
+			return nil
+		}
+
+		// the most recently used connection is the least likely to be closed
+		c := conns[len(conns)-1]
+		p.removeLocked(c)
+		p.mutex.Unlock()
+
+		// stop the watcher
+		c.SetReadDeadline(time.Unix(1, 0))
+		err := <-c.watcher
+		c.SetReadDeadline(time.Time{})
+
+		if !errors.Is(err, os.ErrDeadlineExceeded) || c.reader.Buffered() > 0 {
+			c.Close()
+			continue
+		}
+
+		p.stats.Increase(StatReusedUpstreamConnections)
+		return c
+	}
+}
+
+// remove takes c out of the idle connections and reports whether it was
+// idle
+func (p *upstreamPool) remove(c *pooledConn) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.removeLocked(c)
+}
+
+func (p *upstreamPool) removeLocked(c *pooledConn) bool {
+	if !c.idle {
+		return false
+	}
+
+	c.idle = false
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+
+	conns := p.idle[c.key]
+	for i, ic := range conns {
+		if ic == c {
+			conns = append(conns[:i:i], conns[i+1:]...)
+			break
+		}
+	}
+
+	if len(conns) == 0 {
+		delete(p.idle, c.key)
+	} else {
+		p.idle[c.key] = conns
+	}
+
+	p.count--
+	p.stats.Decrease(StatIdleUpstreamConnections)
+
+	return true
+}
+
+// closeIdle closes all the idle connections
+func (p *upstreamPool) closeIdle() {
+	p.mutex.Lock()
+	conns := []*pooledConn{}
+	for _, cs := range p.idle {
+		conns = append(conns, cs...)
+	}
+	for _, c := range conns {
+		p.removeLocked(c)
+	}
+	p.mutex.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// SetUpstreamPool configures the reuse of connections to upstream servers,
+// both for plain HTTP requests and for the requests of inspected tunnels.
+// Idle connections kept with the previous configuration are closed
+func (m *mitm) SetUpstreamPool(config PoolConfig) {
+	m.pool.setConfig(config)
+
+	old := m.client.Swap(m.newClient(config, m.getLimits()))
+	if old != nil {
+		old.CloseIdleConnections()
+	}
+}
+
+// newClient returns the client used to send plain HTTP requests
+func (m *mitm) newClient(config PoolConfig, limits Limits) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = m.upstreamProxyFor
+	dialer := &net.Dialer{
+		Timeout:   limits.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return m.resolver.Load().dial(ctx, dialer, addr)
+	}
+	transport.TLSHandshakeTimeout = limits.TLSHandshakeTimeout
+	transport.ResponseHeaderTimeout = limits.ResponseHeaderTimeout
+	transport.MaxIdleConns = config.MaxIdleConns
+	transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = config.IdleConnTimeout
+
+	if config.MaxIdleConnsPerHost <= 0 {
+		transport.DisableKeepAlives = true
+	}
+
+	return &http.Client{Transport: transport}
+}
+
+// dialPooled returns an idle connection to addr, or a new one if there is
+// none. If serverName is not empty, the connection uses TLS
+func (m *mitm) dialPooled(ctx context.Context, addr, serverName string) (*pooledConn, error) {
+	upstream := m.upstreamProxies.Load()
+
+	host, portStr, _ := net.SplitHostPort(addr)
+	port, _ := strconv.Atoi(portStr)
+
+	key := poolKey{addr: addr, serverName: serverName}
+	if proxy := upstream.ProxyFor(host, port); proxy != nil {
+		key.proxy = proxy.String()
+	}
+
+	if c := m.pool.get(key); c != nil {
+		return c, nil
+	}
+
+	conn, err := m.dialWith(ctx, upstream, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if serverName != "" {
+		if timeout := m.getLimits().TLSHandshakeTimeout; timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		// force http/1.1 requests
+		tlsConn := tls.Client(conn, &tls.Config{
+			NextProtos:         []string{"http/1.1"},
+			ServerName:         serverName,
+			InsecureSkipVerify: true,
+		})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	return newPooledConn(conn, key, m.pool), nil
+}
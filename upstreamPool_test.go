@@ -0,0 +1,149 @@
+package efincore
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sendTestTunnelRequest sends a request through its own CONNECT tunnel and
+// closes the tunnel
+func sendTestTunnelRequest(t *testing.T, proxy *Proxy, url string) {
+	t.Helper()
+
+	client := newTestClientProxy(t, proxy.URL().String())
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("response status code: got '%d', expected '%d'", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func waitTestStat(t *testing.T, proxy *Proxy, stat string, expected int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for proxy.GetStats()[stat] != expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("stat '%s': got '%d', expected '%d'", stat, proxy.GetStats()[stat], expected)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUpstreamPool_TunnelsReuseUpstreamConnections(t *testing.T) {
+	var mutex sync.Mutex
+	remoteAddrs := map[string]bool{}
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		remoteAddrs[r.RemoteAddr] = true
+		mutex.Unlock()
+	})
+
+	proxy := runTestProxy(t)
+
+	for i := 0; i < 3; i++ {
+		sendTestTunnelRequest(t, proxy, server.URL)
+		waitTestStat(t, proxy, StatIdleUpstreamConnections, 1)
+	}
+
+	if got := proxy.GetStats()[StatReusedUpstreamConnections]; got != 2 {
+		t.Errorf("reused upstream connections: got '%d', expected '%d'", got, 2)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(remoteAddrs) != 1 {
+		t.Errorf("upstream connections: got '%d', expected '%d'", len(remoteAddrs), 1)
+	}
+}
+
+func TestUpstreamPool_Disabled(t *testing.T) {
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	proxy := runTestProxy(t)
+	proxy.SetUpstreamPool(PoolConfig{})
+
+	sendTestTunnelRequest(t, proxy, server.URL)
+	sendTestTunnelRequest(t, proxy, server.URL)
+
+	if got := proxy.GetStats()[StatReusedUpstreamConnections]; got != 0 {
+		t.Errorf("reused upstream connections: got '%d', expected '%d'", got, 0)
+	}
+
+	if got := proxy.GetStats()[StatIdleUpstreamConnections]; got != 0 {
+		t.Errorf("idle upstream connections: got '%d', expected '%d'", got, 0)
+	}
+}
+
+func TestUpstreamPool_IdleTimeout(t *testing.T) {
+	server := newTestServerHTTPS(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	proxy := runTestProxy(t)
+	proxy.SetUpstreamPool(PoolConfig{MaxIdleConnsPerHost: 1, IdleConnTimeout: 50 * time.Millisecond})
+
+	sendTestTunnelRequest(t, proxy, server.URL)
+	waitTestStat(t, proxy, StatIdleUpstreamConnections, 1)
+	waitTestStat(t, proxy, StatIdleUpstreamConnections, 0)
+}
+
+func TestUpstreamPool_ConnectionClosedByServerIsDiscarded(t *testing.T) {
+	stats := NewStatsService()
+	pool := newUpstreamPool(DefaultPoolConfig, stats)
+
+	client, server := net.Pipe()
+	key := poolKey{addr: "example.com:443"}
+	pool.put(newPooledConn(client, key, pool))
+
+	if got := stats.Get()[StatIdleUpstreamConnections]; got != 1 {
+		t.Fatalf("idle upstream connections: got '%d', expected '%d'", got, 1)
+	}
+
+	server.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for stats.Get()[StatIdleUpstreamConnections] != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("closed connection was not discarded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if c := pool.get(key); c != nil {
+		t.Errorf("got closed connection from the pool")
+	}
+}
+
+func TestUpstreamPool_GetStopsWatcher(t *testing.T) {
+	pool := newUpstreamPool(DefaultPoolConfig, NewStatsService())
+
+	client, server := net.Pipe()
+	defer server.Close()
+
+	key := poolKey{addr: "example.com:443"}
+	pool.put(newPooledConn(client, key, pool))
+
+	c := pool.get(key)
+	if c == nil {
+		t.Fatalf("idle connection not returned")
+	}
+
+	go server.Write([]byte("data"))
+
+	got := make([]byte, 4)
+	if _, err := c.reader.Read(got); err != nil {
+		t.Fatalf("could not read from connection: %v", err)
+	}
+
+	if string(got) != "data" {
+		t.Errorf("data: got '%s', expected '%s'", got, "data")
+	}
+}